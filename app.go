@@ -70,6 +70,12 @@ func getFlags() []cli.Flag {
 			DefaultText: "<karbon password>",
 			EnvVars:     []string{"NUTANIX_KARBON_PASS"},
 		}),
+		altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+			Name:    "protected-category",
+			Usage:   "category as Key:Value or Key that prevents a VM from being deleted. may be repeated",
+			Value:   cli.NewStringSlice("Protected:true"),
+			EnvVars: []string{"NUTANIX_PROTECTED_CATEGORY"},
+		}),
 		&cli.StringFlag{
 			Name:        "profile",
 			Aliases:     []string{"pro"},
@@ -78,6 +84,21 @@ func getFlags() []cli.Flag {
 			DefaultText: "<default>",
 			EnvVars:     []string{"NUTANIX_PROFILE"},
 		},
+		&cli.StringFlag{
+			Name:        "output",
+			Aliases:     []string{"o"},
+			Value:       "table",
+			Usage:       "output format <table|json|yaml|csv|tsv>",
+			DefaultText: "table",
+			EnvVars:     []string{"NUTANIX_OUTPUT"},
+		},
+		&cli.StringFlag{
+			Name:        "query",
+			Aliases:     []string{"q"},
+			Value:       "",
+			Usage:       "JMESPath query applied to the command result before output",
+			DefaultText: "<jmespath expression>",
+		},
 		&cli.StringFlag{
 			Name:        "image-name",
 			Aliases:     []string{"iname", "in"},
@@ -106,6 +127,10 @@ func getFlags() []cli.Flag {
 			Usage:       "Image Type - DISK_IMAGE or ISO_IMAGE",
 			DefaultText: "<image type>",
 		},
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "print the requests mutating commands would send as a diff without sending them",
+		},
 		&cli.BoolFlag{
 			Name:    "skip-cert-verify",
 			Aliases: []string{"skipverify", "scv"},
@@ -115,3 +140,67 @@ func getFlags() []cli.Flag {
 	}
 	return output
 }
+
+// waitFlags are the task polling flags shared by mutating commands
+func waitFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "wait",
+			Usage: "wait for the resulting task to finish",
+		},
+		&cli.DurationFlag{
+			Name:  "timeout",
+			Value: defaultTaskTimeout,
+			Usage: "maximum time to wait for the task with --wait",
+		},
+	}
+}
+
+// powerCycleFlag lets resource updates power a running VM off and on without
+// asking when the change cannot be hot added
+func powerCycleFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name:  "power-cycle",
+		Usage: "power the VM off and on around changes that cannot be hot added without asking",
+	}
+}
+
+// diskBusFlag selects the adapter type of a VM disk
+func diskBusFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "bus",
+		Value: "SCSI",
+		Usage: "disk adapter type <SCSI|IDE|PCI|SATA|SPAPR_VSCSI>",
+	}
+}
+
+// diskIndexFlag sets the device index of a new VM disk
+func diskIndexFlag() cli.Flag {
+	return &cli.IntFlag{
+		Name:  "index",
+		Usage: "device index on the bus. the next free index when not set",
+	}
+}
+
+// cdromDeviceFlag picks a VM CD-ROM by bus and device index
+func cdromDeviceFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "device",
+		Usage: "CD-ROM as bus.index such as IDE.0. picked automatically when not set",
+	}
+}
+
+// selectorFlags let a mutating command act on every VM matched by a selector
+func selectorFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "selector",
+			Usage: "act on the VMs matched by key=value pairs such as name=web-*,cluster=prod,power=on",
+		},
+		&cli.IntFlag{
+			Name:  "parallel",
+			Value: 4,
+			Usage: "number of VMs changed at the same time with --selector",
+		},
+	}
+}
@@ -0,0 +1,500 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	nutanix "github.com/routebyintuition/ntnx-go-sdk"
+	"github.com/routebyintuition/ntnx-go-sdk/pc"
+	"github.com/urfave/cli/v2"
+)
+
+// plan actions
+const (
+	actionCreate = "create"
+	actionUpdate = "update"
+	actionDrift  = "drift"
+)
+
+// specChange is a single field difference between a live VM and its spec
+type specChange struct {
+	Field   string
+	Current string
+	Desired string
+	// Drift marks differences that apply reports but does not change
+	Drift bool
+}
+
+// vmPlan is the planned action for one VM spec
+type vmPlan struct {
+	Doc     *vmDocument
+	UUID    string
+	Action  string
+	Changes []specChange
+}
+
+// applySpecFiles returns the YAML files named by the -f flags. Directories
+// contribute every .yaml and .yml file they contain.
+func applySpecFiles(paths []string) ([]string, error) {
+	files := []string{}
+
+	for _, p := range paths {
+		dir, err := isDirectory(p)
+		if err != nil {
+			return nil, err
+		}
+		if !dir {
+			files = append(files, p)
+			continue
+		}
+
+		entries, err := ioutil.ReadDir(p)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			ext := strings.ToLower(filepath.Ext(e.Name()))
+			if !e.IsDir() && (ext == ".yaml" || ext == ".yml") {
+				files = append(files, filepath.Join(p, e.Name()))
+			}
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, errors.New("no VM spec files found")
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// apply creates or updates VMs so that they match the provided specs
+func (n *NCLI) apply(c *cli.Context) error {
+	if len(c.StringSlice("filename")) == 0 {
+		return errors.New("no VM spec provided. use -f <file or directory>")
+	}
+
+	files, err := applySpecFiles(c.StringSlice("filename"))
+	if err != nil {
+		return err
+	}
+
+	vars, err := loadTemplateVars(os.Environ(), c.String("var-file"), c.StringSlice("var"))
+	if err != nil {
+		return err
+	}
+
+	docs := []*vmDocument{}
+	for _, fl := range files {
+		raw, err := ioutil.ReadFile(fl)
+		if err != nil {
+			return err
+		}
+		fileDocs, err := n.loadVMDocuments(fl, raw, vars, guestFiles{})
+		if err != nil {
+			return err
+		}
+		docs = append(docs, fileDocs...)
+	}
+
+	plans, err := n.planVMs(docs)
+	if err != nil {
+		return err
+	}
+
+	if err := n.render(c, planResult(plans)); err != nil {
+		return err
+	}
+
+	pending := 0
+	for _, p := range plans {
+		if p.Action == actionCreate || p.Action == actionUpdate {
+			pending++
+		}
+	}
+	if pending == 0 {
+		fmt.Fprintln(n.out, "no changes to apply")
+		return nil
+	}
+
+	if !c.Bool("yes") && !n.dryRun {
+		ok, err := n.confirm(fmt.Sprintf("apply changes to %d VMs?", pending))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("apply cancelled")
+		}
+	}
+
+	failed := 0
+	for _, p := range plans {
+		var taskUUID string
+
+		switch p.Action {
+		case actionCreate:
+			createRes, err := n.createVM(p.Doc)
+			if err == errDryRun {
+				continue
+			}
+			if err != nil {
+				failed++
+				fmt.Fprintf(n.out, "%s: create failed: %v\n", p.Doc.name(), err)
+				continue
+			}
+			taskUUID = executionTask(createRes.Status)
+		case actionUpdate:
+			updateRes, err := n.updateVM(p.UUID, func(vm *vmSpec) error {
+				_, err := reconcileVM(vm, p.Doc)
+				return err
+			})
+			if err == errDryRun {
+				continue
+			}
+			if err != nil {
+				failed++
+				fmt.Fprintf(n.out, "%s: update failed: %v\n", p.Doc.name(), err)
+				continue
+			}
+			taskUUID = executionTask(updateRes.Status)
+		default:
+			continue
+		}
+
+		fmt.Fprintf(n.out, "%s: %s submitted\n", p.Doc.name(), p.Action)
+		if err := n.reportTask(c, n.getPCTask, taskUUID); err != nil {
+			failed++
+			fmt.Fprintf(n.out, "%s: %v\n", p.Doc.name(), err)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d VM changes failed", failed, pending)
+	}
+
+	return nil
+}
+
+// planVMs matches each spec to an existing VM by name and works out whether
+// it needs to be created or updated
+func (n *NCLI) planVMs(docs []*vmDocument) ([]*vmPlan, error) {
+	vms, err := n.listAllVMs(new(pc.VMListRequest), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := map[string][]string{}
+	for _, vm := range vms {
+		if vm.Spec.Name != nil && vm.Metadata.UUID != nil {
+			byName[*vm.Spec.Name] = append(byName[*vm.Spec.Name], *vm.Metadata.UUID)
+		}
+	}
+
+	var inv *vmInventory
+	plans := []*vmPlan{}
+	seen := map[string]string{}
+
+	for _, doc := range docs {
+		name := doc.name()
+		if len(name) == 0 {
+			return nil, fmt.Errorf("%s: spec.name is required", doc.Source)
+		}
+		if prev, ok := seen[name]; ok {
+			return nil, fmt.Errorf("VM %s is defined in both %s and %s", name, prev, doc.Source)
+		}
+		seen[name] = doc.Source
+
+		uuids := byName[name]
+		switch len(uuids) {
+		case 0:
+			if inv == nil {
+				found, err := n.vmInventory()
+				if err != nil {
+					return nil, err
+				}
+				inv = &found
+			}
+			if violations := validateVMCreate(doc.Request, *inv); len(violations) > 0 {
+				return nil, fmt.Errorf("%s: invalid VM %s:\n  - %s", doc.Source, name, strings.Join(violations, "\n  - "))
+			}
+			plans = append(plans, &vmPlan{Doc: doc, Action: actionCreate, Changes: []specChange{{Field: "vm", Current: "absent", Desired: "new VM"}}})
+			continue
+		case 1:
+		default:
+			return nil, fmt.Errorf("%d VMs are named %s. apply needs VM names to be unique", len(uuids), name)
+		}
+
+		current, err := n.getVMSpec(uuids[0])
+		if err != nil {
+			return nil, err
+		}
+
+		changes, err := reconcileVM(current, doc)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+
+		plan := &vmPlan{Doc: doc, UUID: uuids[0], Changes: changes}
+		for _, ch := range changes {
+			if !ch.Drift {
+				plan.Action = actionUpdate
+				break
+			}
+			plan.Action = actionDrift
+		}
+		plans = append(plans, plan)
+	}
+
+	return plans, nil
+}
+
+// planResult formats the plan as a table with one row per change
+func planResult(plans []*vmPlan) *Result {
+	data := [][]string{}
+
+	for _, p := range plans {
+		if len(p.Changes) == 0 {
+			data = append(data, []string{p.Doc.name(), "none", "", "", ""})
+			continue
+		}
+		for _, ch := range p.Changes {
+			action := p.Action
+			if ch.Drift {
+				action = actionDrift
+			}
+			data = append(data, []string{p.Doc.name(), action, ch.Field, ch.Current, ch.Desired})
+		}
+	}
+
+	return &Result{
+		Header:     []string{"VM", "Action", "Field", "Current", "Desired"},
+		Rows:       data,
+		MergeCells: true,
+	}
+}
+
+// reconcileVM changes the live VM to match the document and returns every
+// difference found. Only fields present in the document are managed. Disks
+// are added or grown but never removed or shrunk.
+func reconcileVM(vm *vmSpec, doc *vmDocument) ([]specChange, error) {
+	changes := []specChange{}
+	desired := doc.Request.Spec
+
+	if vm.Spec.Resources == nil {
+		vm.Spec.Resources = &pc.Resources{}
+	}
+	live := vm.Spec.Resources
+
+	if desired.ClusterReference != nil && len(desired.ClusterReference.UUID) > 0 && vm.Spec.ClusterReference != nil && desired.ClusterReference.UUID != vm.Spec.ClusterReference.UUID {
+		return nil, fmt.Errorf("VM is on cluster %s and cannot be moved to %s by apply", vm.Spec.ClusterReference.UUID, desired.ClusterReference.UUID)
+	}
+
+	if res := desired.Resources; res != nil {
+		intChange := func(field string, want *int, have **int) {
+			if want == nil || (*have != nil && **have == *want) {
+				return
+			}
+			changes = append(changes, specChange{Field: field, Current: intValue(*have), Desired: strconv.Itoa(*want)})
+			v := *want
+			*have = &v
+		}
+		intChange("num_sockets", res.NumSockets, &live.NumSockets)
+		intChange("num_vcpus_per_socket", res.NumVcpusPerSocket, &live.NumVcpusPerSocket)
+		intChange("memory_size_mib", res.MemorySizeMib, &live.MemorySizeMib)
+
+		if res.PowerState != nil && !strings.EqualFold(*res.PowerState, stringValue(live.PowerState)) {
+			changes = append(changes, specChange{Field: "power_state", Current: stringValue(live.PowerState), Desired: strings.ToUpper(*res.PowerState)})
+			live.PowerState = nutanix.String(strings.ToUpper(*res.PowerState))
+		}
+
+		if res.NicList != nil {
+			if ch, ok := reconcileNICs(live, *res.NicList); ok {
+				changes = append(changes, ch)
+			}
+		}
+
+		if res.DiskList != nil {
+			diskChanges, err := reconcileDisks(live, *res.DiskList)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, diskChanges...)
+		}
+	}
+
+	if doc.Categories != nil {
+		changes = append(changes, reconcileCategories(vm, doc.Categories)...)
+	}
+
+	return changes, nil
+}
+
+// reconcileNICs replaces the NIC list when the subnets differ. NICs that stay
+// on the same subnet at the same position keep their UUID and MAC address.
+func reconcileNICs(live *pc.Resources, desired []pc.NicList) (specChange, bool) {
+	current := []pc.NicList{}
+	if live.NicList != nil {
+		current = *live.NicList
+	}
+
+	subnets := func(nics []pc.NicList) string {
+		out := []string{}
+		for _, nic := range nics {
+			name := nic.SubnetReference.Name
+			if len(name) == 0 {
+				name = nic.SubnetReference.UUID
+			}
+			out = append(out, name)
+		}
+		return strings.Join(out, ", ")
+	}
+
+	same := len(current) == len(desired)
+	for i := 0; same && i < len(desired); i++ {
+		same = current[i].SubnetReference.UUID == desired[i].SubnetReference.UUID
+	}
+	if same {
+		return specChange{}, false
+	}
+
+	change := specChange{Field: "nic_list", Current: subnets(current), Desired: subnets(desired)}
+
+	nics := []pc.NicList{}
+	for i, nic := range desired {
+		if i < len(current) && current[i].SubnetReference.UUID == nic.SubnetReference.UUID {
+			nics = append(nics, current[i])
+			continue
+		}
+		nics = append(nics, nic)
+	}
+	live.NicList = &nics
+
+	return change, true
+}
+
+// reconcileDisks adds disks that are missing and grows disks that are smaller
+// than the spec. Disks are matched on their adapter and device index.
+func reconcileDisks(live *pc.Resources, desired []pc.DiskList) ([]specChange, error) {
+	changes := []specChange{}
+
+	disks := []pc.DiskList{}
+	if live.DiskList != nil {
+		disks = *live.DiskList
+	}
+
+	index := map[string]int{}
+	for i, d := range disks {
+		if d.DeviceProperties != nil && d.DeviceProperties.DiskAddress != nil {
+			index[diskAddressKey(d.DeviceProperties.DiskAddress)] = i
+		}
+	}
+
+	wanted := map[string]bool{}
+	for _, d := range desired {
+		if d.DeviceProperties == nil || d.DeviceProperties.DiskAddress == nil || d.DeviceProperties.DiskAddress.DeviceIndex == nil {
+			return nil, errors.New("every disk in disk_list needs device_properties.disk_address for apply")
+		}
+		key := diskAddressKey(d.DeviceProperties.DiskAddress)
+		wanted[key] = true
+
+		i, ok := index[key]
+		if !ok {
+			changes = append(changes, specChange{Field: "disk " + key, Current: "absent", Desired: diskDescription(d)})
+			disks = append(disks, d)
+			continue
+		}
+
+		if d.DiskSizeMib == 0 || d.DiskSizeMib == disks[i].DiskSizeMib {
+			continue
+		}
+		if d.DiskSizeMib < disks[i].DiskSizeMib {
+			return nil, fmt.Errorf("disk %s cannot shrink from %d to %d MiB", key, disks[i].DiskSizeMib, d.DiskSizeMib)
+		}
+		changes = append(changes, specChange{Field: "disk " + key + " size", Current: strconv.Itoa(disks[i].DiskSizeMib) + " MiB", Desired: strconv.Itoa(d.DiskSizeMib) + " MiB"})
+		disks[i].DiskSizeMib = d.DiskSizeMib
+		disks[i].DiskSizeBytes = 0
+	}
+
+	for _, d := range disks {
+		if d.DeviceProperties == nil || d.DeviceProperties.DiskAddress == nil || d.DeviceProperties.DeviceType == "CDROM" {
+			continue
+		}
+		key := diskAddressKey(d.DeviceProperties.DiskAddress)
+		if !wanted[key] && len(d.UUID) > 0 {
+			changes = append(changes, specChange{Field: "disk " + key, Current: diskDescription(d), Desired: "not in spec (kept)", Drift: true})
+		}
+	}
+
+	live.DiskList = &disks
+	return changes, nil
+}
+
+// diskDescription summarises a disk for the plan
+func diskDescription(d pc.DiskList) string {
+	parts := []string{}
+	if d.DeviceProperties != nil && len(d.DeviceProperties.DeviceType) > 0 {
+		parts = append(parts, d.DeviceProperties.DeviceType)
+	}
+	if d.DiskSizeMib > 0 {
+		parts = append(parts, strconv.Itoa(d.DiskSizeMib)+" MiB")
+	}
+	if d.DataSourceReference != nil {
+		parts = append(parts, "from "+d.DataSourceReference.Kind+" "+d.DataSourceReference.UUID)
+	}
+	return strings.Join(parts, " ")
+}
+
+// reconcileCategories sets the VM categories to the desired map
+func reconcileCategories(vm *vmSpec, desired map[string]string) []specChange {
+	changes := []specChange{}
+
+	keys := []string{}
+	for k := range desired {
+		keys = append(keys, k)
+	}
+	for k := range vm.Categories {
+		if _, ok := desired[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if vm.Categories[k] != desired[k] {
+			changes = append(changes, specChange{Field: "category " + k, Current: vm.Categories[k], Desired: desired[k]})
+		}
+	}
+
+	if len(changes) > 0 {
+		vm.Categories = map[string]string{}
+		for k, v := range desired {
+			vm.Categories[k] = v
+		}
+	}
+
+	return changes
+}
+
+// confirm asks a yes or no question on the input reader
+func (n *NCLI) confirm(question string) (bool, error) {
+	in := n.in
+	if in == nil {
+		in = StdInputReader{}
+	}
+
+	fmt.Fprint(n.out, question+" [y/N]: ")
+	answer, err := in.ReadInput()
+	if err != nil {
+		return false, err
+	}
+	fmt.Fprintln(n.out)
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	nutanix "github.com/routebyintuition/ntnx-go-sdk"
+	"github.com/routebyintuition/ntnx-go-sdk/pc"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func testLiveVM() *vmSpec {
+	vm := &vmSpec{Categories: map[string]string{"Environment": "dev"}}
+	vm.Spec = pc.Spec{
+		Name:             nutanix.String("web-01"),
+		ClusterReference: &pc.ClusterReference{Kind: "cluster", UUID: "cluster-a"},
+		Resources: &pc.Resources{
+			NumSockets:        intPtr(2),
+			NumVcpusPerSocket: intPtr(1),
+			MemorySizeMib:     intPtr(4096),
+			PowerState:        nutanix.String("ON"),
+			NicList: &[]pc.NicList{
+				{UUID: "nic-1", MacAddress: "50:6b:8d:00:00:01", SubnetReference: pc.SubnetReference{Kind: "subnet", UUID: "subnet-a"}},
+			},
+			DiskList: &[]pc.DiskList{
+				{UUID: "disk-1", DiskSizeMib: 10240, DeviceProperties: &pc.DeviceProperties{DeviceType: "DISK", DiskAddress: &pc.DiskAddress{AdapterType: "SCSI", DeviceIndex: intPtr(0)}}},
+				{UUID: "disk-2", DiskSizeMib: 2048, DeviceProperties: &pc.DeviceProperties{DeviceType: "DISK", DiskAddress: &pc.DiskAddress{AdapterType: "SCSI", DeviceIndex: intPtr(1)}}},
+			},
+		},
+	}
+	return vm
+}
+
+func testDesiredVM(modify func(res *pc.Resources)) *vmDocument {
+	req := &pc.VMCreateRequest{}
+	req.Spec.Name = nutanix.String("web-01")
+	req.Spec.Resources = &pc.Resources{}
+	if modify != nil {
+		modify(req.Spec.Resources)
+	}
+	return &vmDocument{Request: req}
+}
+
+func Test_reconcileVM(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     *vmDocument
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "no managed fields",
+			doc:  testDesiredVM(nil),
+			want: []string{},
+		},
+		{
+			name: "cpu memory and power",
+			doc: testDesiredVM(func(res *pc.Resources) {
+				res.NumSockets = intPtr(4)
+				res.MemorySizeMib = intPtr(4096)
+				res.PowerState = nutanix.String("off")
+			}),
+			want: []string{"num_sockets 2 -> 4", "power_state ON -> OFF"},
+		},
+		{
+			name: "nic moved to another subnet",
+			doc: testDesiredVM(func(res *pc.Resources) {
+				res.NicList = &[]pc.NicList{{SubnetReference: pc.SubnetReference{Kind: "subnet", UUID: "subnet-b"}}}
+			}),
+			want: []string{"nic_list subnet-a -> subnet-b"},
+		},
+		{
+			name: "disk grown and added with drift",
+			doc: testDesiredVM(func(res *pc.Resources) {
+				res.DiskList = &[]pc.DiskList{
+					{DiskSizeMib: 20480, DeviceProperties: &pc.DeviceProperties{DeviceType: "DISK", DiskAddress: &pc.DiskAddress{AdapterType: "SCSI", DeviceIndex: intPtr(0)}}},
+					{DiskSizeMib: 1024, DeviceProperties: &pc.DeviceProperties{DeviceType: "DISK", DiskAddress: &pc.DiskAddress{AdapterType: "SCSI", DeviceIndex: intPtr(2)}}},
+				}
+			}),
+			want: []string{"disk SCSI.0 size 10240 MiB -> 20480 MiB", "disk SCSI.2 absent -> DISK 1024 MiB", "drift disk SCSI.1 DISK 2048 MiB -> not in spec (kept)"},
+		},
+		{
+			name: "disk shrink",
+			doc: testDesiredVM(func(res *pc.Resources) {
+				res.DiskList = &[]pc.DiskList{
+					{DiskSizeMib: 1024, DeviceProperties: &pc.DeviceProperties{DeviceType: "DISK", DiskAddress: &pc.DiskAddress{AdapterType: "SCSI", DeviceIndex: intPtr(0)}}},
+				}
+			}),
+			wantErr: "cannot shrink",
+		},
+		{
+			name: "categories",
+			doc: func() *vmDocument {
+				doc := testDesiredVM(nil)
+				doc.Categories = map[string]string{"Environment": "prod", "Owner": "ops"}
+				return doc
+			}(),
+			want: []string{"category Environment dev -> prod", "category Owner  -> ops"},
+		},
+		{
+			name: "cluster change",
+			doc: func() *vmDocument {
+				doc := testDesiredVM(nil)
+				doc.Request.Spec.ClusterReference = &pc.ClusterReference{Kind: "cluster", UUID: "cluster-b"}
+				return doc
+			}(),
+			wantErr: "cannot be moved",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, err := reconcileVM(testLiveVM(), tt.doc)
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("reconcileVM() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("reconcileVM() error = %v", err)
+			}
+
+			got := []string{}
+			for _, ch := range changes {
+				line := ch.Field + " " + ch.Current + " -> " + ch.Desired
+				if ch.Drift {
+					line = "drift " + line
+				}
+				got = append(got, line)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("reconcileVM() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_reconcileVM_keepsNIC(t *testing.T) {
+	vm := testLiveVM()
+	doc := testDesiredVM(func(res *pc.Resources) {
+		res.NicList = &[]pc.NicList{
+			{SubnetReference: pc.SubnetReference{Kind: "subnet", UUID: "subnet-a"}},
+			{SubnetReference: pc.SubnetReference{Kind: "subnet", UUID: "subnet-b"}},
+		}
+	})
+
+	if _, err := reconcileVM(vm, doc); err != nil {
+		t.Fatal(err)
+	}
+
+	nics := *vm.Spec.Resources.NicList
+	if len(nics) != 2 || nics[0].MacAddress != "50:6b:8d:00:00:01" || nics[1].SubnetReference.UUID != "subnet-b" {
+		t.Errorf("reconcileVM() nics = %+v", nics)
+	}
+}
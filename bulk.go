@@ -0,0 +1,329 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/routebyintuition/ntnx-go-sdk/pc"
+	"github.com/urfave/cli/v2"
+)
+
+// vmSelector picks a set of VMs for a bulk command. Name is a glob such as
+// web-* matched against the whole VM name.
+type vmSelector struct {
+	name       string
+	cluster    string
+	powerState string
+}
+
+// parseSelector reads a selector of comma separated key=value pairs with the
+// keys name, cluster and power
+func parseSelector(selector string) (vmSelector, error) {
+	sel := vmSelector{}
+	seen := map[string]bool{}
+
+	for _, part := range strings.Split(selector, ",") {
+		part = strings.TrimSpace(part)
+		i := strings.Index(part, "=")
+		if i < 1 || i == len(part)-1 {
+			return sel, fmt.Errorf("invalid selector %q. use key=value pairs such as name=web-*,cluster=prod", part)
+		}
+
+		key, value := strings.ToLower(strings.TrimSpace(part[:i])), strings.TrimSpace(part[i+1:])
+		if seen[key] {
+			return sel, fmt.Errorf("selector key %s is repeated", key)
+		}
+		seen[key] = true
+
+		switch key {
+		case "name":
+			if _, err := path.Match(value, ""); err != nil {
+				return sel, fmt.Errorf("invalid name pattern %q: %v", value, err)
+			}
+			sel.name = value
+		case "cluster":
+			sel.cluster = value
+		case "power":
+			sel.powerState = value
+		default:
+			return sel, fmt.Errorf("unknown selector key %s. <name, cluster, power>", key)
+		}
+	}
+
+	return sel, nil
+}
+
+// matches reports whether a listed VM matches the name pattern
+func (s vmSelector) matches(vm pc.Entities) bool {
+	if len(s.name) == 0 {
+		return true
+	}
+	ok, _ := path.Match(s.name, stringValue(vm.Spec.Name))
+	return ok
+}
+
+// selectVMs resolves a selector through the VM list API. Cluster and power
+// state are filtered by Prism and the name pattern locally.
+func (n *NCLI) selectVMs(selector string) ([]pc.Entities, error) {
+	sel, err := parseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := buildVMFilter("", sel.cluster, sel.powerState, "")
+	if err != nil {
+		return nil, err
+	}
+
+	vms, err := n.listAllVMs(&pc.VMListRequest{Filter: filter}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := []pc.Entities{}
+	for _, vm := range vms {
+		if sel.matches(vm) {
+			selected = append(selected, vm)
+		}
+	}
+
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("selector %q matched no VMs", selector)
+	}
+
+	return selected, nil
+}
+
+// bulkResult is the outcome of a bulk command on one VM
+type bulkResult struct {
+	status  string
+	task    string
+	message string
+}
+
+// bulkFunc runs a bulk command on one VM
+type bulkFunc func(vm pc.Entities) bulkResult
+
+// runBulk shows the selected VMs, asks for confirmation unless --force is set
+// and runs op on up to --parallel VMs at a time. A result table is printed
+// and an error is returned when any VM failed.
+func (n *NCLI) runBulk(c *cli.Context, vms []pc.Entities, action string, op bulkFunc) error {
+	parallel := c.Int("parallel")
+	if parallel < 1 {
+		return errors.New("invalid parallel value...should be at least 1")
+	}
+	// dry-run diffs are printed as they are made
+	if n.dryRun {
+		parallel = 1
+	}
+
+	data := [][]string{}
+	for _, vm := range vms {
+		cluster := ""
+		if vm.Spec.ClusterReference != nil {
+			cluster = vm.Spec.ClusterReference.Name
+		}
+		power := ""
+		if vm.Spec.Resources != nil {
+			power = stringValue(vm.Spec.Resources.PowerState)
+		}
+		data = append(data, []string{stringValue(vm.Spec.Name), stringValue(vm.Metadata.UUID), cluster, power})
+	}
+
+	err := n.render(c, &Result{
+		Header: []string{"Name", "UUID", "Cluster", "Power State"},
+		Rows:   data,
+	})
+	if err != nil {
+		return err
+	}
+
+	if !c.Bool("force") && !n.dryRun {
+		ok, err := n.confirm(fmt.Sprintf("%s %d VMs?", action, len(vms)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New(action + " cancelled")
+		}
+	}
+
+	results := make([]bulkResult, len(vms))
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+
+	for i := range vms {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = op(vms[i])
+		}(i)
+	}
+	wg.Wait()
+
+	if n.dryRun {
+		return nil
+	}
+
+	failed := 0
+	data = [][]string{}
+	for i, vm := range vms {
+		if results[i].status == "FAILED" {
+			failed++
+		}
+		data = append(data, []string{stringValue(vm.Spec.Name), results[i].task, results[i].status, results[i].message})
+	}
+
+	// the selection table already went through n.tr
+	err = renderResult(c, n.out, tablewriter.NewWriter(n.out), &Result{
+		Header: []string{"Name", "Task", "Status", "Message"},
+		Rows:   data,
+		NoWrap: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d VMs failed", failed, len(vms))
+	}
+
+	return nil
+}
+
+// bulkTask turns a submitted task into a result, waiting on it with --wait
+func bulkTask(c *cli.Context, get taskGetFunc, taskUUID string, err error) bulkResult {
+	if err == errDryRun {
+		return bulkResult{}
+	}
+	if err != nil {
+		return bulkResult{status: "FAILED", task: taskUUID, message: err.Error()}
+	}
+	if !c.Bool("wait") {
+		return bulkResult{status: "SUBMITTED", task: taskUUID}
+	}
+
+	// a task that times out counts as failed as well
+	task, err := waitForTask(get, taskUUID, c.Duration("timeout"))
+	if err != nil {
+		return bulkResult{status: "FAILED", task: taskUUID, message: err.Error()}
+	}
+
+	return bulkResult{status: task.Status, task: taskUUID}
+}
+
+// bulkSetPower changes the power state of the VMs matched by --selector
+func (n *NCLI) bulkSetPower(c *cli.Context, powerState string) error {
+	vms, err := n.selectVMs(c.String("selector"))
+	if err != nil {
+		return err
+	}
+
+	return n.runBulk(c, vms, "power "+powerState, func(vm pc.Entities) bulkResult {
+		taskUUID, getTask, err := n.setPower(stringValue(vm.Metadata.UUID), powerState)
+		return bulkTask(c, getTask, taskUUID, err)
+	})
+}
+
+// bulkDelete deletes the VMs matched by --selector. The whole set is refused
+// when any VM carries a protected category.
+func (n *NCLI) bulkDelete(c *cli.Context) error {
+	vms, err := n.selectVMs(c.String("selector"))
+	if err != nil {
+		return err
+	}
+
+	specs := map[string]*vmSpec{}
+	protected := []string{}
+	for _, vm := range vms {
+		spec, err := n.getVMSpec(stringValue(vm.Metadata.UUID))
+		if err != nil {
+			return err
+		}
+		specs[stringValue(vm.Metadata.UUID)] = spec
+
+		if cat, ok := protectedCategory(spec.Categories, c.StringSlice("protected-category")); ok {
+			protected = append(protected, stringValue(vm.Spec.Name)+" ("+cat+")")
+		}
+	}
+	if len(protected) > 0 {
+		sort.Strings(protected)
+		return errors.New("refusing to delete VMs with a protected category: " + strings.Join(protected, ", "))
+	}
+
+	return n.runBulk(c, vms, "delete", func(vm pc.Entities) bulkResult {
+		vmUUID := stringValue(vm.Metadata.UUID)
+		spec := specs[vmUUID]
+
+		if c.Bool("power-off-first") && spec.Spec.Resources != nil && stringValue(spec.Spec.Resources.PowerState) == "ON" {
+			if err := n.setPowerAndWait(c, vmUUID, "OFF"); err != nil {
+				return bulkResult{status: "FAILED", message: err.Error()}
+			}
+		}
+
+		taskUUID, err := n.deleteVM(spec)
+		result := bulkTask(c, n.getPCTask, taskUUID, err)
+		if result.status != "SUCCEEDED" {
+			return result
+		}
+
+		// as with a single delete, the task succeeding is not enough
+		gone, err := n.vmGone(vmUUID)
+		if err != nil {
+			return bulkResult{status: "FAILED", task: taskUUID, message: err.Error()}
+		}
+		if !gone {
+			return bulkResult{status: "FAILED", task: taskUUID, message: "vm still exists after its delete task"}
+		}
+		return result
+	})
+}
+
+// bulkUpdateResources makes a CPU or memory change on the VMs matched by
+// --selector. Running VMs that cannot take the change fail unless
+// --power-cycle is set.
+func (n *NCLI) bulkUpdateResources(c *cli.Context, ch resourceChange) error {
+	vms, err := n.selectVMs(c.String("selector"))
+	if err != nil {
+		return err
+	}
+
+	return n.runBulk(c, vms, "update", func(vm pc.Entities) bulkResult {
+		vmUUID := stringValue(vm.Metadata.UUID)
+
+		spec, err := n.getVMSpec(vmUUID)
+		if err != nil {
+			return bulkResult{status: "FAILED", message: err.Error()}
+		}
+		if spec.Spec.Resources == nil {
+			spec.Spec.Resources = &pc.Resources{}
+		}
+		if err := ch.validate(spec.Spec.Resources); err != nil {
+			return bulkResult{status: "FAILED", message: err.Error()}
+		}
+
+		if blockers := ch.hotAddBlockers(spec.Spec.Resources); len(blockers) > 0 && !n.dryRun {
+			if !c.Bool("power-cycle") {
+				return bulkResult{status: "FAILED", message: strings.Join(blockers, "; ") + ". use --power-cycle"}
+			}
+			taskUUID, err := n.powerCycleUpdate(c, vmUUID, ch)
+			return bulkTask(c, n.getPCTask, taskUUID, err)
+		}
+
+		updateRes, err := n.updateVM(vmUUID, func(vm *vmSpec) error {
+			ch.set(vm.Spec.Resources)
+			return nil
+		})
+		if err != nil {
+			return bulkTask(c, n.getPCTask, "", err)
+		}
+		return bulkTask(c, n.getPCTask, executionTask(updateRes.Status), nil)
+	})
+}
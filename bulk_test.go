@@ -0,0 +1,58 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	nutanix "github.com/routebyintuition/ntnx-go-sdk"
+	"github.com/routebyintuition/ntnx-go-sdk/pc"
+)
+
+func Test_parseSelector(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector string
+		want     vmSelector
+		wantErr  bool
+	}{
+		{name: "all keys", selector: "name=web-*, cluster=prod,power=on", want: vmSelector{name: "web-*", cluster: "prod", powerState: "on"}},
+		{name: "key case", selector: "Name=db-01", want: vmSelector{name: "db-01"}},
+		{name: "missing value", selector: "name=", wantErr: true},
+		{name: "missing key", selector: "=web", wantErr: true},
+		{name: "no pair", selector: "web-*", wantErr: true},
+		{name: "unknown key", selector: "host=ahv-01", wantErr: true},
+		{name: "repeated key", selector: "name=a*,name=b*", wantErr: true},
+		{name: "bad pattern", selector: "name=web-[", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSelector(tt.selector)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSelector() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSelector() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_vmSelector_matches(t *testing.T) {
+	vm := pc.Entities{Spec: pc.Spec{Name: nutanix.String("web-01")}}
+
+	tests := []struct {
+		pattern string
+		want    bool
+	}{
+		{pattern: "", want: true},
+		{pattern: "web-*", want: true},
+		{pattern: "web-0?", want: true},
+		{pattern: "web", want: false},
+		{pattern: "db-*", want: false},
+	}
+	for _, tt := range tests {
+		if got := (vmSelector{name: tt.pattern}).matches(vm); got != tt.want {
+			t.Errorf("matches(%q) = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"text/template"
+
+	"github.com/routebyintuition/ntnx-go-sdk/pc"
+	"github.com/urfave/cli/v2"
+)
+
+// vmCloneRequest is the v3 VM clone request. Fields left out of the override
+// spec are copied from the source VM.
+type vmCloneRequest struct {
+	OverrideSpec vmCloneOverride `json:"override_spec"`
+}
+
+// vmCloneOverride holds the resources that differ from the source VM
+type vmCloneOverride struct {
+	Name               string                 `json:"name"`
+	NumSockets         *int                   `json:"num_sockets,omitempty"`
+	NumVcpusPerSocket  *int                   `json:"num_vcpus_per_socket,omitempty"`
+	MemorySizeMib      *int                   `json:"memory_size_mib,omitempty"`
+	NicList            *[]pc.NicList          `json:"nic_list,omitempty"`
+	GuestCustomization *pc.GuestCustomization `json:"guest_customization,omitempty"`
+}
+
+// cloneNames renders the clone name pattern once per clone. The pattern is a
+// template where {{.Index}} is the clone number starting at start.
+func cloneNames(pattern string, count, start int) ([]string, error) {
+	if count < 1 {
+		return nil, errors.New("invalid count value...should be at least 1")
+	}
+
+	tmpl, err := template.New("name").Option("missingkey=error").Parse(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid name pattern %q: %v", pattern, err)
+	}
+
+	names := []string{}
+	seen := map[string]bool{}
+
+	for i := 0; i < count; i++ {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, struct{ Index int }{start + i}); err != nil {
+			return nil, fmt.Errorf("invalid name pattern %q: %v", pattern, err)
+		}
+
+		name := buf.String()
+		if len(name) < vmMinNameLength || len(name) > vmMaxNameLength {
+			return nil, fmt.Errorf("clone name %q must be %d to %d characters", name, vmMinNameLength, vmMaxNameLength)
+		}
+		if seen[name] {
+			return nil, errors.New("name pattern gives duplicate names. use {{.Index}} when --count is greater than 1")
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+// cloneOverride builds the resources shared by every clone from the flags
+func (n *NCLI) cloneOverride(c *cli.Context) (vmCloneOverride, error) {
+	override := vmCloneOverride{}
+
+	if c.IsSet("vcpus") {
+		vcpus := c.Int("vcpus")
+		if vcpus < 1 || vcpus > vmMaxVCPUs {
+			return override, fmt.Errorf("invalid vcpus value...should be 1 to %d", vmMaxVCPUs)
+		}
+		perSocket := 1
+		override.NumSockets = &vcpus
+		override.NumVcpusPerSocket = &perSocket
+	}
+
+	if c.IsSet("memory") {
+		memory, err := ParseMibSize(c.String("memory"), "MiB")
+		if err != nil {
+			return override, err
+		}
+		if memory < vmMinMemoryMib || memory > vmMaxMemoryMib {
+			return override, fmt.Errorf("invalid memory value...should be %d to %d MiB", vmMinMemoryMib, vmMaxMemoryMib)
+		}
+		override.MemorySizeMib = &memory
+	}
+
+	if len(c.String("subnet")) > 0 {
+		subnetUUID, err := n.resolveUUID(kindSubnet, c.String("subnet"))
+		if err != nil {
+			return override, err
+		}
+		override.NicList = &[]pc.NicList{{SubnetReference: pc.SubnetReference{Kind: "subnet", UUID: subnetUUID}}}
+	}
+
+	files := guestFiles{UserData: c.String("cloud-init"), MetaData: c.String("meta-data"), UnattendXML: c.String("sysprep")}
+	guest := &pc.VMCreateRequest{}
+	if err := applyGuestFiles(guest, files); err != nil {
+		return override, err
+	}
+	if guest.Spec.Resources != nil {
+		override.GuestCustomization = guest.Spec.Resources.GuestCustomization
+	}
+
+	return override, nil
+}
+
+// cloneVM submits a clone of the source VM and returns the clone task UUID
+func (n *NCLI) cloneVM(sourceUUID string, override vmCloneOverride) (string, error) {
+	body := &vmCloneRequest{OverrideSpec: override}
+	path := "vms/" + sourceUUID + "/clone"
+
+	if n.dryRun {
+		return "", n.dryRunRequest("POST", path, nil, body)
+	}
+
+	req, err := n.con.PC.NewRequest("POST", path, body)
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		TaskUUID string `json:"task_uuid"`
+	}
+	if _, err := n.con.PC.Do(req, &result); err != nil {
+		return "", err
+	}
+
+	return result.TaskUUID, nil
+}
+
+// vmClone creates one or more clones of a VM and waits on all of the clone
+// tasks together with --wait
+func (n *NCLI) vmClone(c *cli.Context) error {
+	sourceUUID, err := n.resolveUUID(kindVM, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	if len(c.String("name")) == 0 {
+		return errors.New("no clone name provided. use --name <name or pattern such as web-{{.Index}}>")
+	}
+
+	names, err := cloneNames(c.String("name"), c.Int("count"), c.Int("start-index"))
+	if err != nil {
+		return err
+	}
+
+	override, err := n.cloneOverride(c)
+	if err != nil {
+		return err
+	}
+
+	// a clone that cannot be submitted is reported with the others instead
+	// of hiding the clones already submitted
+	taskUUIDs := []string{}
+	taskRows := []int{}
+	data := [][]string{}
+	failed := 0
+
+	for _, name := range names {
+		override.Name = name
+
+		taskUUID, err := n.cloneVM(sourceUUID, override)
+		if err == errDryRun {
+			continue
+		}
+		if err != nil {
+			failed++
+			data = append(data, []string{name, "", "FAILED", err.Error()})
+			continue
+		}
+
+		taskUUIDs = append(taskUUIDs, taskUUID)
+		taskRows = append(taskRows, len(data))
+		data = append(data, []string{name, taskUUID, "SUBMITTED", ""})
+	}
+
+	if len(data) == 0 {
+		return nil
+	}
+
+	if c.Bool("wait") && len(taskUUIDs) > 0 {
+		tasks, errs := waitForTasks(n.getPCTask, taskUUIDs, c.Duration("timeout"))
+		for i, row := range taskRows {
+			if tasks[i] != nil {
+				data[row][2] = tasks[i].Status
+			}
+			if errs[i] != nil {
+				failed++
+				data[row][3] = errs[i].Error()
+			}
+		}
+	}
+
+	err = n.render(c, &Result{
+		Header: []string{"Name", "Task", "Status", "Message"},
+		Rows:   data,
+	})
+	if err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d clones did not succeed", failed, len(data))
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_cloneNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		count   int
+		start   int
+		want    []string
+		wantErr string
+	}{
+		{name: "single", pattern: "web-clone", count: 1, start: 1, want: []string{"web-clone"}},
+		{name: "index", pattern: "web-{{.Index}}", count: 3, start: 1, want: []string{"web-1", "web-2", "web-3"}},
+		{name: "padded", pattern: `web-{{printf "%02d" .Index}}`, count: 2, start: 9, want: []string{"web-09", "web-10"}},
+		{name: "duplicate", pattern: "web-clone", count: 2, start: 1, wantErr: "duplicate names"},
+		{name: "unknown field", pattern: "web-{{.Number}}", count: 1, start: 1, wantErr: "invalid name pattern"},
+		{name: "too short", pattern: "{{.Index}}", count: 1, start: 1, wantErr: "must be 3 to 80 characters"},
+		{name: "no clones", pattern: "web", count: 0, start: 1, wantErr: "invalid count"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cloneNames(tt.pattern, tt.count, tt.start)
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("cloneNames() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("cloneNames() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("cloneNames() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
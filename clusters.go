@@ -1,32 +1,25 @@
 package main
 
 import (
-	"fmt"
-	"log"
 	"strconv"
 
 	"github.com/routebyintuition/ntnx-go-sdk/pc"
 	"github.com/routebyintuition/ntnx-go-sdk/pe"
 	"github.com/urfave/cli/v2"
-	"gopkg.in/yaml.v2"
 )
 
 // clusterList returns a list of all PC clusters
 func (n *NCLI) clusterList(c *cli.Context) error {
-	ListRequest := new(pc.ClusterListRequest)
-
-	getRes, _, err := n.con.PC.Cluster.List(ListRequest)
+	clusterListLoop, err := n.listAllClusters()
 	if err != nil {
 		return err
 	}
 
-	n.tr.SetHeader([]string{"Cluster Name", "UUID", "Hypervisor", "Version"})
-
 	data := [][]string{}
 
 	entityCount := 0
 
-	for _, entityValue := range getRes.Entities {
+	for _, entityValue := range clusterListLoop {
 		if entityValue.Status.Resources.Nodes != nil {
 			entityCount++
 			hypervisorType := "AHV"
@@ -42,12 +35,12 @@ func (n *NCLI) clusterList(c *cli.Context) error {
 		}
 	}
 
-	n.tr.SetFooter([]string{"", "", "TOTAL", strconv.Itoa(entityCount)})
-
-	n.tr.AppendBulk(data)
-	n.tr.Render()
-
-	return nil
+	return n.render(c, &Result{
+		Header: []string{"Cluster Name", "UUID", "Hypervisor", "Version"},
+		Footer: []string{"", "", "TOTAL", strconv.Itoa(entityCount)},
+		Rows:   data,
+		Entity: clusterListLoop,
+	})
 }
 
 // clusterGet returns details of cluster from PE
@@ -59,11 +52,33 @@ func (n *NCLI) clusterGet(c *cli.Context) error {
 		return err
 	}
 
-	yamlDoc, err := yaml.Marshal(&getRes)
-	if err != nil {
-		log.Fatalf("error on yaml converstion: %v", err)
+	data := [][]string{
+		{"Name", stringValue(getRes.Name)},
+		{"UUID", stringValue(getRes.ClusterUUID)},
+		{"Version", stringValue(getRes.Version)},
+		{"Full Version", stringValue(getRes.FullVersion)},
+		{"External IP", stringValue(getRes.ClusterExternalIpaddress)},
+		{"Nodes", intValue(getRes.NumNodes)},
 	}
-	fmt.Printf("%s\n", string(yamlDoc))
 
-	return nil
+	return n.render(c, &Result{
+		Header: []string{"Attribute", "Value"},
+		Rows:   data,
+		Entity: getRes,
+		NoWrap: true,
+	})
+}
+
+// listAllClusters pages through the cluster list API and returns every cluster
+func (n *NCLI) listAllClusters() ([]pc.Entities, error) {
+	return fetchPCEntities(0, func(offset, length int) ([]pc.Entities, int, error) {
+		ListRequest := &pc.ClusterListRequest{Offset: offset, Length: length}
+
+		getRes, _, err := n.con.PC.Cluster.List(ListRequest)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return getRes.Entities, *getRes.Metadata.TotalMatches, nil
+	})
 }
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/routebyintuition/ntnx-go-sdk/pc"
+	"github.com/urfave/cli/v2"
+)
+
+// protectedCategory returns the first protected category carried by a VM.
+// Protected entries are either Key:Value or a bare Key that matches any value.
+func protectedCategory(categories map[string]string, protected []string) (string, bool) {
+	for _, entry := range protected {
+		key, value := entry, ""
+		if i := strings.Index(entry, ":"); i >= 0 {
+			key, value = entry[:i], entry[i+1:]
+		}
+
+		v, ok := categories[key]
+		if !ok {
+			continue
+		}
+		if len(value) == 0 || strings.EqualFold(v, value) {
+			return key + ":" + v, true
+		}
+	}
+
+	return "", false
+}
+
+// vmGone reports whether a VM no longer exists in Prism Central
+func (n *NCLI) vmGone(vmUUID string) (bool, error) {
+	req, err := n.con.PC.NewRequest("GET", "vms/"+vmUUID, nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := n.con.PC.Do(req, nil)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return false, nil
+}
+
+// deleteVM submits the deletion of a VM and returns the task UUID. With
+// --dry-run the request is printed as a diff against the current VM.
+func (n *NCLI) deleteVM(vm *vmSpec) (string, error) {
+	vmUUID := stringValue(vm.Metadata.UUID)
+
+	if n.dryRun {
+		current, err := vm.updateBody()
+		if err != nil {
+			return "", err
+		}
+		return "", n.dryRunRequest("DELETE", "vms/"+vmUUID, current, nil)
+	}
+
+	deleteRes, _, err := n.con.PC.VM.Delete(&pc.VMDeleteRequest{UUID: vmUUID})
+	if err != nil {
+		return "", err
+	}
+
+	return executionTask(deleteRes.Status), nil
+}
+
+// vmDelete deletes one or more VMs after confirmation. VMs carrying a
+// protected category are refused.
+func (n *NCLI) vmDelete(c *cli.Context) error {
+	if c.IsSet("selector") {
+		if c.Args().Len() > 0 {
+			return errors.New("use either VM arguments or --selector")
+		}
+		return n.bulkDelete(c)
+	}
+
+	if c.Args().Len() == 0 {
+		return errors.New("no VM provided. <VM name|UUID> [<VM name|UUID>...]")
+	}
+
+	vms := []*vmSpec{}
+	seen := map[string]bool{}
+
+	// a prefix or glob must never pick the VM to delete
+	for _, arg := range c.Args().Slice() {
+		vmUUID, err := n.resolveExactUUID(kindVM, arg)
+		if err != nil {
+			return err
+		}
+		if seen[vmUUID] {
+			continue
+		}
+		seen[vmUUID] = true
+
+		vm, err := n.getVMSpec(vmUUID)
+		if err != nil {
+			return err
+		}
+		vms = append(vms, vm)
+	}
+
+	protected := []string{}
+	data := [][]string{}
+
+	for _, vm := range vms {
+		cluster := ""
+		if vm.Spec.ClusterReference != nil {
+			cluster = vm.Spec.ClusterReference.Name
+		}
+		power := ""
+		if vm.Spec.Resources != nil {
+			power = stringValue(vm.Spec.Resources.PowerState)
+		}
+		data = append(data, []string{stringValue(vm.Spec.Name), stringValue(vm.Metadata.UUID), cluster, power})
+
+		if cat, ok := protectedCategory(vm.Categories, c.StringSlice("protected-category")); ok {
+			protected = append(protected, stringValue(vm.Spec.Name)+" ("+cat+")")
+		}
+	}
+
+	if len(protected) > 0 {
+		sort.Strings(protected)
+		return errors.New("refusing to delete VMs with a protected category: " + strings.Join(protected, ", "))
+	}
+
+	err := n.render(c, &Result{
+		Header: []string{"Name", "UUID", "Cluster", "Power State"},
+		Rows:   data,
+	})
+	if err != nil {
+		return err
+	}
+
+	if !c.Bool("force") && !n.dryRun {
+		ok, err := n.confirm(fmt.Sprintf("delete %d VMs?", len(vms)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("delete cancelled")
+		}
+	}
+
+	taskUUIDs := []string{}
+	deleted := []*vmSpec{}
+
+	for _, vm := range vms {
+		vmUUID := stringValue(vm.Metadata.UUID)
+		name := stringValue(vm.Spec.Name)
+
+		if c.Bool("power-off-first") && vm.Spec.Resources != nil && stringValue(vm.Spec.Resources.PowerState) == "ON" {
+			if err := n.setPowerAndWait(c, vmUUID, "OFF"); err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+		}
+
+		taskUUID, err := n.deleteVM(vm)
+		if err == errDryRun {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+
+		fmt.Fprintf(n.out, "vm %s delete submitted\n", name)
+		if len(taskUUID) > 0 {
+			fmt.Fprintf(n.out, "task: %s\n", taskUUID)
+		}
+
+		taskUUIDs = append(taskUUIDs, taskUUID)
+		deleted = append(deleted, vm)
+	}
+
+	if !c.Bool("wait") || len(taskUUIDs) == 0 {
+		return nil
+	}
+
+	_, errs := waitForTasks(n.getPCTask, taskUUIDs, c.Duration("timeout"))
+
+	failed := 0
+	for i, vm := range deleted {
+		name := stringValue(vm.Spec.Name)
+		if errs[i] != nil {
+			failed++
+			fmt.Fprintf(n.out, "vm %s: %v\n", name, errs[i])
+			continue
+		}
+
+		gone, err := n.vmGone(stringValue(vm.Metadata.UUID))
+		if err != nil || !gone {
+			failed++
+			fmt.Fprintf(n.out, "vm %s still exists after its delete task\n", name)
+			continue
+		}
+		fmt.Fprintf(n.out, "vm %s deleted\n", name)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d VMs were not deleted", failed, len(deleted))
+	}
+
+	return nil
+}
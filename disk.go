@@ -2,6 +2,7 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"strconv"
 
 	"github.com/routebyintuition/ntnx-go-sdk/pe"
@@ -10,44 +11,53 @@ import (
 
 // performs a prism element disk list to v2 API
 func (n *NCLI) diskList(c *cli.Context) error {
-	ListRequest := new(pe.DiskListRequest)
+	diskListLoop, err := fetchPEEntities(0, func(page, count int) ([]pe.Entities, int, error) {
+		ListRequest := &pe.DiskListRequest{Page: page, Count: count}
 
-	getRes, _, err := n.con.PE.Disk.List(ListRequest)
+		getRes, _, err := n.con.PE.Disk.List(ListRequest)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return getRes.Entities, *getRes.Metadata.TotalEntities, nil
+	})
 	if err != nil {
 		return err
 	}
 
-	n.tr.SetHeader([]string{"Disk UUID", "Tier", "Size", "Status", "Host", "Online"})
-
-	n.tr.SetFooter([]string{"", "", "", "", "TOTAL", strconv.Itoa(*getRes.Metadata.TotalEntities)})
-
 	data := [][]string{}
 
-	for _, entityValue := range getRes.Entities {
+	for _, entityValue := range diskListLoop {
 		data = append(data, []string{*entityValue.DiskUUID, *entityValue.StorageTierName, strconv.Itoa(int(*entityValue.DiskSize)), *entityValue.DiskStatus, *entityValue.HostName, strconv.FormatBool(*entityValue.Online)})
 	}
-	n.tr.AppendBulk(data)
-	n.tr.Render()
 
-	return nil
+	return n.render(c, &Result{
+		Header: []string{"Disk UUID", "Tier", "Size", "Status", "Host", "Online"},
+		Footer: []string{"", "", "", "", "TOTAL", strconv.Itoa(len(diskListLoop))},
+		Rows:   data,
+		Entity: diskListLoop,
+	})
 }
 
 // vDiskList lists all vdisks with details
 func (n *NCLI) vDiskList(c *cli.Context) error {
-	ListRequest := new(pe.DiskVirtualListRequest)
+	vdiskListLoop, err := fetchPEEntities(0, func(page, count int) ([]pe.Entities, int, error) {
+		ListRequest := &pe.DiskVirtualListRequest{Page: page, Count: count}
+
+		getRes, _, err := n.con.PE.Disk.ListVDisk(ListRequest)
+		if err != nil {
+			return nil, 0, err
+		}
 
-	getRes, _, err := n.con.PE.Disk.ListVDisk(ListRequest)
+		return getRes.Entities, *getRes.Metadata.TotalEntities, nil
+	})
 	if err != nil {
 		return err
 	}
 
-	n.tr.SetHeader([]string{"vDisk UUID", "Attached", "Disk Capacity", "VM Disk Address", "Storage Container UUID"})
-
-	n.tr.SetFooter([]string{"", "", "", "TOTAL", strconv.Itoa(*getRes.Metadata.TotalEntities)})
-
 	data := [][]string{}
 
-	for _, entityValue := range getRes.Entities {
+	for _, entityValue := range vdiskListLoop {
 		attachedVM := "None"
 		if entityValue.AttachedVMUUID != nil {
 			attachedVM = *entityValue.AttachedVMUUID
@@ -59,10 +69,13 @@ func (n *NCLI) vDiskList(c *cli.Context) error {
 
 		data = append(data, []string{*entityValue.UUID, attachedVM, BytesToHumanReadable(*entityValue.DiskCapacityInBytes), diskVMAddress, *entityValue.StorageContainerUUID})
 	}
-	n.tr.AppendBulk(data)
-	n.tr.Render()
 
-	return nil
+	return n.render(c, &Result{
+		Header: []string{"vDisk UUID", "Attached", "Disk Capacity", "VM Disk Address", "Storage Container UUID"},
+		Footer: []string{"", "", "", "TOTAL", strconv.Itoa(len(vdiskListLoop))},
+		Rows:   data,
+		Entity: vdiskListLoop,
+	})
 }
 
 // vDiskGetByUUID returns vdisk details based upon vdisk UUID
@@ -80,3 +93,47 @@ func (n *NCLI) vDiskGetByUUID(uuid string) (*pe.DiskVirtualGetResponse, error) {
 
 	return getRes, nil
 }
+
+// storageContainer is a Prism Element v2 storage container
+type storageContainer struct {
+	UUID string `json:"storage_container_uuid"`
+	Name string `json:"name"`
+}
+
+// storageContainerList is the v2 storage container list response. The SDK
+// has no storage container type.
+type storageContainerList struct {
+	Metadata struct {
+		TotalEntities int `json:"total_entities"`
+	} `json:"metadata"`
+	Entities []storageContainer `json:"entities"`
+}
+
+// listAllContainers pages through the Prism Element storage containers
+func (n *NCLI) listAllContainers() ([]storageContainer, error) {
+	pages, err := fetchPages(defaultPageSize, 0, func(offset, length int) (interface{}, int, error) {
+		relURL := fmt.Sprintf("storage_containers?page=%d&count=%d", offset/length+1, length)
+		req, err := n.con.PE.NewRequest("GET", relURL, nil)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		listRes := new(storageContainerList)
+		if _, err := n.con.PE.Do(req, listRes); err != nil {
+			return nil, 0, err
+		}
+
+		return listRes.Entities, listRes.Metadata.TotalEntities, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	containers := []storageContainer{}
+	for _, page := range pages {
+		items, _ := page.([]storageContainer)
+		containers = append(containers, items...)
+	}
+
+	return containers, nil
+}
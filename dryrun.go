@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// errDryRun is returned in place of sending a mutating request when
+// --dry-run is set. Commands treat it as success.
+var errDryRun = errors.New("dry run: request was not sent")
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+// ansi colors for diff output on a terminal
+const (
+	colorReset = "\x1b[0m"
+	colorBold  = "\x1b[1m"
+	colorRed   = "\x1b[31m"
+	colorGreen = "\x1b[32m"
+	colorCyan  = "\x1b[36m"
+)
+
+// dryRunRequest prints the request that would be sent as a unified diff
+// between the current and proposed bodies and returns errDryRun. A nil
+// current body shows the whole request as added.
+func (n *NCLI) dryRunRequest(method, path string, current, proposed interface{}) error {
+	before, err := jsonLines(current)
+	if err != nil {
+		return err
+	}
+	after, err := jsonLines(proposed)
+	if err != nil {
+		return err
+	}
+
+	diff := unifiedDiff("current", "proposed "+method+" "+path, before, after, diffContext)
+	if len(diff) == 0 {
+		fmt.Fprintf(n.out, "dry run: %s %s makes no changes\n", method, path)
+		return errDryRun
+	}
+
+	color := isTerminalWriter(n.out) && len(os.Getenv("NO_COLOR")) == 0
+	for _, line := range diff {
+		if color {
+			line = colorizeDiffLine(line)
+		}
+		fmt.Fprintln(n.out, line)
+	}
+
+	return errDryRun
+}
+
+// jsonLines formats a request body as indented JSON split into lines
+func jsonLines(v interface{}) ([]string, error) {
+	if v == nil {
+		return []string{}, nil
+	}
+
+	raw, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Split(string(raw), "\n"), nil
+}
+
+// colorizeDiffLine wraps a unified diff line in the ANSI color for its type
+func colorizeDiffLine(line string) string {
+	switch {
+	case strings.HasPrefix(line, "---"), strings.HasPrefix(line, "+++"):
+		return colorBold + line + colorReset
+	case strings.HasPrefix(line, "@@"):
+		return colorCyan + line + colorReset
+	case strings.HasPrefix(line, "-"):
+		return colorRed + line + colorReset
+	case strings.HasPrefix(line, "+"):
+		return colorGreen + line + colorReset
+	}
+	return line
+}
+
+// diffOp is one line of an edit script: ' ' kept, '-' removed or '+' added
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// diffLines returns the edit script turning a into b using the longest
+// common subsequence of lines
+func diffLines(a, b []string) []diffOp {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	ops := []diffOp{}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+
+	return ops
+}
+
+// unifiedDiff returns the lines of a unified diff between a and b with the
+// provided number of context lines. Identical input gives no lines.
+func unifiedDiff(fromName, toName string, a, b []string, context int) []string {
+	ops := diffLines(a, b)
+
+	changed := []int{}
+	for i, op := range ops {
+		if op.kind != ' ' {
+			changed = append(changed, i)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	out := []string{"--- " + fromName, "+++ " + toName}
+
+	for k := 0; k < len(changed); {
+		start := changed[k] - context
+		if start < 0 {
+			start = 0
+		}
+		end := changed[k] + context + 1
+
+		// extend the hunk while the next change is within the context window
+		for k++; k < len(changed) && changed[k]-context <= end; k++ {
+			end = changed[k] + context + 1
+		}
+		if end > len(ops) {
+			end = len(ops)
+		}
+
+		// line numbers of the hunk start in a and b
+		aLine, bLine := 1, 1
+		for _, op := range ops[:start] {
+			if op.kind != '+' {
+				aLine++
+			}
+			if op.kind != '-' {
+				bLine++
+			}
+		}
+
+		aCount, bCount := 0, 0
+		body := []string{}
+		for _, op := range ops[start:end] {
+			if op.kind != '+' {
+				aCount++
+			}
+			if op.kind != '-' {
+				bCount++
+			}
+			body = append(body, string(op.kind)+op.line)
+		}
+		if aCount == 0 {
+			aLine--
+		}
+		if bCount == 0 {
+			bLine--
+		}
+
+		out = append(out, fmt.Sprintf("@@ -%d,%d +%d,%d @@", aLine, aCount, bLine, bCount))
+		out = append(out, body...)
+	}
+
+	return out
+}
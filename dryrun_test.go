@@ -0,0 +1,51 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_unifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a    []string
+		b    []string
+		want []string
+	}{
+		{
+			name: "identical",
+			a:    []string{"a", "b"},
+			b:    []string{"a", "b"},
+			want: nil,
+		},
+		{
+			name: "all added",
+			a:    []string{},
+			b:    []string{"a", "b"},
+			want: []string{"--- old", "+++ new", "@@ -0,0 +1,2 @@", "+a", "+b"},
+		},
+		{
+			name: "changed line with context",
+			a:    []string{"1", "2", "3", "4", "5", "6", "7", "8", "9"},
+			b:    []string{"1", "2", "3", "4", "five", "6", "7", "8", "9"},
+			want: []string{"--- old", "+++ new", "@@ -2,7 +2,7 @@", " 2", " 3", " 4", "-5", "+five", " 6", " 7", " 8"},
+		},
+		{
+			name: "separate hunks",
+			a:    []string{"a", "1", "2", "3", "4", "5", "6", "7", "8", "b"},
+			b:    []string{"A", "1", "2", "3", "4", "5", "6", "7", "8", "B"},
+			want: []string{
+				"--- old", "+++ new",
+				"@@ -1,4 +1,4 @@", "-a", "+A", " 1", " 2", " 3",
+				"@@ -7,4 +7,4 @@", " 6", " 7", " 8", "-b", "+B",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unifiedDiff("old", "new", tt.a, tt.b, 3); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unifiedDiff() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+
+	"github.com/routebyintuition/ntnx-go-sdk/pc"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v2"
+)
+
+// vmExport is a VM spec in the format read by vm create and apply
+type vmExport struct {
+	APIVersion string           `yaml:"api_version"`
+	Metadata   vmExportMetadata `yaml:"metadata"`
+	Spec       pc.Spec          `yaml:"spec"`
+}
+
+// vmExportMetadata keeps the metadata that is not managed by the server
+type vmExportMetadata struct {
+	Kind       string            `yaml:"kind"`
+	Categories map[string]string `yaml:"categories,omitempty"`
+}
+
+// vmExport writes the spec of an existing VM as YAML that can be used with
+// vm create and apply
+func (n *NCLI) vmExport(c *cli.Context) error {
+	vmUUID, err := n.resolveUUID(kindVM, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	vm, err := n.getVMSpec(vmUUID)
+	if err != nil {
+		return err
+	}
+
+	imageNames := map[string]string{}
+	if c.Bool("templatize") {
+		images, err := n.listAllImages()
+		if err != nil {
+			return err
+		}
+		for _, img := range images {
+			if img.Metadata.UUID != nil && img.Spec.Name != nil {
+				imageNames[*img.Metadata.UUID] = *img.Spec.Name
+			}
+		}
+	}
+
+	out, err := yaml.Marshal(exportVMSpec(vm, c.Bool("templatize"), imageNames))
+	if err != nil {
+		return err
+	}
+
+	if fl := c.String("file"); len(fl) > 0 {
+		if err := ioutil.WriteFile(fl, out, 0644); err != nil {
+			return err
+		}
+		fmt.Fprintln(n.out, "vm spec written to", fl)
+		return nil
+	}
+
+	_, err = n.out.Write(out)
+	return err
+}
+
+// exportVMSpec strips the status and server managed fields from a VM. With
+// templatize the subnet, image and cluster UUIDs are replaced by template
+// lookups on their names so that the spec can be used on another cluster.
+func exportVMSpec(vm *vmSpec, templatize bool, imageNames map[string]string) *vmExport {
+	spec := vm.Spec
+
+	export := &vmExport{
+		APIVersion: vm.APIVersion,
+		Metadata:   vmExportMetadata{Kind: "vm", Categories: vm.Categories},
+	}
+	if len(export.APIVersion) == 0 {
+		export.APIVersion = "3.1"
+	}
+
+	lookup := func(fn, name, uuid string) string {
+		if !templatize || len(name) == 0 {
+			return uuid
+		}
+		return "{{ " + fn + " " + strconv.Quote(name) + " }}"
+	}
+
+	if spec.ClusterReference != nil {
+		ref := *spec.ClusterReference
+		ref.UUID = lookup("cluster", ref.Name, ref.UUID)
+		ref.URL = ""
+		spec.ClusterReference = &ref
+	}
+
+	if spec.Resources != nil {
+		res := *spec.Resources
+		res.HostReference = nil
+
+		if res.NicList != nil {
+			nics := []pc.NicList{}
+			for _, nic := range *res.NicList {
+				nic.UUID = ""
+				nic.MacAddress = ""
+				nic.IPEndpointList = nil
+				nic.SubnetReference.UUID = lookup("subnet", nic.SubnetReference.Name, nic.SubnetReference.UUID)
+				nics = append(nics, nic)
+			}
+			res.NicList = &nics
+		}
+
+		if res.DiskList != nil {
+			disks := []pc.DiskList{}
+			for _, disk := range *res.DiskList {
+				disk.UUID = ""
+				if disk.DiskSizeMib > 0 {
+					disk.DiskSizeBytes = 0
+				}
+				if disk.DataSourceReference != nil {
+					ref := *disk.DataSourceReference
+					if ref.Kind == "image" {
+						name := ref.Name
+						if len(name) == 0 {
+							name = imageNames[ref.UUID]
+						}
+						ref.UUID = lookup("image", name, ref.UUID)
+					}
+					ref.URL = ""
+					disk.DataSourceReference = &ref
+				}
+				disks = append(disks, disk)
+			}
+			res.DiskList = &disks
+		}
+
+		spec.Resources = &res
+	}
+
+	export.Spec = spec
+	return export
+}
@@ -3,6 +3,7 @@ module github.com/routebyintuition/uwncli
 go 1.15
 
 require (
+	github.com/jmespath/go-jmespath v0.4.0
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/olekukonko/tablewriter v0.0.4
 	github.com/routebyintuition/ntnx-go-sdk v0.0.0-20210211211700-3b04d0054ed0
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/routebyintuition/ntnx-go-sdk/pc"
+	"gopkg.in/yaml.v2"
+)
+
+// guestFiles are the guest customization files for a VM. Paths come from the
+// --cloud-init, --meta-data and --sysprep flags or from the *_file keys of the
+// VM YAML.
+type guestFiles struct {
+	UserData    string
+	MetaData    string
+	UnattendXML string
+}
+
+// guestFileSpec holds the file keys that the SDK types do not know about
+type guestFileSpec struct {
+	Spec struct {
+		Resources struct {
+			GuestCustomization struct {
+				CloudInit struct {
+					UserDataFile string `yaml:"user_data_file"`
+					MetaDataFile string `yaml:"meta_data_file"`
+				} `yaml:"cloud_init"`
+				Sysprep struct {
+					UnattendXMLFile string `yaml:"unattend_xml_file"`
+				} `yaml:"sysprep"`
+			} `yaml:"guest_customization"`
+		} `yaml:"resources"`
+	} `yaml:"spec"`
+}
+
+// specGuestFiles reads the *_file keys from a VM YAML. Relative paths are
+// resolved against the directory holding the spec.
+func specGuestFiles(raw []byte, baseDir string) (guestFiles, error) {
+	spec := guestFileSpec{}
+	if err := yaml.Unmarshal(raw, &spec); err != nil {
+		return guestFiles{}, err
+	}
+
+	resolve := func(p string) string {
+		if len(p) == 0 || filepath.IsAbs(p) || len(baseDir) == 0 {
+			return p
+		}
+		return filepath.Join(baseDir, p)
+	}
+
+	gc := spec.Spec.Resources.GuestCustomization
+	return guestFiles{
+		UserData:    resolve(gc.CloudInit.UserDataFile),
+		MetaData:    resolve(gc.CloudInit.MetaDataFile),
+		UnattendXML: resolve(gc.Sysprep.UnattendXMLFile),
+	}, nil
+}
+
+// merge returns the files with any path set in override taking precedence
+func (g guestFiles) merge(override guestFiles) guestFiles {
+	if len(override.UserData) > 0 {
+		g.UserData = override.UserData
+	}
+	if len(override.MetaData) > 0 {
+		g.MetaData = override.MetaData
+	}
+	if len(override.UnattendXML) > 0 {
+		g.UnattendXML = override.UnattendXML
+	}
+	return g
+}
+
+// applyGuestFiles reads, validates and base64 encodes the guest customization
+// files into the create request
+func applyGuestFiles(req *pc.VMCreateRequest, files guestFiles) error {
+	if len(files.UserData) == 0 && len(files.MetaData) == 0 && len(files.UnattendXML) == 0 {
+		return nil
+	}
+	if len(files.UnattendXML) > 0 && (len(files.UserData) > 0 || len(files.MetaData) > 0) {
+		return errors.New("cloud-init and sysprep cannot both be used for the same VM")
+	}
+
+	if req.Spec.Resources == nil {
+		req.Spec.Resources = &pc.Resources{}
+	}
+	if req.Spec.Resources.GuestCustomization == nil {
+		req.Spec.Resources.GuestCustomization = &pc.GuestCustomization{}
+	}
+	gc := req.Spec.Resources.GuestCustomization
+
+	if len(files.UnattendXML) > 0 {
+		raw, err := ioutil.ReadFile(files.UnattendXML)
+		if err != nil {
+			return err
+		}
+		if err := validateUnattendXML(raw); err != nil {
+			return fmt.Errorf("%s: %v", files.UnattendXML, err)
+		}
+		if gc.Sysprep == nil {
+			gc.Sysprep = &pc.Sysprep{}
+		}
+		if len(gc.Sysprep.InstallType) == 0 {
+			gc.Sysprep.InstallType = "PREPARED"
+		}
+		gc.Sysprep.UnattendXML = base64.StdEncoding.EncodeToString(raw)
+		return nil
+	}
+
+	if gc.CloudInit == nil {
+		gc.CloudInit = &pc.CloudInit{}
+	}
+
+	if len(files.UserData) > 0 {
+		raw, err := ioutil.ReadFile(files.UserData)
+		if err != nil {
+			return err
+		}
+		if err := validateUserData(raw); err != nil {
+			return fmt.Errorf("%s: %v", files.UserData, err)
+		}
+		gc.CloudInit.UserData = base64.StdEncoding.EncodeToString(raw)
+	}
+
+	if len(files.MetaData) > 0 {
+		raw, err := ioutil.ReadFile(files.MetaData)
+		if err != nil {
+			return err
+		}
+		if err := validateMetaData(raw); err != nil {
+			return fmt.Errorf("%s: %v", files.MetaData, err)
+		}
+		gc.CloudInit.MetaData = base64.StdEncoding.EncodeToString(raw)
+	}
+
+	return nil
+}
+
+// userDataHeaders are the first line prefixes cloud-init recognises
+var userDataHeaders = []string{"#cloud-config", "#!", "#include", "#cloud-boothook", "#part-handler", "Content-Type:"}
+
+// validateUserData checks that user data is a format cloud-init accepts and
+// that a cloud-config document is valid YAML
+func validateUserData(raw []byte) error {
+	content := strings.TrimPrefix(string(raw), "\ufeff")
+	firstLine := strings.SplitN(content, "\n", 2)[0]
+
+	header := ""
+	for _, h := range userDataHeaders {
+		if strings.HasPrefix(firstLine, h) {
+			header = h
+			break
+		}
+	}
+	if len(header) == 0 {
+		return errors.New("user data must start with #cloud-config or another cloud-init header")
+	}
+	if header != "#cloud-config" {
+		return nil
+	}
+
+	config := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(content), &config); err != nil {
+		return fmt.Errorf("invalid cloud-config: %v", err)
+	}
+
+	return nil
+}
+
+// validateMetaData checks that meta data is a YAML or JSON mapping
+func validateMetaData(raw []byte) error {
+	meta := map[string]interface{}{}
+	if err := yaml.Unmarshal(raw, &meta); err != nil {
+		return fmt.Errorf("invalid meta data: %v", err)
+	}
+	return nil
+}
+
+// validateUnattendXML checks that an unattend file is well formed XML with an
+// unattend root element
+func validateUnattendXML(raw []byte) error {
+	dec := xml.NewDecoder(bytes.NewReader(raw))
+	root := ""
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("invalid unattend XML: %v", err)
+		}
+		if start, ok := tok.(xml.StartElement); ok && len(root) == 0 {
+			root = start.Name.Local
+		}
+	}
+
+	if root != "unattend" {
+		return errors.New("invalid unattend XML: root element must be unattend")
+	}
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/base64"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/routebyintuition/ntnx-go-sdk/pc"
+)
+
+const testUserData = `#cloud-config
+hostname: app-01
+users:
+  - name: centos
+    groups: wheel
+`
+
+const testUnattendXML = `<?xml version="1.0" encoding="utf-8"?>
+<unattend xmlns="urn:schemas-microsoft-com:unattend">
+  <settings pass="oobeSystem"/>
+</unattend>
+`
+
+func Test_validateUserData(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr bool
+	}{
+		{name: "cloud-config", raw: testUserData},
+		{name: "shell script", raw: "#!/bin/bash\necho hello\n"},
+		{name: "byte order mark", raw: "\ufeff" + testUserData},
+		{name: "mime multipart", raw: "Content-Type: multipart/mixed; boundary=\"==B==\"\n"},
+		{name: "missing header", raw: "hostname: app-01\n", wantErr: true},
+		{name: "invalid yaml", raw: "#cloud-config\nusers: [\n", wantErr: true},
+		{name: "not a mapping", raw: "#cloud-config\n- a\n- b\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateUserData([]byte(tt.raw)); (err != nil) != tt.wantErr {
+				t.Errorf("validateUserData() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_validateUnattendXML(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr bool
+	}{
+		{name: "unattend", raw: testUnattendXML},
+		{name: "wrong root", raw: "<settings/>", wantErr: true},
+		{name: "malformed", raw: "<unattend><settings></unattend>", wantErr: true},
+		{name: "empty", raw: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateUnattendXML([]byte(tt.raw)); (err != nil) != tt.wantErr {
+				t.Errorf("validateUnattendXML() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_specGuestFiles(t *testing.T) {
+	raw := []byte(`
+spec:
+  resources:
+    guest_customization:
+      cloud_init:
+        user_data_file: cloud/user-data.yaml
+        meta_data_file: /etc/meta.yaml
+`)
+
+	got, err := specGuestFiles(raw, "specs")
+	if err != nil {
+		t.Fatalf("specGuestFiles() error = %v", err)
+	}
+	want := guestFiles{UserData: filepath.Join("specs", "cloud/user-data.yaml"), MetaData: "/etc/meta.yaml"}
+	if got != want {
+		t.Errorf("specGuestFiles() = %v, want %v", got, want)
+	}
+
+	merged := got.merge(guestFiles{UserData: "flag.yaml"})
+	if merged.UserData != "flag.yaml" || merged.MetaData != "/etc/meta.yaml" {
+		t.Errorf("guestFiles.merge() = %v", merged)
+	}
+}
+
+func Test_applyGuestFiles(t *testing.T) {
+	userData := writeTestFile(t, "user-data.yaml", testUserData)
+	metaData := writeTestFile(t, "meta.yaml", "instance-id: app-01\n")
+	unattend := writeTestFile(t, "unattend.xml", testUnattendXML)
+	badUserData := writeTestFile(t, "bad.yaml", "hostname: app-01\n")
+
+	tests := []struct {
+		name         string
+		files        guestFiles
+		wantUserData string
+		wantMetaData string
+		wantUnattend string
+		wantErr      string
+	}{
+		{name: "nothing to apply", files: guestFiles{}},
+		{name: "cloud-init", files: guestFiles{UserData: userData, MetaData: metaData}, wantUserData: testUserData, wantMetaData: "instance-id: app-01\n"},
+		{name: "sysprep", files: guestFiles{UnattendXML: unattend}, wantUnattend: testUnattendXML},
+		{name: "both", files: guestFiles{UserData: userData, UnattendXML: unattend}, wantErr: "cannot both be used"},
+		{name: "invalid user data", files: guestFiles{UserData: badUserData}, wantErr: "bad.yaml: user data must start with"},
+		{name: "missing file", files: guestFiles{UserData: "missing.yaml"}, wantErr: "missing.yaml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &pc.VMCreateRequest{}
+
+			err := applyGuestFiles(req, tt.files)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("applyGuestFiles() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyGuestFiles() error = %v", err)
+			}
+
+			gc := &pc.GuestCustomization{}
+			if req.Spec.Resources != nil && req.Spec.Resources.GuestCustomization != nil {
+				gc = req.Spec.Resources.GuestCustomization
+			}
+			decode := func(s string) string {
+				out, _ := base64.StdEncoding.DecodeString(s)
+				return string(out)
+			}
+			if gc.CloudInit != nil {
+				if got := decode(gc.CloudInit.UserData); got != tt.wantUserData {
+					t.Errorf("user_data = %q, want %q", got, tt.wantUserData)
+				}
+				if got := decode(gc.CloudInit.MetaData); got != tt.wantMetaData {
+					t.Errorf("meta_data = %q, want %q", got, tt.wantMetaData)
+				}
+			} else if tt.wantUserData != "" {
+				t.Errorf("cloud_init was not set")
+			}
+			if gc.Sysprep != nil {
+				if got := decode(gc.Sysprep.UnattendXML); got != tt.wantUnattend {
+					t.Errorf("unattend_xml = %q, want %q", got, tt.wantUnattend)
+				}
+				if gc.Sysprep.InstallType != "PREPARED" {
+					t.Errorf("install_type = %q, want PREPARED", gc.Sysprep.InstallType)
+				}
+			} else if tt.wantUnattend != "" {
+				t.Errorf("sysprep was not set")
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/routebyintuition/ntnx-go-sdk/pe"
+	"github.com/urfave/cli/v2"
+)
+
+// host is a Prism Element v2 host. The SDK has no host type.
+type host struct {
+	UUID                  string            `json:"uuid"`
+	Name                  string            `json:"name"`
+	HypervisorAddress     string            `json:"hypervisor_address"`
+	HypervisorFullName    string            `json:"hypervisor_full_name"`
+	CPUModel              string            `json:"cpu_model"`
+	NumCPUSockets         int               `json:"num_cpu_sockets"`
+	NumCPUCores           int               `json:"num_cpu_cores"`
+	MemoryCapacityInBytes int64             `json:"memory_capacity_in_bytes"`
+	NumVMs                int               `json:"num_vms"`
+	State                 string            `json:"state"`
+	HostInMaintenanceMode bool              `json:"host_in_maintenance_mode"`
+	Stats                 map[string]string `json:"stats"`
+}
+
+// hostList is the v2 host list response
+type hostList struct {
+	Metadata struct {
+		TotalEntities int `json:"total_entities"`
+	} `json:"metadata"`
+	Entities []host `json:"entities"`
+}
+
+// memoryMib returns the memory capacity of the host
+func (h host) memoryMib() int {
+	return int(h.MemoryCapacityInBytes / (1 << 20))
+}
+
+// freeMemoryMib returns the memory not used by the hypervisor and its VMs,
+// from the usage reported in parts per million
+func (h host) freeMemoryMib() int {
+	ppm, err := strconv.ParseInt(h.Stats["hypervisor_memory_usage_ppm"], 10, 64)
+	if err != nil {
+		return h.memoryMib()
+	}
+	return int(int64(h.memoryMib()) * (1000000 - ppm) / 1000000)
+}
+
+// listAllHosts pages through the Prism Element hosts
+func (n *NCLI) listAllHosts() ([]host, error) {
+	pages, err := fetchPages(defaultPageSize, 0, func(offset, length int) (interface{}, int, error) {
+		relURL := fmt.Sprintf("hosts?page=%d&count=%d", offset/length+1, length)
+		req, err := n.con.PE.NewRequest("GET", relURL, nil)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		listRes := new(hostList)
+		if _, err := n.con.PE.Do(req, listRes); err != nil {
+			return nil, 0, err
+		}
+
+		return listRes.Entities, listRes.Metadata.TotalEntities, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := []host{}
+	for _, page := range pages {
+		items, _ := page.([]host)
+		hosts = append(hosts, items...)
+	}
+
+	return hosts, nil
+}
+
+// maintenance reports the maintenance mode of the host as yes or no
+func (h host) maintenance() string {
+	if h.HostInMaintenanceMode {
+		return "yes"
+	}
+	return "no"
+}
+
+// getHost retrieves one Prism Element host
+func (n *NCLI) getHost(hostUUID string) (*host, error) {
+	req, err := n.con.PE.NewRequest("GET", "hosts/"+hostUUID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	h := new(host)
+	if _, err := n.con.PE.Do(req, h); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// resolveHost resolves the host argument of a host command
+func (n *NCLI) resolveHost(c *cli.Context) (*host, error) {
+	if c.Args().Len() == 0 {
+		return nil, errors.New("no host provided. <host name|UUID>")
+	}
+
+	hostUUID, err := n.resolveUUID(kindHost, c.Args().First())
+	if err != nil {
+		return nil, err
+	}
+
+	return n.getHost(hostUUID)
+}
+
+// hostList lists the hosts of the Prism Element cluster
+func (n *NCLI) hostList(c *cli.Context) error {
+	hosts, err := n.listAllHosts()
+	if err != nil {
+		return err
+	}
+
+	sort.SliceStable(hosts, func(i, j int) bool { return hosts[i].Name < hosts[j].Name })
+
+	data := [][]string{}
+	for _, h := range hosts {
+		data = append(data, []string{h.Name, h.UUID, h.HypervisorAddress, h.HypervisorFullName, h.CPUModel, strconv.Itoa(h.NumCPUCores), fmt.Sprintf("%d MiB", h.memoryMib()), strconv.Itoa(h.NumVMs), h.maintenance()})
+	}
+
+	return n.render(c, &Result{
+		Header: []string{"Name", "UUID", "Hypervisor IP", "Hypervisor", "CPU Model", "Cores", "Memory", "VMs", "Maintenance"},
+		Footer: []string{"", "", "", "", "", "", "", "TOTAL", strconv.Itoa(len(hosts))},
+		Rows:   data,
+		Entity: hosts,
+	})
+}
+
+// hostGet returns the details of a host
+func (n *NCLI) hostGet(c *cli.Context) error {
+	h, err := n.resolveHost(c)
+	if err != nil {
+		return err
+	}
+
+	data := [][]string{
+		{"Name", h.Name},
+		{"UUID", h.UUID},
+		{"Hypervisor IP", h.HypervisorAddress},
+		{"Hypervisor", h.HypervisorFullName},
+		{"CPU Model", h.CPUModel},
+		{"CPU Sockets", strconv.Itoa(h.NumCPUSockets)},
+		{"CPU Cores", strconv.Itoa(h.NumCPUCores)},
+		{"Memory", fmt.Sprintf("%d MiB", h.memoryMib())},
+		{"Free Memory", fmt.Sprintf("%d MiB", h.freeMemoryMib())},
+		{"VMs", strconv.Itoa(h.NumVMs)},
+		{"State", h.State},
+		{"Maintenance", h.maintenance()},
+	}
+
+	return n.render(c, &Result{
+		Header: []string{"Attribute", "Value"},
+		Rows:   data,
+		Entity: h,
+		NoWrap: true,
+	})
+}
+
+// migrationCandidates returns the hosts a VM can move to, with the most free
+// memory first. The current host, hosts in maintenance and hosts without
+// enough free memory are left out.
+func migrationCandidates(hosts []host, currentHost string, memoryMib int) []host {
+	candidates := []host{}
+	for _, h := range hosts {
+		if h.UUID == currentHost || h.HostInMaintenanceMode || h.freeMemoryMib() < memoryMib {
+			continue
+		}
+		candidates = append(candidates, h)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].freeMemoryMib() > candidates[j].freeMemoryMib()
+	})
+
+	return candidates
+}
+
+// vmMigrate moves a running VM to another host and waits for the migration.
+// Without --host the hosts able to take the VM are listed instead.
+func (n *NCLI) vmMigrate(c *cli.Context) error {
+	vmUUID, err := n.resolveUUID(kindVM, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	vm, _, err := n.con.PE.VM.Get(&pe.VMGetRequest{Params: &pe.VMGetRequestParams{UUID: vmUUID}, Query: &pe.VMGetRequestQuery{}})
+	if err != nil {
+		return err
+	}
+	name := stringValue(vm.Name)
+
+	if stringValue(vm.PowerState) != "on" {
+		return fmt.Errorf("vm %s is not running. only running VMs are placed on a host", name)
+	}
+	if c.Bool("live") && vm.AllowLiveMigrate != nil && !*vm.AllowLiveMigrate {
+		return fmt.Errorf("vm %s does not allow live migration", name)
+	}
+
+	memoryMib := 0
+	if vm.MemoryMb != nil {
+		memoryMib = *vm.MemoryMb
+	}
+	currentHost := stringValue(vm.HostUUID)
+
+	hosts, err := n.listAllHosts()
+	if err != nil {
+		return err
+	}
+
+	if len(c.String("host")) == 0 {
+		candidates := migrationCandidates(hosts, currentHost, memoryMib)
+		if len(candidates) == 0 {
+			return fmt.Errorf("no other host has %d MiB free for vm %s", memoryMib, name)
+		}
+
+		data := [][]string{}
+		for _, h := range candidates {
+			data = append(data, []string{h.Name, h.UUID, h.HypervisorAddress, strconv.Itoa(h.NumVMs), fmt.Sprintf("%d MiB", h.memoryMib()), fmt.Sprintf("%d MiB", h.freeMemoryMib())})
+		}
+
+		fmt.Fprintf(n.out, "hosts able to take vm %s (%d MiB). pick one with --host\n", name, memoryMib)
+		return n.render(c, &Result{
+			Header: []string{"Name", "UUID", "Hypervisor IP", "VMs", "Memory", "Free Memory"},
+			Rows:   data,
+			Entity: candidates,
+		})
+	}
+
+	hostUUID, err := n.resolveUUID(kindHost, c.String("host"))
+	if err != nil {
+		return err
+	}
+	if hostUUID == currentHost {
+		return fmt.Errorf("vm %s is already running on host %s", name, c.String("host"))
+	}
+
+	var target *host
+	for i := range hosts {
+		if hosts[i].UUID == hostUUID {
+			target = &hosts[i]
+		}
+	}
+	if target == nil {
+		return fmt.Errorf("host %s not found", c.String("host"))
+	}
+	if target.HostInMaintenanceMode {
+		return fmt.Errorf("host %s is in maintenance mode", target.Name)
+	}
+	if target.freeMemoryMib() < memoryMib {
+		return fmt.Errorf("host %s has %d MiB free and vm %s needs %d MiB", target.Name, target.freeMemoryMib(), name, memoryMib)
+	}
+
+	if err := n.migrateVM(c, vmUUID, name, *target, c.Bool("live")); err != errDryRun {
+		return err
+	}
+
+	return nil
+}
+
+// migrateVM moves a VM to a host and waits for the migration task. A
+// migration is always followed to the end so hosts can be drained in turn.
+func (n *NCLI) migrateVM(c *cli.Context, vmUUID, name string, target host, live bool) error {
+	body := map[string]interface{}{"host_uuid": target.UUID, "live": live}
+
+	taskUUID, err := n.peTaskRequest("POST", "vms/"+vmUUID+"/migrate", body)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(n.out, "vm %s migration to host %s submitted\n", name, target.Name)
+	fmt.Fprintf(n.out, "task: %s\n", taskUUID)
+
+	task, err := waitForTask(n.getPETask, taskUUID, c.Duration("timeout"))
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(n.out, "task", task.UUID, strings.ToLower(task.Status))
+
+	return nil
+}
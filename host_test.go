@@ -0,0 +1,123 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	nutanix "github.com/routebyintuition/ntnx-go-sdk"
+	"github.com/routebyintuition/ntnx-go-sdk/pe"
+)
+
+func Test_host_freeMemoryMib(t *testing.T) {
+	tests := []struct {
+		name string
+		h    host
+		want int
+	}{
+		{name: "usage", h: host{MemoryCapacityInBytes: 1 << 30, Stats: map[string]string{"hypervisor_memory_usage_ppm": "250000"}}, want: 768},
+		{name: "full", h: host{MemoryCapacityInBytes: 1 << 30, Stats: map[string]string{"hypervisor_memory_usage_ppm": "1000000"}}, want: 0},
+		{name: "no stats", h: host{MemoryCapacityInBytes: 1 << 30}, want: 1024},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.h.freeMemoryMib(); got != tt.want {
+				t.Errorf("freeMemoryMib() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_migrationCandidates(t *testing.T) {
+	usage := func(ppm string) map[string]string {
+		return map[string]string{"hypervisor_memory_usage_ppm": ppm}
+	}
+	hosts := []host{
+		{UUID: "current", MemoryCapacityInBytes: 1 << 30, Stats: usage("0")},
+		{UUID: "busy", MemoryCapacityInBytes: 1 << 30, Stats: usage("900000")},
+		{UUID: "half", MemoryCapacityInBytes: 1 << 30, Stats: usage("500000")},
+		{UUID: "idle", MemoryCapacityInBytes: 1 << 30, Stats: usage("100000")},
+		{UUID: "maintenance", MemoryCapacityInBytes: 1 << 30, Stats: usage("0"), HostInMaintenanceMode: true},
+	}
+
+	tests := []struct {
+		name      string
+		memoryMib int
+		want      []string
+	}{
+		{name: "small VM", memoryMib: 64, want: []string{"idle", "half", "busy"}},
+		{name: "large VM", memoryMib: 600, want: []string{"idle"}},
+		{name: "too large", memoryMib: 2048, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, h := range migrationCandidates(hosts, "current", tt.memoryMib) {
+				got = append(got, h.UUID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("migrationCandidates() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_planEvacuation(t *testing.T) {
+	vm := func(uuid, hostUUID, power string, memoryMb int, live bool) pe.VMGetResponse {
+		return pe.VMGetResponse{UUID: nutanix.String(uuid), Name: nutanix.String(uuid), HostUUID: nutanix.String(hostUUID), PowerState: nutanix.String(power), MemoryMb: intPtr(memoryMb), AllowLiveMigrate: &live}
+	}
+	hosts := []host{
+		{UUID: "drain", MemoryCapacityInBytes: 1 << 30},
+		{UUID: "a", MemoryCapacityInBytes: 1 << 30, Stats: map[string]string{"hypervisor_memory_usage_ppm": "500000"}},
+		{UUID: "b", MemoryCapacityInBytes: 1 << 30, Stats: map[string]string{"hypervisor_memory_usage_ppm": "250000"}},
+	}
+
+	tests := []struct {
+		name    string
+		vms     []pe.VMGetResponse
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name: "spread by free memory",
+			vms: []pe.VMGetResponse{
+				vm("small", "drain", "on", 256, true),
+				vm("large", "drain", "on", 512, true),
+				vm("off", "drain", "off", 4096, true),
+				vm("elsewhere", "a", "on", 4096, true),
+			},
+			want: map[string]string{"large": "b", "small": "a"},
+		},
+		{
+			name:    "does not fit",
+			vms:     []pe.VMGetResponse{vm("large", "drain", "on", 600, true), vm("second", "drain", "on", 520, true)},
+			wantErr: "no other host has 520 MiB free for vm second",
+		},
+		{
+			name:    "no live migration",
+			vms:     []pe.VMGetResponse{vm("pinned", "drain", "on", 256, false)},
+			wantErr: "does not allow live migration",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := planEvacuation(tt.vms, hosts, "drain")
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("planEvacuation() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("planEvacuation() error = %v", err)
+			}
+			got := map[string]string{}
+			for _, e := range plan {
+				got[e.vmUUID] = e.target.UUID
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("planEvacuation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
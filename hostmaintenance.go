@@ -0,0 +1,227 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/routebyintuition/ntnx-go-sdk/pe"
+	"github.com/urfave/cli/v2"
+)
+
+// evacuation is the planned move of one running VM off a host
+type evacuation struct {
+	vmUUID    string
+	name      string
+	memoryMib int
+	target    host
+}
+
+// listAllPEVMs pages through the Prism Element VMs. The SDK only gets VMs
+// one at a time.
+func (n *NCLI) listAllPEVMs() ([]pe.VMGetResponse, error) {
+	pages, err := fetchPages(defaultPageSize, 0, func(offset, length int) (interface{}, int, error) {
+		relURL := fmt.Sprintf("vms?page=%d&count=%d", offset/length+1, length)
+		req, err := n.con.PE.NewRequest("GET", relURL, nil)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var listRes struct {
+			Metadata struct {
+				TotalEntities int `json:"total_entities"`
+			} `json:"metadata"`
+			Entities []pe.VMGetResponse `json:"entities"`
+		}
+		if _, err := n.con.PE.Do(req, &listRes); err != nil {
+			return nil, 0, err
+		}
+
+		return listRes.Entities, listRes.Metadata.TotalEntities, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	vms := []pe.VMGetResponse{}
+	for _, page := range pages {
+		items, _ := page.([]pe.VMGetResponse)
+		vms = append(vms, items...)
+	}
+
+	return vms, nil
+}
+
+// planEvacuation places every running VM of a host on the other hosts,
+// largest VM first on the host with the most free memory left. VMs that do
+// not allow live migration or do not fit anywhere fail the whole plan.
+func planEvacuation(vms []pe.VMGetResponse, hosts []host, hostUUID string) ([]evacuation, error) {
+	plan := []evacuation{}
+	for _, vm := range vms {
+		if stringValue(vm.HostUUID) != hostUUID || stringValue(vm.PowerState) != "on" {
+			continue
+		}
+		if vm.AllowLiveMigrate != nil && !*vm.AllowLiveMigrate {
+			return nil, fmt.Errorf("vm %s does not allow live migration. power it off first", stringValue(vm.Name))
+		}
+		memoryMib := 0
+		if vm.MemoryMb != nil {
+			memoryMib = *vm.MemoryMb
+		}
+		plan = append(plan, evacuation{vmUUID: stringValue(vm.UUID), name: stringValue(vm.Name), memoryMib: memoryMib})
+	}
+
+	sort.SliceStable(plan, func(i, j int) bool { return plan[i].memoryMib > plan[j].memoryMib })
+
+	free := map[string]int{}
+	for _, h := range hosts {
+		free[h.UUID] = h.freeMemoryMib()
+	}
+
+	for i := range plan {
+		candidates := migrationCandidates(hosts, hostUUID, plan[i].memoryMib)
+		sort.SliceStable(candidates, func(a, b int) bool { return free[candidates[a].UUID] > free[candidates[b].UUID] })
+		if len(candidates) == 0 || free[candidates[0].UUID] < plan[i].memoryMib {
+			return nil, fmt.Errorf("no other host has %d MiB free for vm %s", plan[i].memoryMib, plan[i].name)
+		}
+		plan[i].target = candidates[0]
+		free[candidates[0].UUID] -= plan[i].memoryMib
+	}
+
+	return plan, nil
+}
+
+// waitForHost polls a host until its maintenance mode matches inMaintenance
+// or the timeout expires
+func (n *NCLI) waitForHost(hostUUID string, inMaintenance bool, timeout time.Duration) (*host, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		h, err := n.getHost(hostUUID)
+		if err != nil {
+			return nil, err
+		}
+
+		if h.HostInMaintenanceMode == inMaintenance {
+			return h, nil
+		}
+
+		if time.Now().Add(taskPollInterval).After(deadline) {
+			return h, fmt.Errorf("timed out after %v waiting for host %s (%s)", timeout, h.Name, h.State)
+		}
+
+		time.Sleep(taskPollInterval)
+	}
+}
+
+// hostMaintenanceEnter live migrates the running VMs off a host, puts it in
+// maintenance mode and waits until the host reports it
+func (n *NCLI) hostMaintenanceEnter(c *cli.Context) error {
+	h, err := n.resolveHost(c)
+	if err != nil {
+		return err
+	}
+	if h.HostInMaintenanceMode {
+		return fmt.Errorf("host %s is already in maintenance mode", h.Name)
+	}
+
+	vms, err := n.listAllPEVMs()
+	if err != nil {
+		return err
+	}
+	hosts, err := n.listAllHosts()
+	if err != nil {
+		return err
+	}
+
+	plan, err := planEvacuation(vms, hosts, h.UUID)
+	if err != nil {
+		return err
+	}
+
+	if len(plan) > 0 {
+		data := [][]string{}
+		for _, e := range plan {
+			data = append(data, []string{e.name, e.vmUUID, fmt.Sprintf("%d MiB", e.memoryMib), e.target.Name})
+		}
+		err := n.render(c, &Result{
+			Header: []string{"VM", "UUID", "Memory", "Target Host"},
+			Rows:   data,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if !c.Bool("force") && !n.dryRun {
+		ok, err := n.confirm(fmt.Sprintf("put host %s in maintenance mode and migrate %d VMs off it?", h.Name, len(plan)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("maintenance cancelled")
+		}
+	}
+
+	for _, e := range plan {
+		if err := n.migrateVM(c, e.vmUUID, e.name, e.target, true); err != nil && err != errDryRun {
+			return fmt.Errorf("migrating vm %s off host %s: %v", e.name, h.Name, err)
+		}
+	}
+
+	taskUUID, err := n.peTaskRequest("POST", "hosts/"+h.UUID+"/enter_maintenance_mode", map[string]string{"uuid": h.UUID})
+	if err == errDryRun {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return n.reportHostMaintenance(c, h, taskUUID, true)
+}
+
+// hostMaintenanceExit takes a host out of maintenance mode and waits until
+// it reports ready. VMs are not moved back.
+func (n *NCLI) hostMaintenanceExit(c *cli.Context) error {
+	h, err := n.resolveHost(c)
+	if err != nil {
+		return err
+	}
+	if !h.HostInMaintenanceMode {
+		return fmt.Errorf("host %s is not in maintenance mode", h.Name)
+	}
+
+	taskUUID, err := n.peTaskRequest("POST", "hosts/"+h.UUID+"/exit_maintenance_mode", map[string]string{"uuid": h.UUID})
+	if err == errDryRun {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return n.reportHostMaintenance(c, h, taskUUID, false)
+}
+
+// reportHostMaintenance waits for a maintenance mode task and then for the
+// host to report the new mode
+func (n *NCLI) reportHostMaintenance(c *cli.Context, h *host, taskUUID string, inMaintenance bool) error {
+	fmt.Fprintf(n.out, "task: %s\n", taskUUID)
+
+	if _, err := waitForTask(n.getPETask, taskUUID, c.Duration("timeout")); err != nil {
+		return err
+	}
+
+	h, err := n.waitForHost(h.UUID, inMaintenance, c.Duration("timeout"))
+	if err != nil {
+		return err
+	}
+
+	state := "ready"
+	if inMaintenance {
+		state = "in maintenance mode"
+	}
+	fmt.Fprintf(n.out, "host %s is %s with %d VMs\n", h.Name, state, h.NumVMs)
+
+	return nil
+}
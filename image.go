@@ -1,7 +1,6 @@
 package main
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"strconv"
@@ -12,21 +11,14 @@ import (
 
 func (n *NCLI) imageList(c *cli.Context) error {
 
-	ListRequest := new(pc.ImageListRequest)
-	ListRequest.Length = 1000
-
-	getRes, _, err := n.con.PC.Image.List(ListRequest)
+	imageListLoop, err := n.listAllImages()
 	if err != nil {
 		return err
 	}
 
-	n.tr.SetHeader([]string{"Name", "Type", "UUID", "Status", "Size", "Source"})
-
-	n.tr.SetFooter([]string{"", "", "", "", "Total", strconv.Itoa(len(getRes.Entities))})
-
 	data := [][]string{}
 
-	for _, entityValue := range getRes.Entities {
+	for _, entityValue := range imageListLoop {
 		var eType, eName string
 
 		if entityValue.Spec.Name != nil {
@@ -45,10 +37,13 @@ func (n *NCLI) imageList(c *cli.Context) error {
 
 		data = append(data, []string{eName, eType, eUUID, eState, strconv.Itoa(*entityValue.Status.Resources.SizeBytes), *entityValue.Status.Resources.SourceURI})
 	}
-	n.tr.AppendBulk(data)
-	n.tr.Render()
 
-	return nil
+	return n.render(c, &Result{
+		Header: []string{"Name", "Type", "UUID", "Status", "Size", "Source"},
+		Footer: []string{"", "", "", "", "Total", strconv.Itoa(len(imageListLoop))},
+		Rows:   data,
+		Entity: imageListLoop,
+	})
 }
 
 func (n *NCLI) imageCreate(c *cli.Context) error {
@@ -95,55 +90,46 @@ func (n *NCLI) imageCreate(c *cli.Context) error {
 	CreateRequest.Spec.Resources = &res
 	CreateRequest.Metadata = &meta
 
+	if n.dryRun {
+		if err := n.dryRunRequest("POST", "images", nil, &CreateRequest); err != errDryRun {
+			return err
+		}
+		return nil
+	}
+
 	getRes, _, err := n.con.PC.Image.Create(&CreateRequest)
 	if err != nil {
 		return err
 	}
 
-	n.tr.SetHeader([]string{"Name", "UUID", "Description", "Status"})
+	state := getRes.Status.State
+	taskUUID := executionTask(getRes.Status)
 
-	out, _ := json.MarshalIndent(getRes, "", "  ")
-	fmt.Println("cluster list result: ", string(out))
+	task, err := n.waitIfRequested(c, n.getPCTask, taskUUID)
+	if err != nil {
+		return err
+	}
+	if task != nil {
+		state = task.Status
+	}
 
 	data := [][]string{}
-	data = append(data, []string{*getRes.Spec.Name, *getRes.Metadata.UUID, *getRes.Spec.Description, getRes.Status.State})
-	n.tr.AppendBulk(data)
-	n.tr.Render()
+	data = append(data, []string{*getRes.Spec.Name, *getRes.Metadata.UUID, *getRes.Spec.Description, state, taskUUID})
 
-	return nil
+	return n.render(c, &Result{
+		Header: []string{"Name", "UUID", "Description", "Status", "Task"},
+		Rows:   data,
+		Entity: getRes,
+	})
 }
 
 // GetImageUUIDList returns a string slice containing all image UUIDs
 func (n *NCLI) GetImageUUIDList() ([]string, error) {
-
-	ListRequest := new(pc.ImageListRequest)
-	ListRequest.Length = 40
-
 	data := []string{}
 
-	var listLoop []pc.Entities
-	totalMatches := 0
-	offset := 0
-	currentMatches := -1
-	var err error
-	getRes := &pc.ImageListResponse{}
-
-	for totalMatches > currentMatches {
-		if currentMatches == -1 {
-			currentMatches = 0
-		}
-
-		ListRequest.Offset = offset
-
-		getRes, _, err = n.con.PC.Image.List(ListRequest)
-		if err != nil {
-			return nil, err
-		}
-
-		currentMatches += *getRes.Metadata.Length
-		totalMatches = *getRes.Metadata.TotalMatches
-		offset += ListRequest.Length
-		listLoop = append(listLoop, getRes.Entities...)
+	listLoop, err := n.listAllImages()
+	if err != nil {
+		return nil, err
 	}
 
 	for _, entityValue := range listLoop {
@@ -154,3 +140,17 @@ func (n *NCLI) GetImageUUIDList() ([]string, error) {
 
 	return data, nil
 }
+
+// listAllImages pages through the image list API and returns every image
+func (n *NCLI) listAllImages() ([]pc.Entities, error) {
+	return fetchPCEntities(0, func(offset, length int) ([]pc.Entities, int, error) {
+		ListRequest := &pc.ImageListRequest{Offset: offset, Length: length}
+
+		getRes, _, err := n.con.PC.Image.List(ListRequest)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return getRes.Entities, *getRes.Metadata.TotalMatches, nil
+	})
+}
@@ -17,17 +17,16 @@ func (n *NCLI) krbnListClusters(c *cli.Context) error {
 	}
 	clusterCount := len(*getRes)
 
-	n.tr.SetHeader([]string{"Name", "UUID", "Address", "Version"})
-
-	n.tr.SetFooter([]string{"", "", "Total", strconv.Itoa(clusterCount)})
-
 	data := [][]string{}
 
 	for _, entityValue := range *getRes {
 		data = append(data, []string{entityValue.Name, entityValue.UUID, entityValue.KubeapiServerIpv4Address, entityValue.Version})
 	}
-	n.tr.AppendBulk(data)
-	n.tr.Render()
 
-	return nil
+	return n.render(c, &Result{
+		Header: []string{"Name", "UUID", "Address", "Version"},
+		Footer: []string{"", "", "Total", strconv.Itoa(clusterCount)},
+		Rows:   data,
+		Entity: getRes,
+	})
 }
@@ -152,6 +152,42 @@ func GetMibFromMB(mb int) int {
 	return int(math.Floor(float64(mb) * 0.95367431640625))
 }
 
+// sizeUnits are the bytes in each unit accepted by ParseMibSize
+var sizeUnits = map[string]float64{
+	"mib": 1 << 20, "mi": 1 << 20,
+	"gib": 1 << 30, "gi": 1 << 30,
+	"tib": 1 << 40, "ti": 1 << 40,
+	"mb": 1e6, "m": 1e6,
+	"gb": 1e9, "g": 1e9,
+	"tb": 1e12, "t": 1e12,
+}
+
+// ParseMibSize converts a size such as 8GiB, 8GB, 1.5GiB or 512MiB to MiB. A
+// number without a unit is read in defaultUnit.
+func ParseMibSize(size, defaultUnit string) (int, error) {
+	size = strings.TrimSpace(size)
+
+	i := strings.IndexFunc(size, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	number, unit := size, defaultUnit
+	if i >= 0 {
+		number, unit = size[:i], strings.TrimSpace(size[i:])
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q. use a number with MiB, GiB, MB or GB", size)
+	}
+
+	bytes, ok := sizeUnits[strings.ToLower(unit)]
+	if !ok {
+		return 0, fmt.Errorf("invalid size unit %q. use MiB, GiB, TiB, MB, GB or TB", unit)
+	}
+
+	return int(math.Floor(value * bytes / (1 << 20))), nil
+}
+
 // defaultInputSource creates a default InputSourceContext.
 func defaultInputSource() (altsrc.InputSourceContext, error) {
 	return &altsrc.MapInputSource{}, nil
@@ -233,6 +269,19 @@ func isInputFromPipe() bool {
 	return fileInfo.Mode()&os.ModeCharDevice == 0
 }
 
+// isTerminalWriter reports whether the writer is an interactive terminal
+func isTerminalWriter(w io.Writer) bool {
+	fh, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fileInfo, err := fh.Stat()
+	if err != nil {
+		return false
+	}
+	return fileInfo.Mode()&os.ModeCharDevice != 0
+}
+
 func processYAMLReader(r io.Reader, i interface{}) (interface{}, error) {
 	err := yaml.NewDecoder(r).Decode(i)
 	if err != nil {
@@ -251,46 +300,157 @@ func isDirectory(path string) (bool, error) {
 	return fileInfo.IsDir(), err
 }
 
-// ValidYAMLCreate validates a create VM YAML file
+// VM create limits enforced before a create request is sent
+const (
+	vmMinNameLength = 3
+	vmMaxNameLength = 80
+	vmMaxVCPUs      = 256
+	vmMinMemoryMib  = 256
+	vmMaxMemoryMib  = 4194304
+)
+
+// vmInventory holds the UUIDs that a VM create request may reference
+type vmInventory struct {
+	subnets  []string
+	images   []string
+	clusters []string
+}
+
+// ValidYAMLCreate validates a create VM YAML file against the subnets, images
+// and clusters known to Prism Central. Every violation is reported together.
 func (n *NCLI) ValidYAMLCreate(pcc *pc.VMCreateRequest) error {
-	if len(*pcc.Spec.Name) < 3 {
-		return errors.New("invalid length of VM name")
+	inv, err := n.vmInventory()
+	if err != nil {
+		return err
 	}
 
-	subnetExists := false
+	violations := validateVMCreate(pcc, inv)
+	if len(violations) > 0 {
+		return fmt.Errorf("invalid VM create request:\n  - %s", strings.Join(violations, "\n  - "))
+	}
+
+	return nil
+}
+
+// vmInventory lists the subnets, images and clusters a VM may reference
+func (n *NCLI) vmInventory() (vmInventory, error) {
 	subnetList, err := n.getSubnetUUIDList()
+	if err != nil {
+		return vmInventory{}, err
+	}
 
+	imageList, err := n.GetImageUUIDList()
 	if err != nil {
-		return err
+		return vmInventory{}, err
 	}
-	for _, v := range *pcc.Spec.Resources.NicList {
-		if stringSliceContains(subnetList, v.SubnetReference.UUID) {
-			subnetExists = true
+
+	clusterList, err := n.listAllClusters()
+	if err != nil {
+		return vmInventory{}, err
+	}
+	clusters := []string{}
+	for _, cl := range clusterList {
+		if cl.Metadata.UUID != nil {
+			clusters = append(clusters, *cl.Metadata.UUID)
 		}
 	}
-	if !subnetExists {
-		return errors.New("subnet UUID not defined on cluster")
+
+	return vmInventory{subnets: subnetList, images: imageList, clusters: clusters}, nil
+}
+
+// validateVMCreate returns every problem found in a VM create request
+func validateVMCreate(pcc *pc.VMCreateRequest, inv vmInventory) []string {
+	if pcc == nil {
+		return []string{"request is empty"}
+	}
+
+	violations := []string{}
+	spec := pcc.Spec
+
+	if spec.Name == nil || len(*spec.Name) < vmMinNameLength || len(*spec.Name) > vmMaxNameLength {
+		violations = append(violations, fmt.Sprintf("spec.name must be between %d and %d characters", vmMinNameLength, vmMaxNameLength))
 	}
 
-	imageExists := false
-	imageList, err := n.GetImageUUIDList()
-	if err != nil {
-		return err
+	if spec.ClusterReference == nil || len(spec.ClusterReference.UUID) == 0 {
+		violations = append(violations, "spec.cluster_reference.uuid is missing")
+	} else if !stringSliceContains(inv.clusters, spec.ClusterReference.UUID) {
+		violations = append(violations, fmt.Sprintf("cluster %s does not exist", spec.ClusterReference.UUID))
 	}
-	for _, v := range *pcc.Spec.Resources.DiskList {
-		if v.DataSourceReference != nil {
-			if stringSliceContains(imageList, v.DataSourceReference.UUID) {
-				imageExists = true
+
+	res := spec.Resources
+	if res == nil {
+		return append(violations, "spec.resources is missing")
+	}
+
+	sockets, vcpus := 1, 1
+	if res.NumSockets != nil {
+		sockets = *res.NumSockets
+	}
+	if res.NumVcpusPerSocket != nil {
+		vcpus = *res.NumVcpusPerSocket
+	}
+	if sockets < 1 {
+		violations = append(violations, "spec.resources.num_sockets must be at least 1")
+	}
+	if vcpus < 1 {
+		violations = append(violations, "spec.resources.num_vcpus_per_socket must be at least 1")
+	}
+	if sockets*vcpus > vmMaxVCPUs {
+		violations = append(violations, fmt.Sprintf("total vCPUs %d exceeds the maximum of %d", sockets*vcpus, vmMaxVCPUs))
+	}
+
+	if res.MemorySizeMib == nil {
+		violations = append(violations, "spec.resources.memory_size_mib is missing")
+	} else if *res.MemorySizeMib < vmMinMemoryMib || *res.MemorySizeMib > vmMaxMemoryMib {
+		violations = append(violations, fmt.Sprintf("spec.resources.memory_size_mib must be between %d and %d", vmMinMemoryMib, vmMaxMemoryMib))
+	}
+
+	if res.NicList != nil {
+		for i, nic := range *res.NicList {
+			if len(nic.SubnetReference.UUID) == 0 {
+				violations = append(violations, fmt.Sprintf("nic_list[%d] has no subnet_reference.uuid", i))
+			} else if !stringSliceContains(inv.subnets, nic.SubnetReference.UUID) {
+				violations = append(violations, fmt.Sprintf("nic_list[%d] subnet %s does not exist", i, nic.SubnetReference.UUID))
 			}
-		} else {
-			imageExists = true
 		}
 	}
-	if !imageExists {
-		return errors.New("disk image UUID not defined on cluster")
+
+	diskAddresses := []string{}
+	if res.DiskList != nil {
+		for i, disk := range *res.DiskList {
+			if disk.DataSourceReference != nil && (disk.DataSourceReference.Kind == "" || disk.DataSourceReference.Kind == "image") {
+				if !stringSliceContains(inv.images, disk.DataSourceReference.UUID) {
+					violations = append(violations, fmt.Sprintf("disk_list[%d] image %s does not exist", i, disk.DataSourceReference.UUID))
+				}
+			}
+			if disk.DeviceProperties != nil && disk.DeviceProperties.DiskAddress != nil && disk.DeviceProperties.DiskAddress.DeviceIndex != nil {
+				diskAddresses = append(diskAddresses, diskAddressKey(disk.DeviceProperties.DiskAddress))
+			}
+		}
+	}
+
+	if res.BootConfig != nil && res.BootConfig.BootDevice != nil && res.BootConfig.BootDevice.DiskAddress != nil {
+		boot := res.BootConfig.BootDevice.DiskAddress
+		if boot.DeviceIndex == nil || !stringSliceContains(diskAddresses, diskAddressKey(boot)) {
+			violations = append(violations, fmt.Sprintf("boot device %s does not reference a disk in disk_list", diskAddressKey(boot)))
+		}
+	}
+
+	if res.GuestCustomization != nil && res.GuestCustomization.CloudInit != nil {
+		if !isBase64(res.GuestCustomization.CloudInit.UserData) {
+			violations = append(violations, "guest_customization.cloud_init.user_data is not valid base64")
+		}
+		if !isBase64(res.GuestCustomization.CloudInit.MetaData) {
+			violations = append(violations, "guest_customization.cloud_init.meta_data is not valid base64")
+		}
 	}
 
-	return errors.New("function not ready for prime time")
+	return violations
+}
+
+// diskAddressKey formats a disk address as adapter.index such as SCSI.0
+func diskAddressKey(da *pc.DiskAddress) string {
+	return strings.ToUpper(da.AdapterType) + "." + intValue(da.DeviceIndex)
 }
 
 // stringSliceContains checks whether a string slice contains a specific string
@@ -308,3 +468,19 @@ func isBase64(s string) bool {
 	_, err := base64.StdEncoding.DecodeString(s)
 	return err == nil
 }
+
+// stringValue returns the value of a string pointer or an empty string when nil
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// intValue returns the string form of an int pointer or an empty string when nil
+func intValue(i *int) string {
+	if i == nil {
+		return ""
+	}
+	return strconv.Itoa(*i)
+}
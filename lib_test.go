@@ -1,7 +1,10 @@
 package main
 
 import (
+	"strings"
 	"testing"
+
+	"github.com/routebyintuition/ntnx-go-sdk/pc"
 )
 
 type stubInputReader struct {
@@ -273,3 +276,134 @@ func Test_isDirectory(t *testing.T) {
 		})
 	}
 }
+
+func Test_validateVMCreate(t *testing.T) {
+	inv := vmInventory{
+		subnets:  []string{"subnet-1", "subnet-2"},
+		images:   []string{"image-1"},
+		clusters: []string{"cluster-1"},
+	}
+
+	index := func(i int) *int { return &i }
+	valid := func() *pc.VMCreateRequest {
+		name := "app-01"
+		sockets, vcpus, memory := 2, 1, 2048
+		return &pc.VMCreateRequest{Spec: pc.Spec{
+			Name:             &name,
+			ClusterReference: &pc.ClusterReference{Kind: "cluster", UUID: "cluster-1"},
+			Resources: &pc.Resources{
+				NumSockets:        &sockets,
+				NumVcpusPerSocket: &vcpus,
+				MemorySizeMib:     &memory,
+				NicList:           &[]pc.NicList{{SubnetReference: pc.SubnetReference{UUID: "subnet-1"}}, {SubnetReference: pc.SubnetReference{UUID: "subnet-2"}}},
+				DiskList: &[]pc.DiskList{{
+					DataSourceReference: &pc.DataSourceReference{Kind: "image", UUID: "image-1"},
+					DeviceProperties:    &pc.DeviceProperties{DeviceType: "DISK", DiskAddress: &pc.DiskAddress{AdapterType: "SCSI", DeviceIndex: index(0)}},
+				}},
+				BootConfig:         &pc.BootConfig{BootDevice: &pc.BootDevice{DiskAddress: &pc.DiskAddress{AdapterType: "scsi", DeviceIndex: index(0)}}},
+				GuestCustomization: &pc.GuestCustomization{CloudInit: &pc.CloudInit{UserData: "I2Nsb3VkLWNvbmZpZwo="}},
+			},
+		}}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(r *pc.VMCreateRequest)
+		want   []string
+	}{
+		{name: "valid", modify: func(r *pc.VMCreateRequest) {}},
+		{name: "short name", modify: func(r *pc.VMCreateRequest) { r.Spec.Name = nil }, want: []string{"spec.name"}},
+		{name: "missing cluster", modify: func(r *pc.VMCreateRequest) { r.Spec.ClusterReference = nil }, want: []string{"cluster_reference.uuid is missing"}},
+		{name: "unknown cluster", modify: func(r *pc.VMCreateRequest) { r.Spec.ClusterReference.UUID = "cluster-9" }, want: []string{"cluster cluster-9 does not exist"}},
+		{name: "missing resources", modify: func(r *pc.VMCreateRequest) { r.Spec.Resources = nil }, want: []string{"spec.resources is missing"}},
+		{name: "too many vcpus", modify: func(r *pc.VMCreateRequest) { *r.Spec.Resources.NumVcpusPerSocket = 200 }, want: []string{"total vCPUs 400"}},
+		{name: "zero sockets", modify: func(r *pc.VMCreateRequest) { *r.Spec.Resources.NumSockets = 0 }, want: []string{"num_sockets"}},
+		{name: "memory too small", modify: func(r *pc.VMCreateRequest) { *r.Spec.Resources.MemorySizeMib = 16 }, want: []string{"memory_size_mib must be between"}},
+		{name: "memory missing", modify: func(r *pc.VMCreateRequest) { r.Spec.Resources.MemorySizeMib = nil }, want: []string{"memory_size_mib is missing"}},
+		{
+			name: "every nic is checked",
+			modify: func(r *pc.VMCreateRequest) {
+				(*r.Spec.Resources.NicList)[1].SubnetReference.UUID = "subnet-9"
+			},
+			want: []string{"nic_list[1] subnet subnet-9 does not exist"},
+		},
+		{
+			name: "unknown image",
+			modify: func(r *pc.VMCreateRequest) {
+				(*r.Spec.Resources.DiskList)[0].DataSourceReference.UUID = "image-9"
+			},
+			want: []string{"disk_list[0] image image-9 does not exist"},
+		},
+		{
+			name: "boot device without disk",
+			modify: func(r *pc.VMCreateRequest) {
+				r.Spec.Resources.BootConfig.BootDevice.DiskAddress.DeviceIndex = index(3)
+			},
+			want: []string{"boot device SCSI.3"},
+		},
+		{
+			name: "invalid cloud-init",
+			modify: func(r *pc.VMCreateRequest) {
+				r.Spec.Resources.GuestCustomization.CloudInit.UserData = "#cloud-config"
+			},
+			want: []string{"user_data is not valid base64"},
+		},
+		{
+			name: "violations are collected",
+			modify: func(r *pc.VMCreateRequest) {
+				r.Spec.Name = nil
+				*r.Spec.Resources.MemorySizeMib = 16
+				(*r.Spec.Resources.NicList)[0].SubnetReference.UUID = ""
+			},
+			want: []string{"spec.name", "memory_size_mib", "nic_list[0] has no subnet_reference.uuid"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid()
+			tt.modify(req)
+
+			got := validateVMCreate(req, inv)
+			if len(got) != len(tt.want) {
+				t.Fatalf("validateVMCreate() = %v, want %d violations", got, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(got[i], want) {
+					t.Errorf("validateVMCreate()[%d] = %v, want %v", i, got[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseMibSize(t *testing.T) {
+	tests := []struct {
+		name        string
+		size        string
+		defaultUnit string
+		want        int
+		wantErr     bool
+	}{
+		{name: "GiB", size: "8GiB", defaultUnit: "MiB", want: 8192},
+		{name: "fractional GiB", size: "1.5GiB", defaultUnit: "MiB", want: 1536},
+		{name: "GB", size: "8GB", defaultUnit: "MiB", want: 7629},
+		{name: "MiB with space", size: "512 MiB", defaultUnit: "MiB", want: 512},
+		{name: "lower case", size: "2gi", defaultUnit: "MiB", want: 2048},
+		{name: "bare number in MiB", size: "4096", defaultUnit: "MiB", want: 4096},
+		{name: "bare number in MB", size: "8000", defaultUnit: "MB", want: GetMibFromMB(8000)},
+		{name: "TiB", size: "1TiB", defaultUnit: "MiB", want: 1048576},
+		{name: "unknown unit", size: "8PB", defaultUnit: "MiB", wantErr: true},
+		{name: "no number", size: "GiB", defaultUnit: "MiB", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMibSize(tt.size, tt.defaultUnit)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseMibSize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseMibSize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
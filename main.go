@@ -1,6 +1,7 @@
 package main
 
 import (
+	"io"
 	"log"
 	"os"
 
@@ -14,29 +15,50 @@ import (
 type NCLI struct {
 	con *nutanix.Client
 	tr  *tablewriter.Table
+	out io.Writer
+	in  InputReader
+	// dryRun prints mutating requests instead of sending them
+	dryRun bool
 }
 
 // BCLI (base CLI) is used for non-API calls but allows the table writer setup
 type BCLI struct {
-	tr *tablewriter.Table
+	tr  *tablewriter.Table
+	out io.Writer
 }
 
 func main() {
 
 	ncli := &NCLI{}
-	ncli.tr = tablewriter.NewWriter(os.Stdout)
+	ncli.out = os.Stdout
+	ncli.in = StdInputReader{}
+	ncli.tr = tablewriter.NewWriter(ncli.out)
 
 	bcli := &BCLI{}
-	bcli.tr = tablewriter.NewWriter(os.Stdout)
+	bcli.out = os.Stdout
+	bcli.tr = tablewriter.NewWriter(bcli.out)
+
+	app := newApp(ncli, bcli)
+
+	err := app.Run(os.Args)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
 
+// newApp builds the command tree using the provided API and base CLI handlers
+func newApp(ncli *NCLI, bcli *BCLI) *cli.App {
 	flags := getFlags()
 
-	app := &cli.App{
+	return &cli.App{
 		Name:                 "Unikum und Wunderbar Nutanix CLI",
 		Usage:                "uwncli [flags] [command] [subcommand]",
 		EnableBashCompletion: true,
-		Before:               altsrc.InitInputSourceWithContext(flags, NewYamlSourceFromProfileFunc("profile")),
-		Flags:                flags,
+		Before: func(c *cli.Context) error {
+			ncli.dryRun = c.Bool("dry-run")
+			return altsrc.InitInputSourceWithContext(flags, NewYamlSourceFromProfileFunc("profile"))(c)
+		},
+		Flags: flags,
 		Commands: []*cli.Command{
 			{
 				Name:    "configure",
@@ -83,45 +105,435 @@ func main() {
 				Subcommands: []*cli.Command{
 					{
 						Name:     "list",
-						Usage:    "retrieve all VMs",
+						Usage:    "retrieve all VMs [--filter <fiql>] [--cluster <name>] [--power-state <ON|OFF>] [--name-regex <regex>] [--sort-attribute <attribute>] [--sort-order <ASCENDING|DESCENDING>] [--limit <count>]",
 						Action:   ncli.vmList,
 						Category: "get",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "filter",
+								Usage: "v3 FIQL filter such as power_state==on;cluster_name==prod. ANDed with --cluster, --power-state and --name-regex, which cannot be combined with a , (OR) filter",
+							},
+							&cli.StringFlag{
+								Name:  "cluster",
+								Usage: "only VMs on the named cluster",
+							},
+							&cli.StringFlag{
+								Name:  "power-state",
+								Usage: "only VMs in the power state <ON|OFF>",
+							},
+							&cli.StringFlag{
+								Name:  "name-regex",
+								Usage: "only VMs with a name matching the regular expression",
+							},
+							&cli.StringFlag{
+								Name:  "sort-attribute",
+								Usage: "attribute to sort by such as vm_name or memory_size_mib",
+							},
+							&cli.StringFlag{
+								Name:  "sort-order",
+								Usage: "sort order <ASCENDING|DESCENDING>",
+							},
+							&cli.IntFlag{
+								Name:  "limit",
+								Usage: "maximum number of VMs to return",
+							},
+						},
 					},
 					{
 						Name:     "get",
-						Usage:    "<VM UUID>",
+						Usage:    "<VM name|UUID>",
 						Action:   ncli.vmGet,
 						Category: "get",
 					},
+					{
+						Name:     "delete",
+						Category: "put",
+						Usage:    "delete VMs after confirmation. [--selector <key=value,...>] [--parallel <n>] [--force] [--power-off-first] [--wait] [--timeout <duration>] <VM name|UUID> [<VM name|UUID>...]",
+						Action:   ncli.vmDelete,
+						Flags: append(append([]cli.Flag{
+							&cli.BoolFlag{
+								Name:  "force",
+								Usage: "delete without asking for confirmation",
+							},
+							&cli.BoolFlag{
+								Name:  "power-off-first",
+								Usage: "power off running VMs and wait for them before deleting",
+							},
+						}, selectorFlags()...), waitFlags()...),
+					},
+					{
+						Name:     "clone",
+						Category: "put",
+						Usage:    "clone a VM. <source VM name|UUID> --name <name or pattern such as web-{{.Index}}> [--count <n>] [--start-index <n>] [--vcpus <n>] [--memory <size such as 8GiB>] [--subnet <name|UUID>] [--cloud-init <user data>] [--meta-data <meta data>] [--sysprep <unattend.xml>] [--wait] [--timeout <duration>]",
+						Action:   ncli.vmClone,
+						Flags: append([]cli.Flag{
+							&cli.StringFlag{
+								Name:  "name",
+								Usage: "clone name. a template where {{.Index}} is the clone number",
+							},
+							&cli.IntFlag{
+								Name:  "count",
+								Value: 1,
+								Usage: "number of clones to create",
+							},
+							&cli.IntFlag{
+								Name:  "start-index",
+								Value: 1,
+								Usage: "first value of {{.Index}}",
+							},
+							&cli.IntFlag{
+								Name:  "vcpus",
+								Usage: "vCPUs for the clones",
+							},
+							&cli.StringFlag{
+								Name:  "memory",
+								Usage: "memory for the clones as MiB, GiB, MB or GB. a bare number is MiB",
+							},
+							&cli.StringFlag{
+								Name:  "subnet",
+								Usage: "attach the clones to a single NIC on this subnet",
+							},
+							&cli.StringFlag{
+								Name:  "cloud-init",
+								Usage: "cloud-init user data file for the clones",
+							},
+							&cli.StringFlag{
+								Name:  "meta-data",
+								Usage: "cloud-init meta data file for the clones",
+							},
+							&cli.StringFlag{
+								Name:  "sysprep",
+								Usage: "sysprep unattend.xml file for the clones",
+							},
+						}, waitFlags()...),
+					},
+					{
+						Name:     "export",
+						Usage:    "write a VM spec usable with vm create and apply. <VM name|UUID> [--file <yaml file>] [--templatize]",
+						Action:   ncli.vmExport,
+						Category: "get",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "file",
+								Aliases: []string{"f"},
+								Usage:   "write the spec to a file instead of standard output",
+							},
+							&cli.BoolFlag{
+								Name:  "templatize",
+								Usage: "replace subnet, image and cluster UUIDs with name lookups",
+							},
+						},
+					},
 					{
 						Name:     "get-vdisks",
-						Usage:    "<VM UUID>",
+						Usage:    "<VM name|UUID>",
 						Action:   ncli.vmVDiskGet,
 						Category: "get",
 					},
 					{
 						Name:     "get-disks",
-						Usage:    "<VM UUID>",
+						Usage:    "<VM name|UUID>",
 						Action:   ncli.vmDiskList,
 						Category: "get",
 					},
+					{
+						Name:     "disk",
+						Usage:    "VM disk commands. use `uwncli vm disk help` to view options",
+						Category: "put",
+						Subcommands: []*cli.Command{
+							{
+								Name:   "add",
+								Usage:  "add an empty disk. [--size <size such as 100GiB>] [--bus <SCSI|IDE|PCI|SATA|SPAPR_VSCSI>] [--index <n>] [--container <name|UUID>] [--wait] [--timeout <duration>] <VM name|UUID>",
+								Action: ncli.vmDiskAdd,
+								Flags: append([]cli.Flag{
+									&cli.StringFlag{
+										Name:  "size",
+										Usage: "disk size as MiB, GiB, MB or GB. a bare number is MiB",
+									},
+									diskBusFlag(),
+									diskIndexFlag(),
+									&cli.StringFlag{
+										Name:  "container",
+										Usage: "storage container for the disk. the cluster default when not set",
+									},
+								}, waitFlags()...),
+							},
+							{
+								Name:   "attach-image",
+								Usage:  "add a disk cloned from an image. [--image <name|UUID>] [--size <size>] [--bus <SCSI|IDE|PCI|SATA|SPAPR_VSCSI>] [--index <n>] [--wait] [--timeout <duration>] <VM name|UUID>",
+								Action: ncli.vmDiskAttachImage,
+								Flags: append([]cli.Flag{
+									&cli.StringFlag{
+										Name:  "image",
+										Usage: "image to clone the disk from",
+									},
+									&cli.StringFlag{
+										Name:  "size",
+										Usage: "disk size when larger than the image as MiB, GiB, MB or GB",
+									},
+									diskBusFlag(),
+									diskIndexFlag(),
+								}, waitFlags()...),
+							},
+							{
+								Name:   "resize",
+								Usage:  "grow a disk. [--size <size such as 200GiB>] [--bus <bus of a bare index>] [--wait] [--timeout <duration>] <VM name|UUID> <disk UUID|bus.index|index>",
+								Action: ncli.vmDiskResize,
+								Flags: append([]cli.Flag{
+									&cli.StringFlag{
+										Name:  "size",
+										Usage: "new disk size as MiB, GiB, MB or GB. a bare number is MiB",
+									},
+									diskBusFlag(),
+								}, waitFlags()...),
+							},
+							{
+								Name:   "detach",
+								Usage:  "remove a disk after confirmation. [--force] [--bus <bus of a bare index>] [--wait] [--timeout <duration>] <VM name|UUID> <disk UUID|bus.index|index>",
+								Action: ncli.vmDiskDetach,
+								Flags: append([]cli.Flag{
+									&cli.BoolFlag{
+										Name:  "force",
+										Usage: "detach without asking for confirmation",
+									},
+									diskBusFlag(),
+								}, waitFlags()...),
+							},
+						},
+					},
+					{
+						Name:     "nic",
+						Usage:    "VM NIC commands. use `uwncli vm nic help` to view options",
+						Category: "put",
+						Subcommands: []*cli.Command{
+							{
+								Name:   "add",
+								Usage:  "add a NIC. [--subnet <name|UUID>] [--ip <static IP>] [--mac <MAC address>] [--wait] [--timeout <duration>] <VM name|UUID>",
+								Action: ncli.vmNICAdd,
+								Flags: append([]cli.Flag{
+									&cli.StringFlag{
+										Name:  "subnet",
+										Usage: "subnet for the NIC",
+									},
+									&cli.StringFlag{
+										Name:  "ip",
+										Usage: "static IP requested from the subnet IP address management",
+									},
+									&cli.StringFlag{
+										Name:  "mac",
+										Usage: "MAC address. generated when not set",
+									},
+								}, waitFlags()...),
+							},
+							{
+								Name:   "update",
+								Usage:  "move a NIC to another subnet or change its static IP. [--subnet <name|UUID>] [--ip <static IP>] [--wait] [--timeout <duration>] <VM name|UUID> <NIC UUID|MAC>",
+								Action: ncli.vmNICUpdate,
+								Flags: append([]cli.Flag{
+									&cli.StringFlag{
+										Name:  "subnet",
+										Usage: "new subnet for the NIC",
+									},
+									&cli.StringFlag{
+										Name:  "ip",
+										Usage: "static IP requested from the subnet IP address management",
+									},
+								}, waitFlags()...),
+							},
+							{
+								Name:   "remove",
+								Usage:  "remove a NIC. [--wait] [--timeout <duration>] <VM name|UUID> <NIC UUID|MAC>",
+								Action: ncli.vmNICRemove,
+								Flags:  waitFlags(),
+							},
+						},
+					},
+					{
+						Name:     "cdrom",
+						Usage:    "VM CD-ROM commands. use `uwncli vm cdrom help` to view options",
+						Category: "put",
+						Subcommands: []*cli.Command{
+							{
+								Name:   "mount",
+								Usage:  "mount an ISO image. [--image <ISO image name|UUID>] [--device <bus.index>] [--wait] [--timeout <duration>] <VM name|UUID>",
+								Action: ncli.vmCDROMMount,
+								Flags: append([]cli.Flag{
+									&cli.StringFlag{
+										Name:  "image",
+										Usage: "ISO_IMAGE to mount",
+									},
+									cdromDeviceFlag(),
+								}, waitFlags()...),
+							},
+							{
+								Name:   "eject",
+								Usage:  "remove the mounted media. [--device <bus.index>] [--wait] [--timeout <duration>] <VM name|UUID>",
+								Action: ncli.vmCDROMEject,
+								Flags:  append([]cli.Flag{cdromDeviceFlag()}, waitFlags()...),
+							},
+						},
+					},
+					{
+						Name:     "snapshot",
+						Usage:    "VM snapshot commands through Prism Element. use `uwncli vm snapshot help` to view options",
+						Category: "put",
+						Subcommands: []*cli.Command{
+							{
+								Name:   "list",
+								Usage:  "list the snapshots of a VM or of every VM. [<VM name|UUID>]",
+								Action: ncli.vmSnapshotList,
+							},
+							{
+								Name:   "create",
+								Usage:  "snapshot a VM. [--name <snapshot name>] [--wait] [--timeout <duration>] <VM name|UUID>",
+								Action: ncli.vmSnapshotCreate,
+								Flags: append([]cli.Flag{
+									&cli.StringFlag{
+										Name:  "name",
+										Usage: "snapshot name. the VM name and time when not set",
+									},
+								}, waitFlags()...),
+							},
+							{
+								Name:   "restore",
+								Usage:  "revert a VM to a snapshot after confirmation. [--force] [--wait] [--timeout <duration>] <VM name|UUID> <snapshot name|UUID>",
+								Action: ncli.vmSnapshotRestore,
+								Flags: append([]cli.Flag{
+									&cli.BoolFlag{
+										Name:  "force",
+										Usage: "restore without asking for confirmation",
+									},
+								}, waitFlags()...),
+							},
+							{
+								Name:   "clone",
+								Usage:  "create a new VM from a snapshot. [--name <new VM name>] [--wait] [--timeout <duration>] <VM name|UUID> <snapshot name|UUID>",
+								Action: ncli.vmSnapshotClone,
+								Flags: append([]cli.Flag{
+									&cli.StringFlag{
+										Name:  "name",
+										Usage: "name of the new VM",
+									},
+								}, waitFlags()...),
+							},
+							{
+								Name:   "delete",
+								Usage:  "delete a snapshot after confirmation. [--force] [--wait] [--timeout <duration>] <VM name|UUID> <snapshot name|UUID>",
+								Action: ncli.vmSnapshotDelete,
+								Flags: append([]cli.Flag{
+									&cli.BoolFlag{
+										Name:  "force",
+										Usage: "delete without asking for confirmation",
+									},
+								}, waitFlags()...),
+							},
+						},
+					},
 					{
 						Name:     "create",
-						Usage:    "[--vm-yaml <vm yaml config file>] [yaml config from standard input (pipe)]",
+						Usage:    "[--vm-yaml <vm yaml config file>] [--var <key=value>] [--var-file <vars yaml>] [--cloud-init <user data>] [--meta-data <meta data>] [--sysprep <unattend.xml>] [--wait] [--timeout <duration>] [yaml config from standard input (pipe)]",
 						Action:   ncli.vmCreate,
 						Category: "put",
+						Flags: append([]cli.Flag{
+							&cli.StringSliceFlag{
+								Name:  "var",
+								Usage: "template variable as key=value. may be repeated",
+							},
+							&cli.StringFlag{
+								Name:  "var-file",
+								Usage: "YAML file of template variables",
+							},
+							&cli.StringFlag{
+								Name:  "cloud-init",
+								Usage: "cloud-init user data file such as a #cloud-config YAML",
+							},
+							&cli.StringFlag{
+								Name:  "meta-data",
+								Usage: "cloud-init meta data YAML file",
+							},
+							&cli.StringFlag{
+								Name:  "sysprep",
+								Usage: "sysprep unattend.xml file for Windows guests",
+							},
+						}, waitFlags()...),
+					},
+					{
+						Name:     "update",
+						Usage:    "[--memory <size such as 8GiB>] [--vcpus <n>] [--power-cycle] [--selector <key=value,...>] [--parallel <n>] [--force] [--wait] [--timeout <duration>] <VM name|UUID>",
+						Action:   ncli.vmUpdate,
+						Category: "put",
+						Flags: append(append([]cli.Flag{
+							&cli.StringFlag{
+								Name:  "memory",
+								Usage: "memory as MiB, GiB, MB or GB. a bare number is MiB",
+							},
+							&cli.IntFlag{
+								Name:  "vcpus",
+								Usage: "total vCPUs",
+							},
+							powerCycleFlag(),
+							&cli.BoolFlag{
+								Name:  "force",
+								Usage: "update the VMs matched by --selector without asking for confirmation",
+							},
+						}, selectorFlags()...), waitFlags()...),
+					},
+					{
+						Name:     "update-cpu",
+						Usage:    "[--sockets <n>] [--cores-per-socket <n>] [--power-cycle] [--wait] [--timeout <duration>] <VM name|UUID>",
+						Action:   ncli.vmCPUUpdate,
+						Category: "put",
+						Flags: append([]cli.Flag{
+							&cli.IntFlag{
+								Name:  "sockets",
+								Usage: "number of vCPU sockets",
+							},
+							&cli.IntFlag{
+								Name:  "cores-per-socket",
+								Usage: "vCPUs per socket",
+							},
+							powerCycleFlag(),
+						}, waitFlags()...),
 					},
 					{
 						Name:     "update-memory",
-						Usage:    "<VM UUID> <memory in MB integer>",
+						Usage:    "[--power-cycle] [--wait] [--timeout <duration>] <VM name|UUID> <memory in MB or a size such as 8GiB>",
 						Action:   ncli.vmMemoryUpdate,
 						Category: "put",
+						Flags:    append([]cli.Flag{powerCycleFlag()}, waitFlags()...),
 					},
 					{
 						Name:     "update-power",
-						Usage:    "<VM UUID> <ON|OFF|POWERCYCLE|RESET|PAUSE|SUSPEND|RESUME|ACPI_SHUTDOWN|ACPI_REBOOT>",
+						Usage:    "[--selector <key=value,...>] [--parallel <n>] [--force] [--wait] [--timeout <duration>] <VM name|UUID, omitted with --selector> <ON|OFF|POWERCYCLE|RESET|PAUSE|SUSPEND|RESUME|ACPI_SHUTDOWN|ACPI_REBOOT>",
 						Action:   ncli.vmSetPowerState,
 						Category: "put",
+						Flags: append(append([]cli.Flag{
+							&cli.BoolFlag{
+								Name:  "force",
+								Usage: "change the VMs matched by --selector without asking for confirmation",
+							},
+						}, selectorFlags()...), waitFlags()...),
+					},
+					{
+						Name:     "migrate",
+						Usage:    "move a running VM to another host and wait for it. lists the hosts able to take the VM without --host. [--host <host name|UUID>] [--live] [--timeout <duration>] <VM name|UUID>",
+						Action:   ncli.vmMigrate,
+						Category: "put",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "host",
+								Usage: "host to move the VM to",
+							},
+							&cli.BoolFlag{
+								Name:  "live",
+								Usage: "migrate without stopping the VM",
+							},
+							&cli.DurationFlag{
+								Name:  "timeout",
+								Value: defaultTaskTimeout,
+								Usage: "maximum time to wait for the migration",
+							},
+						},
 					},
 				},
 			},
@@ -142,9 +554,10 @@ func main() {
 					},
 					{
 						Name:     "create",
-						Usage:    "create a new image",
+						Usage:    "create a new image [--wait] [--timeout <duration>]",
 						Action:   ncli.imageCreate,
 						Category: "image",
+						Flags:    waitFlags(),
 					},
 				},
 			},
@@ -192,6 +605,64 @@ func main() {
 					},
 				},
 			},
+			{
+				Before: func(c *cli.Context) error {
+					var err error
+					ncli.con, err = setupConnection(c)
+					return err
+				},
+				Name:  "host",
+				Usage: "host specific commands through Prism Element. use `uwncli host help` to view options",
+				Subcommands: []*cli.Command{
+					{
+						Name:     "list",
+						Usage:    "list the hosts of the cluster",
+						Category: "host",
+						Action:   ncli.hostList,
+					},
+					{
+						Name:     "get",
+						Usage:    "<host name|UUID>",
+						Category: "host",
+						Action:   ncli.hostGet,
+					},
+					{
+						Name:     "maintenance",
+						Usage:    "host maintenance mode commands. use `uwncli host maintenance help` to view options",
+						Category: "host",
+						Subcommands: []*cli.Command{
+							{
+								Name:   "enter",
+								Usage:  "live migrate the running VMs off a host and put it in maintenance mode after confirmation. [--force] [--timeout <duration>] <host name|UUID>",
+								Action: ncli.hostMaintenanceEnter,
+								Flags: []cli.Flag{
+									&cli.BoolFlag{
+										Name:  "force",
+										Usage: "enter maintenance mode without asking for confirmation",
+									},
+									&cli.DurationFlag{
+										Name:  "timeout",
+										Value: defaultTaskTimeout,
+										Usage: "maximum time to wait for each migration and for the host",
+									},
+								},
+							},
+							{
+								Name:   "exit",
+								Usage:  "take a host out of maintenance mode and wait until it is ready. [--timeout <duration>] <host name|UUID>",
+								Action: ncli.hostMaintenanceExit,
+								Flags: []cli.Flag{
+									&cli.DurationFlag{
+										Name:  "timeout",
+										Value: defaultTaskTimeout,
+										Usage: "maximum time to wait for the host",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
 			{
 				Before: func(c *cli.Context) error {
 					var err error
@@ -208,6 +679,108 @@ func main() {
 					},
 				},
 			},
+			{
+				Before: func(c *cli.Context) error {
+					var err error
+					ncli.con, err = setupConnection(c)
+					return err
+				},
+				Name:   "apply",
+				Usage:  "create or update VMs to match YAML specs. -f <file or directory> [--var <key=value>] [--var-file <vars yaml>] [--yes] [--wait] [--timeout <duration>]",
+				Action: ncli.apply,
+				Flags: append([]cli.Flag{
+					&cli.StringSliceFlag{
+						Name:    "filename",
+						Aliases: []string{"f"},
+						Usage:   "VM spec file or directory of spec files. may be repeated",
+					},
+					&cli.StringSliceFlag{
+						Name:  "var",
+						Usage: "template variable as key=value. may be repeated",
+					},
+					&cli.StringFlag{
+						Name:  "var-file",
+						Usage: "YAML file of template variables",
+					},
+					&cli.BoolFlag{
+						Name:    "yes",
+						Aliases: []string{"y"},
+						Usage:   "apply the plan without asking for confirmation",
+					},
+				}, waitFlags()...),
+			},
+			{
+				Before: func(c *cli.Context) error {
+					var err error
+					ncli.con, err = setupConnection(c)
+					return err
+				},
+				Name:  "task",
+				Usage: "task specific commands. use `uwncli task help` to view options",
+				Subcommands: []*cli.Command{
+					{
+						Name:     "list",
+						Usage:    "list tasks [--status <status>] [--entity <name|UUID>] [--operation <type>] [--limit <count>]",
+						Action:   ncli.taskList,
+						Category: "get",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "status",
+								Usage: "only tasks in the status <QUEUED|RUNNING|SUCCEEDED|FAILED|ABORTED>",
+							},
+							&cli.StringFlag{
+								Name:  "entity",
+								Usage: "only tasks on the entity UUID or name glob",
+							},
+							&cli.StringFlag{
+								Name:  "operation",
+								Usage: "only tasks whose operation type contains the value",
+							},
+							&cli.IntFlag{
+								Name:  "limit",
+								Usage: "maximum number of tasks to return",
+							},
+						},
+					},
+					{
+						Name:     "get",
+						Usage:    "<task UUID>",
+						Action:   ncli.taskGet,
+						Category: "get",
+					},
+					{
+						Name:     "watch",
+						Usage:    "[--interval <duration>] [--timeout <duration>] [task UUID...] watch tasks until they finish. defaults to every running task",
+						Action:   ncli.taskWatch,
+						Category: "get",
+						Flags: []cli.Flag{
+							&cli.DurationFlag{
+								Name:  "interval",
+								Value: taskPollInterval,
+								Usage: "delay between refreshes",
+							},
+							&cli.DurationFlag{
+								Name:  "timeout",
+								Value: defaultTaskTimeout,
+								Usage: "maximum time to watch",
+							},
+						},
+					},
+					{
+						Name:     "wait",
+						Usage:    "[--timeout <duration>] <task UUID...> wait for tasks to finish",
+						Action:   ncli.taskWait,
+						Category: "get",
+						Flags: []cli.Flag{
+							&cli.DurationFlag{
+								Name:  "timeout",
+								Value: defaultTaskTimeout,
+								Usage: "maximum time to wait for all tasks",
+							},
+						},
+					},
+				},
+			},
 			{
 				Before: func(c *cli.Context) error {
 					var err error
@@ -232,9 +805,4 @@ func main() {
 			},
 		},
 	}
-
-	err := app.Run(os.Args)
-	if err != nil {
-		log.Fatal(err)
-	}
 }
@@ -0,0 +1,1834 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/olekukonko/tablewriter"
+)
+
+// setTestHome points the home directory at an empty temporary folder so that
+// stored profiles on the machine running the tests are never used
+func setTestHome(t *testing.T) string {
+	home := t.TempDir()
+
+	oldHome := os.Getenv("HOME")
+	os.Setenv("HOME", home)
+	homedir.DisableCache = true
+	t.Cleanup(func() {
+		os.Setenv("HOME", oldHome)
+		homedir.DisableCache = false
+	})
+
+	return home
+}
+
+// runCLI runs uwncli against the mock server and returns everything written
+// to the command output
+func runCLI(t *testing.T, m *mockPrism, args ...string) (string, error) {
+	var buf bytes.Buffer
+
+	ncli := &NCLI{out: &buf, tr: tablewriter.NewWriter(&buf), in: stubInputReader{Input: "n"}}
+	bcli := &BCLI{out: &buf, tr: tablewriter.NewWriter(&buf)}
+
+	base := []string{"uwncli", "--skip-cert-verify", "--username", mockUser, "--password", mockPass}
+	if m != nil {
+		base = append(base, "--pcaddress", m.address(), "--peaddress", m.address(), "--karbonaddress", m.address(), "--karbonuser", mockUser, "--karbonpass", mockPass)
+	}
+
+	err := newApp(ncli, bcli).Run(append(base, args...))
+
+	return buf.String(), err
+}
+
+// writeTestFile writes content to a file in a temporary directory
+func writeTestFile(t *testing.T, name, content string) string {
+	fl := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(fl, []byte(content), 0600); err != nil {
+		t.Fatalf("unable to write %s: %v", name, err)
+	}
+	return fl
+}
+
+const testCreateVMYAML = `
+spec:
+  name: app-01
+  resources:
+    nic_list:
+    - nic_type: NORMAL_NIC
+      subnet_reference:
+        kind: subnet
+        uuid: 5e3a1b2c-4d5e-4f6a-8b7c-9d0e1f2a3b10
+    num_vcpus_per_socket: 1
+    num_sockets: 2
+    memory_size_mib: 2048
+    power_state: 'ON'
+    disk_list:
+    - data_source_reference:
+        kind: image
+        uuid: 6f4b2c3d-5e6f-4a7b-9c8d-0e1f2a3b4c81
+      device_properties:
+        disk_address:
+          device_index: 0
+          adapter_type: SCSI
+        device_type: DISK
+  cluster_reference:
+    kind: cluster
+    uuid: 0005b1c2-3d4e-4f56-8a7b-9c0d1e2f3a41
+api_version: '3.1'
+metadata:
+  kind: vm
+`
+
+func TestCommands(t *testing.T) {
+	setTestHome(t)
+
+	createFile := writeTestFile(t, "createvm.yaml", testCreateVMYAML)
+	invalidFile := writeTestFile(t, "invalidvm.yaml", strings.NewReplacer("3b10", "3b99", "4c81", "4c99").Replace(testCreateVMYAML))
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "vm list",
+			args: []string{"vm", "list"},
+			want: []string{"web-01", "web-02", "db-01", "1b0e2a47-5d8c-4e0f-9a31-6f2d8c4b7e01", "OFF", "prod", "dev", "TOTAL", "3"},
+		},
+		{
+			name: "vm list json",
+			args: []string{"--output", "json", "vm", "list"},
+			want: []string{`"uuid": "3d204c69-7fae-4021-9c53-8b4fae6d9a03"`, `"memory_size_mib": 16384`, `"mac_address": "50:6b:8d:00:00:01"`},
+		},
+		{
+			name: "vm list query",
+			args: []string{"--output", "csv", "--query", "[?spec.resources.power_state=='ON'].spec.name", "vm", "list"},
+			want: []string{"web-01\ndb-01\n"},
+		},
+		{
+			name: "vm get",
+			args: []string{"vm", "get", "1b0e2a47-5d8c-4e0f-9a31-6f2d8c4b7e01"},
+			want: []string{"web-01", "d10e2a47-5d8c-4e0f-9a31-6f2d8c4b7e11", "10.10.0.11", "prod-vlan10", "POWER STATE"},
+		},
+		{
+			name:    "vm get unknown name",
+			args:    []string{"vm", "get", "not-a-vm"},
+			wantErr: `no vm found matching "not-a-vm"`,
+		},
+		{
+			name: "vm get by name",
+			args: []string{"vm", "get", "web-01"},
+			want: []string{"d10e2a47-5d8c-4e0f-9a31-6f2d8c4b7e11", "10.10.0.11"},
+		},
+		{
+			name: "vm get by prefix",
+			args: []string{"vm", "get", "db"},
+			want: []string{"db-01", "10.20.0.21"},
+		},
+		{
+			name:    "vm get ambiguous glob",
+			args:    []string{"vm", "get", "web-*"},
+			wantErr: "2 vms match \"web-*\"",
+		},
+		{
+			name: "vm get-disks",
+			args: []string{"vm", "get-disks", "3d204c69-7fae-4021-9c53-8b4fae6d9a03"},
+			want: []string{"d3204c69-7fae-4021-9c53-8b4fae6d9a13", "2361393152", "SCSI", "DB-01"},
+		},
+		{
+			name: "vm get-vdisks",
+			args: []string{"vm", "get-vdisks", "2c1f3b58-6e9d-4f10-8b42-7a3e9d5c8f02"},
+			want: []string{"WEB-02", "d21f3b58-6e9d-4f10-8b42-7a3e9d5c8f12", "CDROM", "nfs://127.0.0.1/default-container"},
+		},
+		{
+			name: "vm create",
+			args: []string{"vm", "--vm-yaml", createFile, "create"},
+			want: []string{"app-01", "PENDING", "4a5b6c7d-8e9f-4a0b-9c1d-000000000001"},
+		},
+		{
+			name:    "vm create invalid",
+			args:    []string{"vm", "--vm-yaml", invalidFile, "create"},
+			wantErr: "nic_list[0] subnet 5e3a1b2c-4d5e-4f6a-8b7c-9d0e1f2a3b99 does not exist\n  - disk_list[0] image",
+		},
+		{
+			name: "vm update-memory",
+			args: []string{"vm", "update-memory", "2c1f3b58-6e9d-4f10-8b42-7a3e9d5c8f02", "8000"},
+			want: []string{"vm updated to 8000 memory"},
+		},
+		{
+			name: "vm update-power",
+			args: []string{"vm", "update-power", "2c1f3b58-6e9d-4f10-8b42-7a3e9d5c8f02", "on"},
+			want: []string{"power state:  ON"},
+		},
+		{
+			name: "image list",
+			args: []string{"image", "list"},
+			want: []string{"centos8", "ubuntu20", "ISO_IMAGE", "6f4b2c3d-5e6f-4a7b-9c8d-0e1f2a3b4c83", "http://images.local/focal.img"},
+		},
+		{
+			name: "image create",
+			args: []string{"--image-name", "rocky8", "--image-description", "rocky linux", "--image-type", "DISK_IMAGE", "--image-source", "http://images.local/rocky8.qcow2", "image", "create"},
+			want: []string{"rocky8", "rocky linux", "PENDING"},
+		},
+		{
+			name: "disk list",
+			args: []string{"disk", "list"},
+			want: []string{"9c7e5f60-8192-4dae-9fb0-3b4c5d6e7f01", "DAS-SATA", "ahv-node-02", "NORMAL"},
+		},
+		{
+			name: "disk list-vdisk",
+			args: []string{"disk", "list-vdisk"},
+			want: []string{"d10e2a47-5d8c-4e0f-9a31-6f2d8c4b7e55", "1b0e2a47-5d8c-4e0f-9a31-6f2d8c4b7e01", "scsi.0"},
+		},
+		{
+			name: "cluster list",
+			args: []string{"cluster", "list"},
+			want: []string{"prod", "dev", "0005b1c2-3d4e-4f56-8a7b-9c0d1e2f3a42", "el7.nutanix.20190916.410", "TOTAL", "2"},
+		},
+		{
+			name: "cluster get",
+			args: []string{"cluster", "get"},
+			want: []string{"5.19.1", "10.0.1.50", "0005b1c2-3d4e-4f56-8a7b-9c0d1e2f3a41"},
+		},
+		{
+			name: "cluster get yaml",
+			args: []string{"-o", "yaml", "cluster", "get"},
+			want: []string{"full_version: el7.3-release-euphrates-5.19.1-stable", "num_nodes: 2"},
+		},
+		{
+			name: "subnet list",
+			args: []string{"subnet", "list"},
+			want: []string{"prod-vlan10", "dev-vlan20", "10.20.0.1", "VLAN"},
+		},
+		{
+			name: "karbon cluster list",
+			args: []string{"karbon", "cluster", "list"},
+			want: []string{"k8s-prod", "k8s-dev", "10.10.0.100", "1.18.15-0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockPrism(t)
+
+			got, err := runCLI(t, m, tt.args...)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("run %v error = %v, wantErr %v", tt.args, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("run %v error = %v", tt.args, err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("run %v output missing %q\n%s", tt.args, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestCommandsUpdateRequests(t *testing.T) {
+	setTestHome(t)
+	m := newMockPrism(t)
+
+	if _, err := runCLI(t, m, "vm", "update-memory", "1b0e2a47-5d8c-4e0f-9a31-6f2d8c4b7e01", "8000"); err != nil {
+		t.Fatalf("update-memory error = %v", err)
+	}
+
+	puts := m.received("PUT", pcPrefix+"vms/1b0e2a47-5d8c-4e0f-9a31-6f2d8c4b7e01")
+	if len(puts) != 1 {
+		t.Fatalf("expected 1 update request, got %d", len(puts))
+	}
+
+	spec := puts[0].Body["spec"].(map[string]interface{})
+	resources := spec["resources"].(map[string]interface{})
+	if got := resources["memory_size_mib"]; got != float64(GetMibFromMB(8000)) {
+		t.Errorf("memory_size_mib = %v, want %v", got, GetMibFromMB(8000))
+	}
+}
+
+func TestProfileList(t *testing.T) {
+	home := setTestHome(t)
+
+	dir := filepath.Join(home, ".nutanix")
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeProfileFile(filepath.Join(dir, "lab.credential"), dir, &profileItem{PCAddress: "10.0.0.1:9440"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := runCLI(t, nil, "profile", "list")
+	if err != nil {
+		t.Fatalf("profile list error = %v", err)
+	}
+	if !strings.Contains(got, "lab") || !strings.Contains(got, "lab.credential") {
+		t.Errorf("profile list output missing lab profile\n%s", got)
+	}
+}
+
+func TestVMListFilters(t *testing.T) {
+	setTestHome(t)
+	m := newMockPrism(t)
+
+	got, err := runCLI(t, m, "vm", "list", "--cluster", "prod", "--power-state", "on", "--sort-attribute", "vm_name", "--sort-order", "descending", "--limit", "2")
+	if err != nil {
+		t.Fatalf("vm list error = %v", err)
+	}
+	if strings.Contains(got, "db-01") {
+		t.Errorf("vm list --limit 2 returned more than 2 VMs\n%s", got)
+	}
+
+	lists := m.received("POST", pcPrefix+"vms/list")
+	if len(lists) != 1 {
+		t.Fatalf("expected 1 list request, got %d", len(lists))
+	}
+
+	body := lists[0].Body
+	want := map[string]interface{}{
+		"filter":         "cluster_name==prod;power_state==on",
+		"sort_attribute": "vm_name",
+		"sort_order":     "DESCENDING",
+		"length":         float64(2),
+	}
+	for k, v := range want {
+		if body[k] != v {
+			t.Errorf("vm list request %s = %v, want %v", k, body[k], v)
+		}
+	}
+
+	if _, err := runCLI(t, m, "vm", "list", "--sort-order", "sideways"); err == nil {
+		t.Errorf("vm list accepted an invalid sort order")
+	}
+}
+
+func TestVMListPaginates(t *testing.T) {
+	setTestHome(t)
+	m := newMockPrism(t)
+
+	template, _ := json.Marshal(m.data["pc_vms"][0])
+	for i := 0; i < 247; i++ {
+		vm := map[string]interface{}{}
+		json.Unmarshal(template, &vm)
+		vm["metadata"].(map[string]interface{})["uuid"] = fmt.Sprintf("7a000000-0000-4000-8000-%012d", i)
+		vm["spec"].(map[string]interface{})["name"] = fmt.Sprintf("bulk-%03d", i)
+		m.data["pc_vms"] = append(m.data["pc_vms"], vm)
+	}
+
+	got, err := runCLI(t, m, "--output", "csv", "--query", "[].spec.name", "vm", "list")
+	if err != nil {
+		t.Fatalf("vm list error = %v", err)
+	}
+
+	names := strings.Split(strings.TrimSpace(got), "\n")
+	if len(names) != 250 {
+		t.Errorf("vm list returned %d VMs, want 250", len(names))
+	}
+	for _, want := range []string{"web-01", "db-01", "bulk-000", "bulk-246"} {
+		if !strings.Contains(got, want+"\n") {
+			t.Errorf("vm list output missing %q", want)
+		}
+	}
+	if names[len(names)-1] != "bulk-246" {
+		t.Errorf("vm list last entry = %q, want pages in order", names[len(names)-1])
+	}
+
+	if lists := m.received("POST", pcPrefix+"vms/list"); len(lists) != 3 {
+		t.Errorf("expected 3 list requests, got %d", len(lists))
+	}
+}
+
+func TestVMSetPowerState(t *testing.T) {
+	setTestHome(t)
+
+	oldInterval := taskPollInterval
+	taskPollInterval = time.Millisecond
+	t.Cleanup(func() { taskPollInterval = oldInterval })
+
+	vmUUID := "2c1f3b58-6e9d-4f10-8b42-7a3e9d5c8f02"
+
+	tests := []struct {
+		name       string
+		taskStatus string
+		args       []string
+		want       []string
+		wantPE     string
+		wantErr    string
+	}{
+		{
+			name: "on through v3 with wait",
+			args: []string{"vm", "update-power", "--wait", "web-02", "on"},
+			want: []string{"power state:  ON", "task: 4a5b6c7d-8e9f-4a0b-9c1d-000000000001", "succeeded"},
+		},
+		{
+			name:   "acpi shutdown through v2",
+			args:   []string{"vm", "update-power", "--wait", vmUUID, "acpi_shutdown"},
+			want:   []string{"power state:  ACPI_SHUTDOWN", "succeeded"},
+			wantPE: "ACPI_SHUTDOWN",
+		},
+		{
+			name:   "powercycle without wait",
+			args:   []string{"vm", "update-power", vmUUID, "POWERCYCLE"},
+			want:   []string{"task: 4a5b6c7d"},
+			wantPE: "POWERCYCLE",
+		},
+		{
+			name:       "failed task",
+			taskStatus: "FAILED",
+			args:       []string{"vm", "update-power", "--wait", vmUUID, "reset"},
+			wantErr:    "failed: kInvalidState operation failed",
+		},
+		{
+			name:       "timeout",
+			taskStatus: "RUNNING",
+			args:       []string{"vm", "update-power", "--wait", "--timeout", "1ms", vmUUID, "off"},
+			wantErr:    "timed out",
+		},
+		{
+			name:    "invalid state",
+			args:    []string{"vm", "update-power", vmUUID, "hibernate"},
+			wantErr: "invalid power state",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockPrism(t)
+			if tt.taskStatus != "" {
+				m.taskStatus = tt.taskStatus
+			}
+
+			got, err := runCLI(t, m, tt.args...)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("run %v error = %v, wantErr %v", tt.args, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("run %v error = %v", tt.args, err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("run %v output missing %q\n%s", tt.args, want, got)
+				}
+			}
+
+			posts := m.received("POST", pePrefix+"vms/"+vmUUID+"/set_power_state")
+			if tt.wantPE == "" {
+				if len(posts) != 0 {
+					t.Errorf("expected no v2 power requests, got %d", len(posts))
+				}
+				return
+			}
+			if len(posts) != 1 || posts[0].Body["transition"] != tt.wantPE {
+				t.Errorf("v2 power requests = %v, want transition %s", posts, tt.wantPE)
+			}
+		})
+	}
+}
+
+func TestTaskCommands(t *testing.T) {
+	setTestHome(t)
+
+	oldInterval := taskPollInterval
+	taskPollInterval = time.Millisecond
+	t.Cleanup(func() { taskPollInterval = oldInterval })
+
+	running := "5c6d7e8f-9a0b-4c1d-8e2f-3a4b5c6d7e01"
+	subtask := "5c6d7e8f-9a0b-4c1d-8e2f-3a4b5c6d7e02"
+	succeeded := "5c6d7e8f-9a0b-4c1d-8e2f-3a4b5c6d7e03"
+	failed := "5c6d7e8f-9a0b-4c1d-8e2f-3a4b5c6d7e04"
+
+	tests := []struct {
+		name    string
+		steps   map[string]int
+		args    []string
+		want    []string
+		notWant []string
+		wantErr string
+	}{
+		{
+			name: "list",
+			args: []string{"task", "list"},
+			want: []string{running, succeeded, failed, "kImageCreate", "image:centos8", "TOTAL", "4"},
+		},
+		{
+			name:    "list by status",
+			args:    []string{"task", "list", "--status", "failed"},
+			want:    []string{failed, "vm:db-01"},
+			notWant: []string{running, succeeded},
+		},
+		{
+			name:    "list by entity and operation",
+			args:    []string{"task", "list", "--entity", "web-*", "--operation", "disk"},
+			want:    []string{subtask},
+			notWant: []string{running, failed},
+		},
+		{
+			name: "list limit",
+			args: []string{"-o", "csv", "--query", "[].uuid", "task", "list", "--limit", "2"},
+			want: []string{running + "\n" + subtask + "\n"},
+		},
+		{
+			name: "get",
+			args: []string{"task", "get", running},
+			want: []string{"kVmCreate", "40%", subtask, "vm:web-01", "0005b1c2-3d4e-4f56-8a7b-9c0d1e2f3a41"},
+		},
+		{
+			name:    "get missing uuid",
+			args:    []string{"task", "get"},
+			wantErr: "no task UUID provided",
+		},
+		{
+			name: "wait",
+			args: []string{"task", "wait", succeeded},
+			want: []string{"SUCCEEDED", "kImageCreate"},
+		},
+		{
+			name:    "wait failed",
+			args:    []string{"task", "wait", succeeded, failed},
+			wantErr: "1 of 2 tasks did not succeed",
+		},
+		{
+			name:  "wait running",
+			steps: map[string]int{running: 2},
+			args:  []string{"task", "wait", running},
+			want:  []string{"SUCCEEDED", "100%"},
+		},
+		{
+			name:    "wait timeout",
+			args:    []string{"task", "wait", "--timeout", "1ms", running},
+			wantErr: "1 of 1 tasks did not succeed",
+		},
+		{
+			name:  "watch running tasks",
+			steps: map[string]int{running: 1, subtask: 1},
+			args:  []string{"task", "watch", "--interval", "1ms"},
+			want:  []string{running, "└ " + subtask, "[########............] 40%", "[####################] 100%"},
+		},
+		{
+			name:    "watch failed",
+			args:    []string{"task", "watch", "--interval", "1ms", failed},
+			wantErr: "1 of 1 tasks did not succeed",
+		},
+		{
+			name:    "watch timeout",
+			args:    []string{"task", "watch", "--interval", "1ms", "--timeout", "1ms", running},
+			wantErr: "timed out",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockPrism(t)
+			for uuid, steps := range tt.steps {
+				m.taskSteps[uuid] = steps
+			}
+
+			got, err := runCLI(t, m, tt.args...)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("run %v error = %v, wantErr %v", tt.args, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("run %v error = %v", tt.args, err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("run %v output missing %q\n%s", tt.args, want, got)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(got, notWant) {
+					t.Errorf("run %v output should not contain %q\n%s", tt.args, notWant, got)
+				}
+			}
+		})
+	}
+}
+
+func TestMutatingCommandsReportTasks(t *testing.T) {
+	setTestHome(t)
+
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{
+			name: "update-memory",
+			args: []string{"vm", "update-memory", "--wait", "web-02", "8000"},
+			want: []string{"task: 4a5b6c7d-8e9f-4a0b-9c1d-000000000001", "task 4a5b6c7d-8e9f-4a0b-9c1d-000000000001 succeeded"},
+		},
+		{
+			name: "image create",
+			args: []string{"--image-name", "rocky8", "--image-description", "rocky linux", "--image-type", "DISK_IMAGE", "--image-source", "http://images.local/rocky8.qcow2", "image", "create", "--wait"},
+			want: []string{"rocky8", "SUCCEEDED", "4a5b6c7d-8e9f-4a0b-9c1d-000000000001"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockPrism(t)
+
+			got, err := runCLI(t, m, tt.args...)
+			if err != nil {
+				t.Fatalf("run %v error = %v", tt.args, err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("run %v output missing %q\n%s", tt.args, want, got)
+				}
+			}
+			if gets := m.received("GET", pcPrefix+"tasks/4a5b6c7d-8e9f-4a0b-9c1d-000000000001"); len(gets) == 0 {
+				t.Errorf("run %v did not poll the task", tt.args)
+			}
+		})
+	}
+}
+
+func TestVMCreateValidation(t *testing.T) {
+	setTestHome(t)
+	m := newMockPrism(t)
+
+	invalid := strings.NewReplacer(
+		"name: app-01", "name: a",
+		"memory_size_mib: 2048", "memory_size_mib: 64",
+		"0005b1c2-3d4e-4f56-8a7b-9c0d1e2f3a41", "0005b1c2-3d4e-4f56-8a7b-9c0d1e2f3a99",
+	).Replace(testCreateVMYAML)
+
+	_, err := runCLI(t, m, "vm", "--vm-yaml", writeTestFile(t, "invalidvm.yaml", invalid), "create")
+	if err == nil {
+		t.Fatal("vm create accepted an invalid request")
+	}
+	for _, want := range []string{"spec.name", "memory_size_mib", "cluster 0005b1c2-3d4e-4f56-8a7b-9c0d1e2f3a99 does not exist"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("vm create error missing %q\n%v", want, err)
+		}
+	}
+
+	for _, r := range m.received("POST", pcPrefix+"vms") {
+		if r.Path == pcPrefix+"vms" {
+			t.Errorf("vm create sent the request despite validation errors")
+		}
+	}
+}
+
+func TestVMCreateTemplate(t *testing.T) {
+	setTestHome(t)
+	m := newMockPrism(t)
+
+	spec := strings.NewReplacer(
+		"name: app-01", "name: {{ .VM_NAME }}",
+		"uuid: 5e3a1b2c-4d5e-4f6a-8b7c-9d0e1f2a3b10", `uuid: "{{SUBNET_UUID}}"`,
+		"uuid: 6f4b2c3d-5e6f-4a7b-9c8d-0e1f2a3b4c81", `uuid: '{{ image "centos8" }}'`,
+		"uuid: 0005b1c2-3d4e-4f56-8a7b-9c0d1e2f3a41", `uuid: '{{ cluster "prod" }}'`,
+		"memory_size_mib: 2048", "memory_size_mib: {{ .MEMORY }}",
+	).Replace(testCreateVMYAML)
+	specFile := writeTestFile(t, "template.yaml", spec)
+	varFile := writeTestFile(t, "vars.yaml", "VM_NAME: from-file\nMEMORY: 4096\n")
+
+	_, err := runCLI(t, m, "vm", "--vm-yaml", specFile, "create", "--var-file", varFile, "--var", "VM_NAME=app-02", "--var", "SUBNET_UUID=5e3a1b2c-4d5e-4f6a-8b7c-9d0e1f2a3b20")
+	if err != nil {
+		t.Fatalf("vm create error = %v", err)
+	}
+
+	var create *mockRequest
+	for _, r := range m.received("POST", pcPrefix+"vms") {
+		if r.Path == pcPrefix+"vms" {
+			r := r
+			create = &r
+		}
+	}
+	if create == nil {
+		t.Fatal("vm create request was not sent")
+	}
+
+	body, _ := json.Marshal(create.Body)
+	for _, want := range []string{
+		`"name":"app-02"`,
+		`"memory_size_mib":4096`,
+		"5e3a1b2c-4d5e-4f6a-8b7c-9d0e1f2a3b20",
+		"6f4b2c3d-5e6f-4a7b-9c8d-0e1f2a3b4c81",
+		"0005b1c2-3d4e-4f56-8a7b-9c0d1e2f3a41",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("vm create request missing %s\n%s", want, body)
+		}
+	}
+
+	if _, err := runCLI(t, m, "vm", "--vm-yaml", specFile, "create"); err == nil || !strings.Contains(err.Error(), "SUBNET_UUID") {
+		t.Errorf("vm create with a missing variable error = %v", err)
+	}
+
+	prefixFile := writeTestFile(t, "prefix.yaml", strings.Replace(spec, `image "centos8"`, `image "cent"`, 1))
+	_, err = runCLI(t, m, "vm", "--vm-yaml", prefixFile, "create", "--var-file", varFile, "--var", "SUBNET_UUID=5e3a1b2c-4d5e-4f6a-8b7c-9d0e1f2a3b20")
+	if err == nil || !strings.Contains(err.Error(), `no image named "cent"`) {
+		t.Errorf("vm create with an image name prefix error = %v", err)
+	}
+}
+
+func TestVMCreateCloudInit(t *testing.T) {
+	setTestHome(t)
+
+	specDir := t.TempDir()
+	spec := strings.Replace(testCreateVMYAML, "    power_state: 'ON'\n", "    power_state: 'ON'\n    guest_customization:\n      cloud_init:\n        user_data_file: user-data.yaml\n", 1)
+	if err := ioutil.WriteFile(filepath.Join(specDir, "vm.yaml"), []byte(spec), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(specDir, "user-data.yaml"), []byte("#cloud-config\nhostname: from-spec\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	flagUserData := writeTestFile(t, "flag-user-data.yaml", "#cloud-config\nhostname: from-flag\n")
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "spec file key", args: []string{"vm", "--vm-yaml", filepath.Join(specDir, "vm.yaml"), "create"}, want: "hostname: from-spec"},
+		{name: "flag overrides spec", args: []string{"vm", "--vm-yaml", filepath.Join(specDir, "vm.yaml"), "create", "--cloud-init", flagUserData}, want: "hostname: from-flag"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockPrism(t)
+
+			if _, err := runCLI(t, m, tt.args...); err != nil {
+				t.Fatalf("run %v error = %v", tt.args, err)
+			}
+
+			for _, r := range m.received("POST", pcPrefix+"vms") {
+				if r.Path != pcPrefix+"vms" {
+					continue
+				}
+				resources := r.Body["spec"].(map[string]interface{})["resources"].(map[string]interface{})
+				cloudInit := resources["guest_customization"].(map[string]interface{})["cloud_init"].(map[string]interface{})
+				userData, _ := base64.StdEncoding.DecodeString(cloudInit["user_data"].(string))
+				if !strings.Contains(string(userData), tt.want) {
+					t.Errorf("user_data = %q, want %q", userData, tt.want)
+				}
+				if _, ok := cloudInit["user_data_file"]; ok {
+					t.Errorf("user_data_file was sent to Prism Central")
+				}
+				return
+			}
+			t.Errorf("vm create request was not sent")
+		})
+	}
+}
+
+const testApplyWebYAML = `
+spec:
+  name: web-01
+  cluster_reference:
+    kind: cluster
+    uuid: 0005b1c2-3d4e-4f56-8a7b-9c0d1e2f3a41
+  resources:
+    num_sockets: 2
+    num_vcpus_per_socket: 1
+    memory_size_mib: 8192
+    power_state: 'ON'
+    nic_list:
+    - subnet_reference:
+        kind: subnet
+        uuid: 5e3a1b2c-4d5e-4f6a-8b7c-9d0e1f2a3b10
+    disk_list:
+    - disk_size_mib: 20480
+      device_properties:
+        device_type: DISK
+        disk_address:
+          adapter_type: SCSI
+          device_index: 0
+metadata:
+  kind: vm
+  categories:
+    Environment: prod
+`
+
+func TestApply(t *testing.T) {
+	setTestHome(t)
+	m := newMockPrism(t)
+
+	specDir := t.TempDir()
+	for name, content := range map[string]string{
+		"app-01.yaml": testCreateVMYAML,
+		"web-01.yml":  testApplyWebYAML,
+		"notes.txt":   "not a spec",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(specDir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out, err := runCLI(t, m, "apply", "-f", specDir)
+	if err == nil || !strings.Contains(err.Error(), "apply cancelled") {
+		t.Fatalf("apply without confirmation error = %v", err)
+	}
+	for _, want := range []string{"APP-01", "web-01", "create", "update", "MEMORY_SIZE_MIB", "4096", "8192", "DISK SCSI.0 SIZE", "20480 MIB", "CATEGORY ENVIRONMENT", "prod"} {
+		if !strings.Contains(strings.ToUpper(out), strings.ToUpper(want)) {
+			t.Errorf("apply plan missing %q\n%s", want, out)
+		}
+	}
+	if got := len(m.received("PUT", pcPrefix+"vms/1b0e2a47-5d8c-4e0f-9a31-6f2d8c4b7e01")); got != 0 {
+		t.Fatalf("cancelled apply sent %d updates", got)
+	}
+
+	out, err = runCLI(t, m, "apply", "-f", specDir, "--yes")
+	if err != nil {
+		t.Fatalf("apply error = %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "app-01: create submitted") || !strings.Contains(out, "web-01: update submitted") {
+		t.Errorf("apply output missing submitted VMs\n%s", out)
+	}
+
+	puts := m.received("PUT", pcPrefix+"vms/1b0e2a47-5d8c-4e0f-9a31-6f2d8c4b7e01")
+	if len(puts) != 1 {
+		t.Fatalf("apply sent %d updates for web-01, want 1", len(puts))
+	}
+	body, _ := json.Marshal(puts[0].Body)
+	for _, want := range []string{`"memory_size_mib":8192`, `"disk_size_mib":20480`, `"categories":{"Environment":"prod"}`, `"mac_address":"50:6b:8d:00:00:01"`, `"device_type":"CDROM"`} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("web-01 update missing %s\n%s", want, body)
+		}
+	}
+
+	out, err = runCLI(t, m, "apply", "-f", specDir)
+	if err != nil {
+		t.Fatalf("second apply error = %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "no changes to apply") {
+		t.Errorf("second apply should have no changes\n%s", out)
+	}
+
+	shrink := writeTestFile(t, "shrink.yaml", strings.Replace(testApplyWebYAML, "disk_size_mib: 20480", "disk_size_mib: 1024", 1))
+	if _, err := runCLI(t, m, "apply", "-f", shrink, "--yes"); err == nil || !strings.Contains(err.Error(), "cannot shrink") {
+		t.Errorf("apply with a smaller disk error = %v", err)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	setTestHome(t)
+	m := newMockPrism(t)
+
+	createFile := writeTestFile(t, "createvm.yaml", testCreateVMYAML)
+	applyFile := writeTestFile(t, "web-01.yaml", testApplyWebYAML)
+
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{
+			name: "update memory",
+			args: []string{"--dry-run", "vm", "update-memory", "web-01", "8192"},
+			want: []string{"--- current", "+++ proposed PUT vms/1b0e2a47-5d8c-4e0f-9a31-6f2d8c4b7e01", `-      "memory_size_mib": 4096,`, `+      "memory_size_mib": 7812,`},
+		},
+		{
+			name: "power off",
+			args: []string{"--dry-run", "vm", "update-power", "web-01", "OFF"},
+			want: []string{`-      "power_state": "ON"`, `+      "power_state": "OFF"`},
+		},
+		{
+			name: "power transition",
+			args: []string{"--dry-run", "vm", "update-power", "web-01", "ACPI_REBOOT"},
+			want: []string{"+++ proposed POST vms/1b0e2a47-5d8c-4e0f-9a31-6f2d8c4b7e01/set_power_state", `+  "transition": "ACPI_REBOOT"`},
+		},
+		{
+			name: "vm create",
+			args: []string{"--dry-run", "vm", "--vm-yaml", createFile, "create"},
+			want: []string{"+++ proposed POST vms", `+    "name": "app-01",`},
+		},
+		{
+			name: "image create",
+			args: []string{"--dry-run", "--image-name", "rocky8", "--image-description", "rocky linux", "--image-type", "DISK_IMAGE", "--image-source", "http://images.local/rocky8.qcow2", "image", "create"},
+			want: []string{"+++ proposed POST images", `+      "source_uri": "http://images.local/rocky8.qcow2"`},
+		},
+		{
+			name: "apply",
+			args: []string{"--dry-run", "apply", "-f", applyFile},
+			want: []string{"memory_size_mib", `+      "Environment": "prod"`},
+		},
+		{
+			name: "snapshot delete",
+			args: []string{"--dry-run", "vm", "snapshot", "delete", "web-01", "8b2d3e4f-5a6b-4c7d-8e9f-0a1b2c3d4e03"},
+			want: []string{"dry run: DELETE snapshots/8b2d3e4f-5a6b-4c7d-8e9f-0a1b2c3d4e03"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := runCLI(t, m, tt.args...)
+			if err != nil {
+				t.Fatalf("error = %v\n%s", err, out)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("output missing %q\n%s", want, out)
+				}
+			}
+		})
+	}
+
+	for _, method := range []string{"POST", "PUT", "DELETE"} {
+		for _, r := range m.received(method, "") {
+			if !strings.HasSuffix(r.Path, "/list") {
+				t.Errorf("dry run sent %s %s", method, r.Path)
+			}
+		}
+	}
+}
+
+func TestVMExport(t *testing.T) {
+	setTestHome(t)
+	m := newMockPrism(t)
+
+	out, err := runCLI(t, m, "vm", "export", "web-01")
+	if err != nil {
+		t.Fatalf("vm export error = %v", err)
+	}
+	for _, want := range []string{"name: web-01", "uuid: 5e3a1b2c-4d5e-4f6a-8b7c-9d0e1f2a3b10", "memory_size_mib: 4096", "kind: vm"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("vm export missing %q\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{"status:", "spec_version", "creation_time", "mac_address", "1b0e2a47-5d8c-4e0f-9a31-6f2d8c4b7e01", "d10e2a47-5d8c-4e0f-9a31-6f2d8c4b7e11", "a10e2a47-5d8c-4e0f-9a31-6f2d8c4b7e21", "disk_size_bytes"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("vm export should not contain %q\n%s", unwanted, out)
+		}
+	}
+
+	specFile := filepath.Join(t.TempDir(), "web-01.yaml")
+	if _, err := runCLI(t, m, "vm", "export", "--templatize", "--file", specFile, "web-01"); err != nil {
+		t.Fatalf("vm export --templatize error = %v", err)
+	}
+	raw, err := ioutil.ReadFile(specFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`{{ subnet "prod-vlan10" }}`, `{{ image "centos8" }}`, `{{ cluster "prod" }}`} {
+		if !strings.Contains(string(raw), want) {
+			t.Errorf("templatized export missing %q\n%s", want, raw)
+		}
+	}
+
+	if out, err := runCLI(t, m, "vm", "--vm-yaml", specFile, "create"); err != nil {
+		t.Fatalf("vm create from export error = %v\n%s", err, out)
+	}
+}
+
+func TestVMClone(t *testing.T) {
+	setTestHome(t)
+	oldInterval := taskPollInterval
+	taskPollInterval = time.Millisecond
+	t.Cleanup(func() { taskPollInterval = oldInterval })
+
+	m := newMockPrism(t)
+	userData := writeTestFile(t, "user-data.yaml", "#cloud-config\nhostname: clone\n")
+
+	out, err := runCLI(t, m, "vm", "clone", "--name", "web-{{.Index}}", "--count", "3", "--start-index", "2", "--memory", "8192", "--vcpus", "4", "--subnet", "prod-vlan10", "--cloud-init", userData, "--wait", "web-01")
+	if err != nil {
+		t.Fatalf("vm clone error = %v\n%s", err, out)
+	}
+	for _, want := range []string{"web-2", "web-3", "web-4", "SUCCEEDED"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("vm clone output missing %q\n%s", want, out)
+		}
+	}
+
+	clones := m.received("POST", pcPrefix+"vms/1b0e2a47-5d8c-4e0f-9a31-6f2d8c4b7e01/clone")
+	if len(clones) != 3 {
+		t.Fatalf("vm clone sent %d requests, want 3", len(clones))
+	}
+	body, _ := json.Marshal(clones[0].Body)
+	for _, want := range []string{`"name":"web-2"`, `"memory_size_mib":8192`, `"num_sockets":4`, `"num_vcpus_per_socket":1`, "5e3a1b2c-4d5e-4f6a-8b7c-9d0e1f2a3b10", base64.StdEncoding.EncodeToString([]byte("#cloud-config\nhostname: clone\n"))} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("vm clone request missing %s\n%s", want, body)
+		}
+	}
+
+	if out, err := runCLI(t, m, "vm", "list"); err != nil || !strings.Contains(out, "web-3") {
+		t.Errorf("vm list should include the clones. error = %v\n%s", err, out)
+	}
+
+	m.rejectNames["app-2"] = true
+	out, err = runCLI(t, m, "vm", "clone", "--name", "app-{{.Index}}", "--count", "3", "--wait", "web-01")
+	if err == nil || !strings.Contains(err.Error(), "1 of 3 clones did not succeed") {
+		t.Errorf("vm clone with a rejected clone error = %v", err)
+	}
+	for _, want := range []string{"app-1", "app-3", "FAILED", "422", "SUCCEEDED"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("vm clone result missing %q\n%s", want, out)
+		}
+	}
+
+	m.taskStatus = "FAILED"
+	if _, err := runCLI(t, m, "vm", "clone", "--name", "db-{{.Index}}", "--count", "2", "--wait", "web-01"); err == nil || !strings.Contains(err.Error(), "2 of 2 clones did not succeed") {
+		t.Errorf("vm clone with failed tasks error = %v", err)
+	}
+}
+
+func TestVMDelete(t *testing.T) {
+	setTestHome(t)
+	oldInterval := taskPollInterval
+	taskPollInterval = time.Millisecond
+	t.Cleanup(func() { taskPollInterval = oldInterval })
+
+	const web01 = "1b0e2a47-5d8c-4e0f-9a31-6f2d8c4b7e01"
+
+	m := newMockPrism(t)
+
+	out, err := runCLI(t, m, "vm", "delete", "web-01")
+	if err == nil || !strings.Contains(err.Error(), "delete cancelled") {
+		t.Fatalf("vm delete without confirmation error = %v", err)
+	}
+	for _, want := range []string{"web-01", web01, "prod", "ON", "delete 1 VMs? [y/N]"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("vm delete confirmation missing %q\n%s", want, out)
+		}
+	}
+
+	for _, loose := range []string{"web-0", "db*"} {
+		if _, err := runCLI(t, m, "vm", "delete", "--force", loose); err == nil || !strings.Contains(err.Error(), "use the exact name or the UUID") {
+			t.Errorf("vm delete %s error = %v", loose, err)
+		}
+	}
+
+	if _, err := runCLI(t, m, "vm", "delete", "--force", "web-01", "db-01"); err == nil || !strings.Contains(err.Error(), "db-01 (Protected:true)") {
+		t.Errorf("vm delete of a protected VM error = %v", err)
+	}
+	if _, err := runCLI(t, m, "--protected-category", "Tier:gold", "vm", "delete", "--force", "db-01"); err != nil {
+		t.Errorf("vm delete with other protected categories error = %v", err)
+	}
+
+	if _, err := runCLI(t, m, "--dry-run", "vm", "delete", "--force", "web-01"); err != nil {
+		t.Errorf("vm delete --dry-run error = %v", err)
+	}
+	if got := len(m.received("DELETE", pcPrefix+"vms/"+web01)); got != 0 {
+		t.Fatalf("cancelled and dry run deletes sent %d requests", got)
+	}
+
+	out, err = runCLI(t, m, "vm", "delete", "--force", "--power-off-first", "--wait", "web-01")
+	if err != nil {
+		t.Fatalf("vm delete error = %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "vm web-01 deleted") {
+		t.Errorf("vm delete output missing confirmation\n%s", out)
+	}
+	if !strings.Contains(out, "\ntask: 4a5b6c7d") {
+		t.Errorf("vm delete output missing the task line\n%s", out)
+	}
+
+	puts := m.received("PUT", pcPrefix+"vms/"+web01)
+	if len(puts) != 1 {
+		t.Fatalf("vm delete --power-off-first sent %d updates, want 1", len(puts))
+	}
+	if body, _ := json.Marshal(puts[0].Body); !strings.Contains(string(body), `"power_state":"OFF"`) {
+		t.Errorf("power off before delete sent %s", body)
+	}
+	if got := len(m.received("DELETE", pcPrefix+"vms/"+web01)); got != 1 {
+		t.Errorf("vm delete sent %d delete requests, want 1", got)
+	}
+}
+
+func TestVMUpdateResources(t *testing.T) {
+	setTestHome(t)
+	oldInterval := taskPollInterval
+	taskPollInterval = time.Millisecond
+	t.Cleanup(func() { taskPollInterval = oldInterval })
+
+	const web01 = "1b0e2a47-5d8c-4e0f-9a31-6f2d8c4b7e01"
+
+	resources := func(r mockRequest) map[string]interface{} {
+		spec, _ := r.Body["spec"].(map[string]interface{})
+		res, _ := spec["resources"].(map[string]interface{})
+		return res
+	}
+
+	t.Run("hot add", func(t *testing.T) {
+		m := newMockPrism(t)
+		out, err := runCLI(t, m, "vm", "update", "--memory", "8GiB", "--vcpus", "4", "web-01")
+		if err != nil {
+			t.Fatalf("vm update error = %v\n%s", err, out)
+		}
+
+		puts := m.received("PUT", pcPrefix+"vms/"+web01)
+		if len(puts) != 1 {
+			t.Fatalf("vm update sent %d updates, want 1", len(puts))
+		}
+		res := resources(puts[0])
+		if res["memory_size_mib"] != float64(8192) || res["num_sockets"] != float64(4) || res["num_vcpus_per_socket"] != float64(1) || res["power_state"] != "ON" {
+			t.Errorf("vm update resources = %v", res)
+		}
+	})
+
+	t.Run("declined power cycle", func(t *testing.T) {
+		m := newMockPrism(t)
+		out, err := runCLI(t, m, "vm", "update-cpu", "--cores-per-socket", "2", "web-01")
+		if err == nil || !strings.Contains(err.Error(), "update cancelled") {
+			t.Fatalf("vm update-cpu error = %v", err)
+		}
+		if !strings.Contains(out, "cores per socket cannot change on a running VM (1 to 2)") {
+			t.Errorf("vm update-cpu output missing the hot add blocker\n%s", out)
+		}
+		if got := len(m.received("PUT", pcPrefix+"vms/"+web01)); got != 0 {
+			t.Errorf("declined update sent %d updates", got)
+		}
+	})
+
+	t.Run("power cycle", func(t *testing.T) {
+		m := newMockPrism(t)
+		out, err := runCLI(t, m, "vm", "update-cpu", "--sockets", "1", "--cores-per-socket", "2", "--power-cycle", "web-01")
+		if err != nil {
+			t.Fatalf("vm update-cpu error = %v\n%s", err, out)
+		}
+
+		puts := m.received("PUT", pcPrefix+"vms/"+web01)
+		if len(puts) != 3 {
+			t.Fatalf("power cycled update sent %d updates, want 3", len(puts))
+		}
+		if res := resources(puts[0]); res["power_state"] != "OFF" || res["num_sockets"] != float64(2) {
+			t.Errorf("first update should only power off. resources = %v", res)
+		}
+		if res := resources(puts[1]); res["power_state"] != "OFF" || res["num_sockets"] != float64(1) || res["num_vcpus_per_socket"] != float64(2) {
+			t.Errorf("second update should change the CPU. resources = %v", res)
+		}
+		if res := resources(puts[2]); res["power_state"] != "ON" || res["num_vcpus_per_socket"] != float64(2) {
+			t.Errorf("third update should power on. resources = %v", res)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		m := newMockPrism(t)
+		for _, args := range [][]string{
+			{"vm", "update", "--memory", "8PB", "web-01"},
+			{"vm", "update", "--memory", "100MiB", "web-01"},
+			{"vm", "update", "--memory", "0", "web-01"},
+			{"vm", "update-memory", "web-01", "0"},
+			{"vm", "update-cpu", "--sockets", "64", "--cores-per-socket", "8", "web-01"},
+			{"vm", "update", "web-01"},
+		} {
+			if _, err := runCLI(t, m, args...); err == nil {
+				t.Errorf("%v should fail", args)
+			}
+		}
+		if got := len(m.received("PUT", pcPrefix+"vms/")); got != 0 {
+			t.Errorf("invalid updates sent %d requests", got)
+		}
+	})
+}
+
+func TestVMDisk(t *testing.T) {
+	setTestHome(t)
+
+	const web01 = "1b0e2a47-5d8c-4e0f-9a31-6f2d8c4b7e01"
+
+	disks := func(t *testing.T, m *mockPrism) []interface{} {
+		puts := m.received("PUT", pcPrefix+"vms/"+web01)
+		if len(puts) != 1 {
+			t.Fatalf("disk command sent %d updates, want 1", len(puts))
+		}
+		spec, _ := puts[0].Body["spec"].(map[string]interface{})
+		res, _ := spec["resources"].(map[string]interface{})
+		list, _ := res["disk_list"].([]interface{})
+		return list
+	}
+
+	t.Run("add", func(t *testing.T) {
+		m := newMockPrism(t)
+		out, err := runCLI(t, m, "vm", "disk", "add", "--size", "100GiB", "--container", "default-container", "web-01")
+		if err != nil {
+			t.Fatalf("vm disk add error = %v\n%s", err, out)
+		}
+		if !strings.Contains(out, "disk SCSI.1 of 102400 MiB added to web-01") {
+			t.Errorf("vm disk add output = %s", out)
+		}
+
+		list := disks(t, m)
+		if len(list) != 3 {
+			t.Fatalf("vm disk add sent %d disks, want 3", len(list))
+		}
+		added, _ := list[2].(map[string]interface{})
+		storage, _ := added["storage_config"].(map[string]interface{})
+		container, _ := storage["storage_container_reference"].(map[string]interface{})
+		if added["disk_size_mib"] != float64(102400) || container["uuid"] != "7a1c2d3e-4f50-4617-8a29-3b4c5d6e7f01" {
+			t.Errorf("added disk = %v", added)
+		}
+	})
+
+	t.Run("index collision", func(t *testing.T) {
+		m := newMockPrism(t)
+		_, err := runCLI(t, m, "vm", "disk", "add", "--size", "10GiB", "--index", "0", "web-01")
+		if err == nil || !strings.Contains(err.Error(), "device index SCSI.0 is already used") {
+			t.Fatalf("vm disk add error = %v", err)
+		}
+		if got := len(m.received("PUT", pcPrefix+"vms/"+web01)); got != 0 {
+			t.Errorf("colliding add sent %d updates", got)
+		}
+	})
+
+	t.Run("attach image", func(t *testing.T) {
+		m := newMockPrism(t)
+		out, err := runCLI(t, m, "vm", "disk", "attach-image", "--image", "centos8", "--bus", "sata", "web-01")
+		if err != nil {
+			t.Fatalf("vm disk attach-image error = %v\n%s", err, out)
+		}
+
+		list := disks(t, m)
+		added, _ := list[len(list)-1].(map[string]interface{})
+		source, _ := added["data_source_reference"].(map[string]interface{})
+		if source["uuid"] != "6f4b2c3d-5e6f-4a7b-9c8d-0e1f2a3b4c81" || !strings.Contains(out, "disk SATA.0 from image centos8") {
+			t.Errorf("attached disk = %v\n%s", added, out)
+		}
+	})
+
+	t.Run("resize", func(t *testing.T) {
+		m := newMockPrism(t)
+		out, err := runCLI(t, m, "vm", "disk", "resize", "--size", "20GiB", "web-01", "0")
+		if err != nil {
+			t.Fatalf("vm disk resize error = %v\n%s", err, out)
+		}
+
+		boot, _ := disks(t, m)[0].(map[string]interface{})
+		if boot["disk_size_mib"] != float64(20480) || boot["disk_size_bytes"] != nil {
+			t.Errorf("resized disk = %v", boot)
+		}
+	})
+
+	t.Run("shrink", func(t *testing.T) {
+		m := newMockPrism(t)
+		_, err := runCLI(t, m, "vm", "disk", "resize", "--size", "5GiB", "web-01", "SCSI.0")
+		if err == nil || !strings.Contains(err.Error(), "refusing to shrink disk from 10240 to 5120 MiB") {
+			t.Fatalf("vm disk resize error = %v", err)
+		}
+	})
+
+	t.Run("detach boot disk", func(t *testing.T) {
+		m := newMockPrism(t)
+		_, err := runCLI(t, m, "vm", "disk", "detach", "--force", "web-01", "0")
+		if err == nil || !strings.Contains(err.Error(), "boot device") {
+			t.Fatalf("vm disk detach error = %v", err)
+		}
+	})
+
+	t.Run("detach declined", func(t *testing.T) {
+		m := newMockPrism(t)
+		out, err := runCLI(t, m, "vm", "disk", "detach", web01, "d10e2a47-5d8c-4e0f-9a31-6f2d8c4b7e99")
+		if err == nil || !strings.Contains(err.Error(), "detach cancelled") {
+			t.Fatalf("vm disk detach error = %v", err)
+		}
+		if !strings.Contains(out, "detach disk IDE.0 from web-01?") {
+			t.Errorf("vm disk detach prompt does not name the resolved VM and disk\n%s", out)
+		}
+		if got := len(m.received("PUT", pcPrefix+"vms/")); got != 0 {
+			t.Errorf("declined detach sent %d requests", got)
+		}
+	})
+
+	t.Run("detach missing VM", func(t *testing.T) {
+		m := newMockPrism(t)
+		out, err := runCLI(t, m, "vm", "disk", "detach", "app-01", "1")
+		if err == nil || strings.Contains(out, "detach disk") {
+			t.Fatalf("vm disk detach of a missing VM asked for confirmation. error = %v\n%s", err, out)
+		}
+	})
+
+	t.Run("detach", func(t *testing.T) {
+		m := newMockPrism(t)
+		out, err := runCLI(t, m, "vm", "disk", "detach", "--force", "web-01", "d10e2a47-5d8c-4e0f-9a31-6f2d8c4b7e99")
+		if err != nil {
+			t.Fatalf("vm disk detach error = %v\n%s", err, out)
+		}
+		if list := disks(t, m); len(list) != 1 {
+			t.Errorf("vm disk detach left %d disks, want 1", len(list))
+		}
+	})
+}
+
+func TestVMNIC(t *testing.T) {
+	setTestHome(t)
+
+	const web01 = "1b0e2a47-5d8c-4e0f-9a31-6f2d8c4b7e01"
+
+	nics := func(t *testing.T, m *mockPrism) []interface{} {
+		puts := m.received("PUT", pcPrefix+"vms/"+web01)
+		if len(puts) != 1 {
+			t.Fatalf("nic command sent %d updates, want 1", len(puts))
+		}
+		spec, _ := puts[0].Body["spec"].(map[string]interface{})
+		res, _ := spec["resources"].(map[string]interface{})
+		list, _ := res["nic_list"].([]interface{})
+		return list
+	}
+
+	t.Run("add", func(t *testing.T) {
+		m := newMockPrism(t)
+		out, err := runCLI(t, m, "vm", "nic", "add", "--subnet", "dev-vlan20", "--ip", "10.20.0.50", "--mac", "50:6B:8D:00:00:10", "web-01")
+		if err != nil {
+			t.Fatalf("vm nic add error = %v\n%s", err, out)
+		}
+		if !strings.Contains(out, "NIC added to web-01 on dev-vlan20 with IP 10.20.0.50") {
+			t.Errorf("vm nic add output = %s", out)
+		}
+
+		list := nics(t, m)
+		if len(list) != 2 {
+			t.Fatalf("vm nic add sent %d NICs, want 2", len(list))
+		}
+		added, _ := list[1].(map[string]interface{})
+		subnet, _ := added["subnet_reference"].(map[string]interface{})
+		if subnet["uuid"] != "5e3a1b2c-4d5e-4f6a-8b7c-9d0e1f2a3b20" || added["mac_address"] != "50:6b:8d:00:00:10" {
+			t.Errorf("added NIC = %v", added)
+		}
+	})
+
+	t.Run("ip outside subnet", func(t *testing.T) {
+		m := newMockPrism(t)
+		_, err := runCLI(t, m, "vm", "nic", "add", "--subnet", "dev-vlan20", "--ip", "10.10.0.50", "web-01")
+		if err == nil || !strings.Contains(err.Error(), "outside subnet dev-vlan20") {
+			t.Fatalf("vm nic add error = %v", err)
+		}
+	})
+
+	t.Run("ip in use", func(t *testing.T) {
+		m := newMockPrism(t)
+		_, err := runCLI(t, m, "vm", "nic", "add", "--subnet", "prod-vlan10", "--ip", "10.10.0.12", "web-01")
+		if err == nil || !strings.Contains(err.Error(), "IP 10.10.0.12 is already assigned to web-02") {
+			t.Fatalf("vm nic add error = %v", err)
+		}
+		if got := len(m.received("PUT", pcPrefix+"vms/"+web01)); got != 0 {
+			t.Errorf("rejected add sent %d updates", got)
+		}
+	})
+
+	t.Run("update subnet", func(t *testing.T) {
+		m := newMockPrism(t)
+		out, err := runCLI(t, m, "vm", "nic", "update", "--subnet", "dev-vlan20", "web-01", "50:6b:8d:00:00:01")
+		if err != nil {
+			t.Fatalf("vm nic update error = %v\n%s", err, out)
+		}
+
+		nic, _ := nics(t, m)[0].(map[string]interface{})
+		subnet, _ := nic["subnet_reference"].(map[string]interface{})
+		if subnet["uuid"] != "5e3a1b2c-4d5e-4f6a-8b7c-9d0e1f2a3b20" || nic["ip_endpoint_list"] != nil || nic["uuid"] != "a10e2a47-5d8c-4e0f-9a31-6f2d8c4b7e21" {
+			t.Errorf("updated NIC = %v", nic)
+		}
+	})
+
+	t.Run("update keeps own ip", func(t *testing.T) {
+		m := newMockPrism(t)
+		out, err := runCLI(t, m, "vm", "nic", "update", "--ip", "10.10.0.11", "web-01", "a10e2a47-5d8c-4e0f-9a31-6f2d8c4b7e21")
+		if err != nil {
+			t.Fatalf("vm nic update error = %v\n%s", err, out)
+		}
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		m := newMockPrism(t)
+		out, err := runCLI(t, m, "vm", "nic", "remove", "web-01", "a10e2a47-5d8c-4e0f-9a31-6f2d8c4b7e21")
+		if err != nil {
+			t.Fatalf("vm nic remove error = %v\n%s", err, out)
+		}
+		if list := nics(t, m); len(list) != 0 {
+			t.Errorf("vm nic remove left %d NICs", len(list))
+		}
+	})
+}
+
+func TestVMCDROM(t *testing.T) {
+	setTestHome(t)
+
+	const web01 = "1b0e2a47-5d8c-4e0f-9a31-6f2d8c4b7e01"
+
+	m := newMockPrism(t)
+
+	if _, err := runCLI(t, m, "vm", "cdrom", "mount", "--image", "centos8", "web-01"); err == nil || !strings.Contains(err.Error(), "not an ISO_IMAGE") {
+		t.Fatalf("mounting a disk image error = %v", err)
+	}
+
+	out, err := runCLI(t, m, "vm", "cdrom", "mount", "--image", "centos8-iso", "web-01")
+	if err != nil {
+		t.Fatalf("vm cdrom mount error = %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "image centos8-iso mounted on CD-ROM IDE.0 of web-01") {
+		t.Errorf("vm cdrom mount output = %s", out)
+	}
+
+	puts := m.received("PUT", pcPrefix+"vms/"+web01)
+	if len(puts) != 1 {
+		t.Fatalf("vm cdrom mount sent %d updates, want 1", len(puts))
+	}
+	spec, _ := puts[0].Body["spec"].(map[string]interface{})
+	res, _ := spec["resources"].(map[string]interface{})
+	disks, _ := res["disk_list"].([]interface{})
+	cdrom, _ := disks[1].(map[string]interface{})
+	source, _ := cdrom["data_source_reference"].(map[string]interface{})
+	if source["uuid"] != "6f4b2c3d-5e6f-4a7b-9c8d-0e1f2a3b4c83" {
+		t.Errorf("mounted CD-ROM = %v", cdrom)
+	}
+
+	out, err = runCLI(t, m, "vm", "cdrom", "eject", "web-01")
+	if err != nil {
+		t.Fatalf("vm cdrom eject error = %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "CD-ROM IDE.0 of web-01 ejected") {
+		t.Errorf("vm cdrom eject output = %s", out)
+	}
+
+	if _, err := runCLI(t, m, "vm", "cdrom", "eject", "web-01"); err == nil || !strings.Contains(err.Error(), "no CD-ROM has media mounted") {
+		t.Errorf("second eject error = %v", err)
+	}
+}
+
+func TestVMSnapshot(t *testing.T) {
+	setTestHome(t)
+
+	const web01 = "1b0e2a47-5d8c-4e0f-9a31-6f2d8c4b7e01"
+
+	t.Run("list", func(t *testing.T) {
+		m := newMockPrism(t)
+		out, err := runCLI(t, m, "vm", "snapshot", "list")
+		if err != nil {
+			t.Fatalf("vm snapshot list error = %v\n%s", err, out)
+		}
+		for _, want := range []string{"before-upgrade", "pre-patch", "2023-11-14T22:13:20Z", "TOTAL"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("vm snapshot list output missing %q\n%s", want, out)
+			}
+		}
+		if strings.Contains(out, " old ") {
+			t.Errorf("vm snapshot list shows deleted snapshots\n%s", out)
+		}
+
+		out, err = runCLI(t, m, "vm", "snapshot", "list", "db-01")
+		if err != nil {
+			t.Fatalf("vm snapshot list db-01 error = %v\n%s", err, out)
+		}
+		if strings.Contains(out, "pre-patch") || !strings.Contains(out, "before-upgrade") {
+			t.Errorf("vm snapshot list db-01 output\n%s", out)
+		}
+	})
+
+	t.Run("create", func(t *testing.T) {
+		m := newMockPrism(t)
+		out, err := runCLI(t, m, "vm", "snapshot", "create", "--name", "pre-patch-2", "--wait", "web-01")
+		if err != nil {
+			t.Fatalf("vm snapshot create error = %v\n%s", err, out)
+		}
+
+		posts := m.received("POST", pePrefix+"snapshots")
+		if len(posts) != 1 {
+			t.Fatalf("vm snapshot create sent %d requests, want 1", len(posts))
+		}
+		specs, _ := posts[0].Body["snapshot_specs"].([]interface{})
+		spec, _ := specs[0].(map[string]interface{})
+		if spec["vm_uuid"] != web01 || spec["snapshot_name"] != "pre-patch-2" {
+			t.Errorf("snapshot spec = %v", spec)
+		}
+	})
+
+	t.Run("create default name", func(t *testing.T) {
+		m := newMockPrism(t)
+		out, err := runCLI(t, m, "vm", "snapshot", "create", web01)
+		if err != nil {
+			t.Fatalf("vm snapshot create error = %v\n%s", err, out)
+		}
+
+		posts := m.received("POST", pePrefix+"snapshots")
+		if len(posts) != 1 {
+			t.Fatalf("vm snapshot create sent %d requests, want 1", len(posts))
+		}
+		specs, _ := posts[0].Body["snapshot_specs"].([]interface{})
+		spec, _ := specs[0].(map[string]interface{})
+		if name, _ := spec["snapshot_name"].(string); !strings.HasPrefix(name, "web-01-") {
+			t.Errorf("default snapshot name = %q, want it named after the VM", name)
+		}
+		if !strings.Contains(out, " of web-01 submitted") {
+			t.Errorf("vm snapshot create output missing the VM name\n%s", out)
+		}
+	})
+
+	t.Run("restore declined", func(t *testing.T) {
+		m := newMockPrism(t)
+		_, err := runCLI(t, m, "vm", "snapshot", "restore", "web-01", "pre-patch")
+		if err == nil || !strings.Contains(err.Error(), "restore cancelled") {
+			t.Fatalf("vm snapshot restore error = %v", err)
+		}
+		if got := len(m.received("POST", pePrefix+"vms/"+web01+"/restore")); got != 0 {
+			t.Errorf("declined restore sent %d requests", got)
+		}
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		m := newMockPrism(t)
+		out, err := runCLI(t, m, "vm", "snapshot", "restore", "--force", "--wait", web01, "pre-patch")
+		if err != nil {
+			t.Fatalf("vm snapshot restore error = %v\n%s", err, out)
+		}
+		if !strings.Contains(out, "restore of web-01 to snapshot pre-patch submitted") {
+			t.Errorf("vm snapshot restore output missing the VM name\n%s", out)
+		}
+		posts := m.received("POST", pePrefix+"vms/"+web01+"/restore")
+		if len(posts) != 1 || posts[0].Body["snapshot_uuid"] != "8b2d3e4f-5a6b-4c7d-8e9f-0a1b2c3d4e01" {
+			t.Errorf("restore requests = %v", posts)
+		}
+	})
+
+	t.Run("ambiguous name", func(t *testing.T) {
+		m := newMockPrism(t)
+		_, err := runCLI(t, m, "vm", "snapshot", "restore", "--force", "web-01", "nightly")
+		if err == nil || !strings.Contains(err.Error(), "2 snapshots are named nightly") {
+			t.Fatalf("vm snapshot restore error = %v", err)
+		}
+	})
+
+	t.Run("clone", func(t *testing.T) {
+		m := newMockPrism(t)
+		out, err := runCLI(t, m, "vm", "snapshot", "clone", "--name", "web-01-rebuild", "web-01", "pre-patch")
+		if err != nil {
+			t.Fatalf("vm snapshot clone error = %v\n%s", err, out)
+		}
+
+		posts := m.received("POST", pePrefix+"vms")
+		if len(posts) != 1 {
+			t.Fatalf("vm snapshot clone sent %d requests, want 1", len(posts))
+		}
+		disks, _ := posts[0].Body["vm_disks"].([]interface{})
+		if posts[0].Body["name"] != "web-01-rebuild" || len(disks) != 1 {
+			t.Errorf("clone spec = %v", posts[0].Body)
+		}
+		nics, _ := posts[0].Body["vm_nics"].([]interface{})
+		if len(nics) != 1 {
+			t.Fatalf("clone NICs = %v", posts[0].Body["vm_nics"])
+		}
+		nic, _ := nics[0].(map[string]interface{})
+		if _, ok := nic["mac_address"]; ok {
+			t.Errorf("clone NIC keeps the source MAC address: %v", nic)
+		}
+		if _, ok := nic["requested_ip_address"]; ok {
+			t.Errorf("clone NIC keeps the source IP address: %v", nic)
+		}
+		if nic["network_uuid"] != "5e3a1b2c-4d5e-4f6a-8b7c-9d0e1f2a3b10" {
+			t.Errorf("clone NIC lost its network: %v", nic)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		m := newMockPrism(t)
+		out, err := runCLI(t, m, "vm", "snapshot", "delete", "--force", "web-01", "8b2d3e4f-5a6b-4c7d-8e9f-0a1b2c3d4e03")
+		if err != nil {
+			t.Fatalf("vm snapshot delete error = %v\n%s", err, out)
+		}
+		if got := len(m.received("DELETE", pePrefix+"snapshots/8b2d3e4f-5a6b-4c7d-8e9f-0a1b2c3d4e03")); got != 1 {
+			t.Errorf("vm snapshot delete sent %d requests, want 1", got)
+		}
+	})
+}
+
+func TestBulkSelector(t *testing.T) {
+	setTestHome(t)
+	oldInterval := taskPollInterval
+	taskPollInterval = time.Millisecond
+	t.Cleanup(func() { taskPollInterval = oldInterval })
+
+	const (
+		web01 = "1b0e2a47-5d8c-4e0f-9a31-6f2d8c4b7e01"
+		web02 = "2c1f3b58-6e9d-4f10-8b42-7a3e9d5c8f02"
+	)
+
+	t.Run("power declined", func(t *testing.T) {
+		m := newMockPrism(t)
+		out, err := runCLI(t, m, "vm", "update-power", "--selector", "name=web-*", "OFF")
+		if err == nil || !strings.Contains(err.Error(), "power OFF cancelled") {
+			t.Fatalf("bulk update-power error = %v", err)
+		}
+		if !strings.Contains(out, "web-01") || !strings.Contains(out, "web-02") || strings.Contains(out, "db-01") {
+			t.Errorf("bulk update-power did not list the matched VMs\n%s", out)
+		}
+		if got := len(m.received("PUT", pcPrefix+"vms/")); got != 0 {
+			t.Errorf("declined bulk update-power sent %d updates", got)
+		}
+	})
+
+	t.Run("power", func(t *testing.T) {
+		m := newMockPrism(t)
+		out, err := runCLI(t, m, "vm", "update-power", "--selector", "name=web-*,cluster=prod", "--parallel", "2", "--force", "--wait", "OFF")
+		if err != nil {
+			t.Fatalf("bulk update-power error = %v\n%s", err, out)
+		}
+
+		for _, uuid := range []string{web01, web02} {
+			if got := len(m.received("PUT", pcPrefix+"vms/"+uuid)); got != 1 {
+				t.Errorf("bulk update-power sent %d updates to %s, want 1", got, uuid)
+			}
+		}
+		lists := m.received("POST", pcPrefix+"vms/list")
+		if len(lists) == 0 || lists[0].Body["filter"] != "cluster_name==prod" {
+			t.Errorf("bulk update-power list requests = %v", lists)
+		}
+		if strings.Count(out, "SUCCEEDED") != 2 {
+			t.Errorf("bulk update-power results\n%s", out)
+		}
+	})
+
+	t.Run("update partial failure", func(t *testing.T) {
+		m := newMockPrism(t)
+		out, err := runCLI(t, m, "vm", "update", "--selector", "name=web-*", "--vcpus", "1", "--force", "--wait")
+		if err == nil || err.Error() != "1 of 2 VMs failed" {
+			t.Fatalf("bulk update error = %v\n%s", err, out)
+		}
+		if !strings.Contains(out, "sockets cannot be removed from a running VM (2 to 1). use --power-cycle") {
+			t.Errorf("bulk update output missing the failure\n%s", out)
+		}
+		if got := len(m.received("PUT", pcPrefix+"vms/"+web01)); got != 0 {
+			t.Errorf("bulk update changed the running VM %d times", got)
+		}
+		if got := len(m.received("PUT", pcPrefix+"vms/"+web02)); got != 1 {
+			t.Errorf("bulk update sent %d updates to the stopped VM, want 1", got)
+		}
+	})
+
+	t.Run("delete protected", func(t *testing.T) {
+		m := newMockPrism(t)
+		_, err := runCLI(t, m, "vm", "delete", "--selector", "name=*", "--force")
+		if err == nil || !strings.Contains(err.Error(), "db-01 (Protected:true)") {
+			t.Fatalf("bulk delete error = %v", err)
+		}
+		if got := len(m.received("DELETE", pcPrefix+"vms/")); got != 0 {
+			t.Errorf("refused bulk delete sent %d deletes", got)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		m := newMockPrism(t)
+		out, err := runCLI(t, m, "vm", "delete", "--selector", "name=web-*", "--force")
+		if err != nil {
+			t.Fatalf("bulk delete error = %v\n%s", err, out)
+		}
+		if got := len(m.received("DELETE", pcPrefix+"vms/")); got != 2 {
+			t.Errorf("bulk delete sent %d deletes, want 2", got)
+		}
+	})
+
+	t.Run("selector with VM arguments", func(t *testing.T) {
+		m := newMockPrism(t)
+		for _, args := range [][]string{
+			{"vm", "update", "--selector", "name=web-*", "--memory", "8GiB", "--force", "web-01"},
+			{"vm", "update-power", "--selector", "name=web-*", "--force", "web-01", "OFF"},
+			{"vm", "delete", "--selector", "name=web-*", "--force", "web-01"},
+		} {
+			if _, err := runCLI(t, m, args...); err == nil || !strings.Contains(err.Error(), "--selector") {
+				t.Errorf("%v error = %v", args, err)
+			}
+		}
+		for _, method := range []string{"PUT", "POST", "DELETE"} {
+			if got := len(m.received(method, "")); got != 0 {
+				t.Errorf("refused bulk commands sent %d %s requests", got, method)
+			}
+		}
+	})
+
+	t.Run("delete wait", func(t *testing.T) {
+		m := newMockPrism(t)
+		out, err := runCLI(t, m, "vm", "delete", "--selector", "name=web-*", "--force", "--wait")
+		if err != nil {
+			t.Fatalf("bulk delete error = %v\n%s", err, out)
+		}
+
+		m = newMockPrism(t)
+		m.keepDeleted = true
+		out, err = runCLI(t, m, "vm", "delete", "--selector", "name=web-*", "--force", "--wait")
+		if err == nil || !strings.Contains(err.Error(), "2 of 2 VMs failed") {
+			t.Fatalf("bulk delete of VMs that remain error = %v", err)
+		}
+		if !strings.Contains(out, "vm still exists after its delete task") {
+			t.Errorf("bulk delete output missing the remaining VMs\n%s", out)
+		}
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		m := newMockPrism(t)
+		_, err := runCLI(t, m, "vm", "delete", "--selector", "name=app-*")
+		if err == nil || !strings.Contains(err.Error(), "matched no VMs") {
+			t.Fatalf("bulk delete error = %v", err)
+		}
+	})
+}
+
+func TestVMMigrate(t *testing.T) {
+	setTestHome(t)
+
+	const web01 = "1b0e2a47-5d8c-4e0f-9a31-6f2d8c4b7e01"
+
+	t.Run("candidates", func(t *testing.T) {
+		m := newMockPrism(t)
+		out, err := runCLI(t, m, "vm", "migrate", "web-01")
+		if err != nil {
+			t.Fatalf("vm migrate error = %v\n%s", err, out)
+		}
+		if !strings.Contains(out, "prod-node-2") || !strings.Contains(out, "235929 MiB") || strings.Contains(out, "prod-node-1") {
+			t.Errorf("vm migrate candidate output\n%s", out)
+		}
+		if got := len(m.received("POST", pePrefix+"vms/")); got != 0 {
+			t.Errorf("listing candidates sent %d requests", got)
+		}
+	})
+
+	t.Run("migrate", func(t *testing.T) {
+		m := newMockPrism(t)
+		out, err := runCLI(t, m, "vm", "migrate", "--host", "prod-node-2", "--live", "web-01")
+		if err != nil {
+			t.Fatalf("vm migrate error = %v\n%s", err, out)
+		}
+		if !strings.Contains(out, "succeeded") {
+			t.Errorf("vm migrate did not wait for the task\n%s", out)
+		}
+
+		posts := m.received("POST", pePrefix+"vms/"+web01+"/migrate")
+		if len(posts) != 1 {
+			t.Fatalf("vm migrate sent %d requests, want 1", len(posts))
+		}
+		if posts[0].Body["host_uuid"] != "7a5c3d4e-6f70-4b8c-9d9e-1f2a3b4c5d62" || posts[0].Body["live"] != true {
+			t.Errorf("migrate body = %v", posts[0].Body)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{name: "current host", args: []string{"--host", "prod-node-1", "web-01"}, wantErr: "already running on host"},
+		{name: "powered off", args: []string{"web-02"}, wantErr: "is not running"},
+		{name: "unknown host", args: []string{"--host", "prod-node-9", "web-01"}, wantErr: "prod-node-9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockPrism(t)
+			_, err := runCLI(t, m, append([]string{"vm", "migrate"}, tt.args...)...)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("vm migrate error = %v, want %v", err, tt.wantErr)
+			}
+			if got := len(m.received("POST", pePrefix+"vms/")); got != 0 {
+				t.Errorf("refused migration sent %d requests", got)
+			}
+		})
+	}
+}
+
+func TestHost(t *testing.T) {
+	setTestHome(t)
+
+	const node1, node3 = "7a5c3d4e-6f70-4b8c-9d9e-1f2a3b4c5d61", "7a5c3d4e-6f70-4b8c-9d9e-1f2a3b4c5d63"
+
+	t.Run("list", func(t *testing.T) {
+		m := newMockPrism(t)
+		out, err := runCLI(t, m, "host", "list")
+		if err != nil {
+			t.Fatalf("host list error = %v\n%s", err, out)
+		}
+		for _, want := range []string{"prod-node-1", "prod-node-3", "Nutanix 20190916.410", "Gold 6230", "262144 MiB", "TOTAL"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("host list output missing %q\n%s", want, out)
+			}
+		}
+	})
+
+	t.Run("get", func(t *testing.T) {
+		m := newMockPrism(t)
+		out, err := runCLI(t, m, "host", "get", "prod-node-3")
+		if err != nil {
+			t.Fatalf("host get error = %v\n%s", err, out)
+		}
+		for _, want := range []string{node3, "ENTERED_MAINTENANCE_MODE", "Free Memory", "256901 MiB"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("host get output missing %q\n%s", want, out)
+			}
+		}
+	})
+
+	t.Run("enter declined", func(t *testing.T) {
+		m := newMockPrism(t)
+		out, err := runCLI(t, m, "host", "maintenance", "enter", "prod-node-1")
+		if err == nil || !strings.Contains(err.Error(), "maintenance cancelled") {
+			t.Fatalf("host maintenance enter error = %v", err)
+		}
+		if !strings.Contains(out, "db-01") || !strings.Contains(out, "prod-node-2") || strings.Contains(out, "web-02") {
+			t.Errorf("host maintenance enter plan\n%s", out)
+		}
+		if got := len(m.received("POST", pePrefix)); got != 0 {
+			t.Errorf("declined maintenance sent %d requests", got)
+		}
+	})
+
+	t.Run("enter", func(t *testing.T) {
+		m := newMockPrism(t)
+		out, err := runCLI(t, m, "host", "maintenance", "enter", "--force", "prod-node-1")
+		if err != nil {
+			t.Fatalf("host maintenance enter error = %v\n%s", err, out)
+		}
+		if !strings.Contains(out, "host prod-node-1 is in maintenance mode") {
+			t.Errorf("host maintenance enter output\n%s", out)
+		}
+
+		if got := len(m.received("POST", pePrefix+"vms/")); got != 2 {
+			t.Errorf("host maintenance enter sent %d migrations, want 2", got)
+		}
+		for _, vm := range []string{"1b0e2a47-5d8c-4e0f-9a31-6f2d8c4b7e01", "3d204c69-7fae-4021-9c53-8b4fae6d9a03"} {
+			posts := m.received("POST", pePrefix+"vms/"+vm+"/migrate")
+			if len(posts) != 1 || posts[0].Body["live"] != true {
+				t.Errorf("migration of %s = %v", vm, posts)
+			}
+		}
+		if got := len(m.received("POST", pePrefix+"hosts/"+node1+"/enter_maintenance_mode")); got != 1 {
+			t.Errorf("host maintenance enter sent %d requests, want 1", got)
+		}
+	})
+
+	t.Run("exit", func(t *testing.T) {
+		m := newMockPrism(t)
+		out, err := runCLI(t, m, "host", "maintenance", "exit", "prod-node-3")
+		if err != nil {
+			t.Fatalf("host maintenance exit error = %v\n%s", err, out)
+		}
+		if !strings.Contains(out, "host prod-node-3 is ready") {
+			t.Errorf("host maintenance exit output\n%s", out)
+		}
+		if got := len(m.received("POST", pePrefix+"hosts/"+node3+"/exit_maintenance_mode")); got != 1 {
+			t.Errorf("host maintenance exit sent %d requests, want 1", got)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{name: "enter twice", args: []string{"enter", "--force", "prod-node-3"}, wantErr: "already in maintenance mode"},
+		{name: "exit twice", args: []string{"exit", "prod-node-2"}, wantErr: "not in maintenance mode"},
+		{name: "no host", args: []string{"exit"}, wantErr: "no host provided"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockPrism(t)
+			_, err := runCLI(t, m, append([]string{"host", "maintenance"}, tt.args...)...)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("host maintenance error = %v, want %v", err, tt.wantErr)
+			}
+			if got := len(m.received("POST", pePrefix)); got != 0 {
+				t.Errorf("refused maintenance sent %d requests", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,536 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	mockUser = "admin"
+	mockPass = "nutanix/4u"
+
+	pcPrefix     = "/api/nutanix/v3/"
+	pePrefix     = "/PrismGateway/services/rest/v2.0/"
+	karbonPrefix = "/karbon/"
+)
+
+// mockRequest is a request received by the mock server
+type mockRequest struct {
+	Method string
+	Path   string
+	Body   map[string]interface{}
+}
+
+// mockPrism is a fake Prism Central, Prism Element and Karbon API serving the
+// fixtures found in testdata. Entities are held as generic JSON so handlers can
+// update them in place for mutating requests.
+type mockPrism struct {
+	t   *testing.T
+	srv *httptest.Server
+
+	mu       sync.Mutex
+	data     map[string][]map[string]interface{}
+	object   map[string]map[string]interface{}
+	requests []mockRequest
+	tasks    []map[string]interface{}
+	taskSeq  int
+
+	// taskStatus is the status reported for tasks created by mutating requests
+	taskStatus string
+	// taskSteps counts the polls left before a running task succeeds
+	taskSteps map[string]int
+	// rejectNames lists the entity names whose clone requests fail
+	rejectNames map[string]bool
+	// keepDeleted leaves entities in place after their delete task succeeds
+	keepDeleted bool
+}
+
+// newMockPrism starts a TLS server loaded with the testdata fixtures
+func newMockPrism(t *testing.T) *mockPrism {
+	m := &mockPrism{
+		t:           t,
+		data:        map[string][]map[string]interface{}{},
+		object:      map[string]map[string]interface{}{},
+		taskStatus:  "SUCCEEDED",
+		taskSteps:   map[string]int{},
+		rejectNames: map[string]bool{},
+	}
+
+	for _, name := range []string{"pc_vms", "pc_images", "pc_subnets", "pc_clusters", "pe_vms", "pe_disks", "pe_virtual_disks", "pe_storage_containers", "pe_snapshots", "pe_hosts", "karbon_clusters"} {
+		list := []map[string]interface{}{}
+		m.loadFixture(name, &list)
+		m.data[name] = list
+	}
+
+	m.loadFixture("pc_tasks", &m.tasks)
+
+	cluster := map[string]interface{}{}
+	m.loadFixture("pe_cluster", &cluster)
+	m.object["pe_cluster"] = cluster
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(pcPrefix, m.authorized(m.handlePC))
+	mux.HandleFunc(pePrefix, m.authorized(m.handlePE))
+	mux.HandleFunc(karbonPrefix, m.authorized(m.handleKarbon))
+
+	m.srv = httptest.NewTLSServer(mux)
+	t.Cleanup(m.srv.Close)
+
+	return m
+}
+
+// address returns the host:port of the mock server
+func (m *mockPrism) address() string {
+	return strings.TrimPrefix(m.srv.URL, "https://")
+}
+
+// loadFixture decodes a testdata JSON file
+func (m *mockPrism) loadFixture(name string, v interface{}) {
+	raw, err := ioutil.ReadFile(filepath.Join("testdata", name+".json"))
+	if err != nil {
+		m.t.Fatalf("unable to read fixture %s: %v", name, err)
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		m.t.Fatalf("unable to decode fixture %s: %v", name, err)
+	}
+}
+
+// received returns the requests matching a method and path prefix
+func (m *mockPrism) received(method, path string) []mockRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := []mockRequest{}
+	for _, r := range m.requests {
+		if r.Method == method && strings.HasPrefix(r.Path, path) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// authorized checks basic auth and records the request before handling it
+func (m *mockPrism) authorized(next func(w http.ResponseWriter, r *http.Request, path string, body map[string]interface{})) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != mockUser || pass != mockPass {
+			http.Error(w, `{"message": "authentication required"}`, http.StatusUnauthorized)
+			return
+		}
+
+		body := map[string]interface{}{}
+		if r.Body != nil {
+			raw, _ := ioutil.ReadAll(r.Body)
+			if len(raw) > 0 {
+				json.Unmarshal(raw, &body)
+			}
+		}
+
+		m.mu.Lock()
+		m.requests = append(m.requests, mockRequest{Method: r.Method, Path: r.URL.Path, Body: body})
+		m.mu.Unlock()
+
+		path := r.URL.Path
+		for _, prefix := range []string{pcPrefix, pePrefix, karbonPrefix} {
+			path = strings.TrimPrefix(path, prefix)
+		}
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		next(w, r, path, body)
+	}
+}
+
+// handlePC serves the Prism Central v3 endpoints
+func (m *mockPrism) handlePC(w http.ResponseWriter, r *http.Request, path string, body map[string]interface{}) {
+	parts := strings.Split(path, "/")
+	kind := parts[0]
+	collection := "pc_" + kind
+
+	if kind == "tasks" && len(parts) == 2 && parts[1] == "list" && r.Method == http.MethodPost {
+		m.writeList(w, "task", m.tasks, body)
+		return
+	}
+	if kind == "tasks" && len(parts) == 2 && r.Method == http.MethodGet {
+		if task := m.findTask(parts[1]); task != nil {
+			writeJSON(w, http.StatusOK, task)
+			return
+		}
+		http.Error(w, `{"state": "ERROR", "code": 404}`, http.StatusNotFound)
+		return
+	}
+
+	if _, ok := m.data[collection]; !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch {
+	case len(parts) == 2 && parts[1] == "list" && r.Method == http.MethodPost:
+		m.writeList(w, kind, m.data[collection], body)
+	case len(parts) == 1 && r.Method == http.MethodPost:
+		m.createEntity(w, collection, kind, body)
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		entity := m.findPC(collection, parts[1])
+		if entity == nil {
+			http.Error(w, `{"state": "ERROR", "code": 404}`, http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, entity)
+	case len(parts) == 2 && r.Method == http.MethodPut:
+		m.updateEntity(w, collection, parts[1], body)
+	case len(parts) == 2 && r.Method == http.MethodDelete:
+		m.deleteEntity(w, collection, kind, parts[1])
+	case len(parts) == 3 && parts[2] == "clone" && r.Method == http.MethodPost:
+		m.cloneEntity(w, collection, kind, parts[1], body)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// writeList returns a v3 list response honouring offset and length
+func (m *mockPrism) writeList(w http.ResponseWriter, kind string, list []map[string]interface{}, body map[string]interface{}) {
+	offset, _ := body["offset"].(float64)
+	length, _ := body["length"].(float64)
+
+	start := int(offset)
+	if start > len(list) {
+		start = len(list)
+	}
+	end := len(list)
+	if length > 0 && start+int(length) < end {
+		end = start + int(length)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"api_version": "3.1",
+		"metadata": map[string]interface{}{
+			"kind":          kind,
+			"total_matches": len(list),
+			"length":        end - start,
+			"offset":        start,
+		},
+		"entities": list[start:end],
+	})
+}
+
+// findPC returns the v3 entity with the provided metadata UUID
+func (m *mockPrism) findPC(collection, uuid string) map[string]interface{} {
+	for _, entity := range m.data[collection] {
+		meta, _ := entity["metadata"].(map[string]interface{})
+		if meta["uuid"] == uuid {
+			return entity
+		}
+	}
+	return nil
+}
+
+// createEntity stores a new v3 entity and returns it in a pending state
+func (m *mockPrism) createEntity(w http.ResponseWriter, collection, kind string, body map[string]interface{}) {
+	meta, _ := body["metadata"].(map[string]interface{})
+	if meta == nil {
+		meta = map[string]interface{}{}
+	}
+	meta["kind"] = kind
+	meta["uuid"] = fmt.Sprintf("9f8e7d6c-5b4a-4c3d-8e2f-%012d", len(m.requests))
+
+	entity := map[string]interface{}{
+		"api_version": "3.1",
+		"metadata":    meta,
+		"spec":        body["spec"],
+		"status":      m.pendingStatus(m.newTask("kCreate", kind, meta["uuid"].(string))),
+	}
+	m.data[collection] = append(m.data[collection], entity)
+
+	writeJSON(w, http.StatusAccepted, entity)
+}
+
+// cloneEntity copies an existing v3 entity applying the override_spec fields
+// to its resources and returns the clone task
+func (m *mockPrism) cloneEntity(w http.ResponseWriter, collection, kind, uuid string, body map[string]interface{}) {
+	source := m.findPC(collection, uuid)
+	if source == nil {
+		http.Error(w, `{"state": "ERROR", "code": 404}`, http.StatusNotFound)
+		return
+	}
+
+	raw, _ := json.Marshal(source["spec"])
+	spec := map[string]interface{}{}
+	json.Unmarshal(raw, &spec)
+
+	resources, _ := spec["resources"].(map[string]interface{})
+	override, _ := body["override_spec"].(map[string]interface{})
+	if name, _ := override["name"].(string); m.rejectNames[name] {
+		http.Error(w, `{"state": "ERROR", "code": 422, "message_list": [{"message": "clone rejected"}]}`, http.StatusUnprocessableEntity)
+		return
+	}
+	for k, v := range override {
+		if k == "name" {
+			spec["name"] = v
+			continue
+		}
+		resources[k] = v
+	}
+
+	cloneUUID := fmt.Sprintf("9f8e7d6c-5b4a-4c3d-8e2f-%012d", len(m.requests))
+	m.data[collection] = append(m.data[collection], map[string]interface{}{
+		"api_version": "3.1",
+		"metadata":    map[string]interface{}{"kind": kind, "uuid": cloneUUID},
+		"spec":        spec,
+		"status":      map[string]interface{}{"state": "COMPLETE"},
+	})
+
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{"task_uuid": m.newTask("kVmClone", kind, cloneUUID)})
+}
+
+// deleteEntity removes a v3 entity and returns the delete task
+func (m *mockPrism) deleteEntity(w http.ResponseWriter, collection, kind, uuid string) {
+	for i, entity := range m.data[collection] {
+		meta, _ := entity["metadata"].(map[string]interface{})
+		if meta["uuid"] != uuid {
+			continue
+		}
+		if !m.keepDeleted {
+			m.data[collection] = append(m.data[collection][:i], m.data[collection][i+1:]...)
+		}
+
+		writeJSON(w, http.StatusAccepted, map[string]interface{}{
+			"api_version": "3.1",
+			"metadata":    meta,
+			"status": map[string]interface{}{
+				"state":             "DELETE_PENDING",
+				"execution_context": map[string]interface{}{"task_uuids": []string{m.newTask("kVmDelete", kind, uuid)}},
+			},
+		})
+		return
+	}
+
+	http.Error(w, `{"state": "ERROR", "code": 404}`, http.StatusNotFound)
+}
+
+// updateEntity replaces the spec of an existing v3 entity
+func (m *mockPrism) updateEntity(w http.ResponseWriter, collection, uuid string, body map[string]interface{}) {
+	entity := m.findPC(collection, uuid)
+	if entity == nil {
+		http.Error(w, `{"state": "ERROR", "code": 404}`, http.StatusNotFound)
+		return
+	}
+	entity["spec"] = body["spec"]
+
+	meta, _ := entity["metadata"].(map[string]interface{})
+	if bodyMeta, ok := body["metadata"].(map[string]interface{}); ok {
+		if cats, ok := bodyMeta["categories"]; ok {
+			meta["categories"] = cats
+		}
+	}
+	kind, _ := meta["kind"].(string)
+
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{
+		"api_version": "3.1",
+		"metadata":    entity["metadata"],
+		"spec":        body["spec"],
+		"status":      m.pendingStatus(m.newTask("kUpdate", kind, uuid)),
+	})
+}
+
+// pendingStatus is the status of an intentful response for the given task
+func (m *mockPrism) pendingStatus(taskUUID string) map[string]interface{} {
+	return map[string]interface{}{
+		"state":             "PENDING",
+		"execution_context": map[string]interface{}{"task_uuids": []string{taskUUID}},
+	}
+}
+
+// newTask records a v3 task against an entity and returns its UUID
+func (m *mockPrism) newTask(operation, kind, entityUUID string) string {
+	m.taskSeq++
+	uuid := fmt.Sprintf("4a5b6c7d-8e9f-4a0b-9c1d-%012d", m.taskSeq)
+
+	percent := 100
+	if m.taskStatus != "SUCCEEDED" && m.taskStatus != "FAILED" {
+		percent = 40
+	}
+
+	task := map[string]interface{}{
+		"uuid":                  uuid,
+		"status":                m.taskStatus,
+		"operation_type":        operation,
+		"percentage_complete":   percent,
+		"progress_message":      operation,
+		"entity_reference_list": []map[string]interface{}{{"kind": kind, "uuid": entityUUID}},
+	}
+	if m.taskStatus == "FAILED" {
+		task["error_code"] = "500"
+		task["error_detail"] = "operation failed"
+	}
+	m.tasks = append(m.tasks, task)
+
+	return uuid
+}
+
+// findTask returns the recorded task with the provided UUID. Tasks listed in
+// taskSteps advance on every lookup and succeed once their steps run out.
+func (m *mockPrism) findTask(uuid string) map[string]interface{} {
+	for _, task := range m.tasks {
+		if task["uuid"] != uuid {
+			continue
+		}
+		if steps, ok := m.taskSteps[uuid]; ok {
+			if steps <= 0 {
+				task["status"] = "SUCCEEDED"
+				task["percentage_complete"] = 100
+			} else {
+				m.taskSteps[uuid] = steps - 1
+			}
+		}
+		return task
+	}
+	return nil
+}
+
+// handlePE serves the Prism Element v2 endpoints
+func (m *mockPrism) handlePE(w http.ResponseWriter, r *http.Request, path string, body map[string]interface{}) {
+	parts := strings.Split(path, "/")
+
+	switch {
+	case path == "cluster" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, m.object["pe_cluster"])
+	case (path == "disks" || path == "virtual_disks" || path == "storage_containers" || path == "hosts") && r.Method == http.MethodGet:
+		m.writePEList(w, r, m.data["pe_"+path])
+	case len(parts) == 2 && parts[0] == "tasks" && r.Method == http.MethodGet:
+		task := m.findTask(parts[1])
+		if task == nil {
+			http.Error(w, `{"message": "task not found"}`, http.StatusNotFound)
+			return
+		}
+		meta := map[string]interface{}{"error": "kNoError"}
+		if task["status"] == "FAILED" {
+			meta = map[string]interface{}{"error": "kInvalidState", "error_detail": task["error_detail"]}
+		}
+		status := strings.ToUpper(task["status"].(string)[:1]) + strings.ToLower(task["status"].(string)[1:])
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"uuid":                task["uuid"],
+			"operation_type":      task["operation_type"],
+			"progress_status":     status,
+			"percentage_complete": task["percentage_complete"],
+			"meta_response":       meta,
+		})
+	case path == "snapshots" && r.Method == http.MethodGet:
+		list := []map[string]interface{}{}
+		for _, s := range m.data["pe_snapshots"] {
+			if vmUUID := r.URL.Query().Get("vm_uuid"); len(vmUUID) == 0 || s["vm_uuid"] == vmUUID {
+				list = append(list, s)
+			}
+		}
+		m.writePEList(w, r, list)
+	case path == "snapshots" && r.Method == http.MethodPost:
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"task_uuid": m.newTask("kSnapshot", "vm", "")})
+	case len(parts) == 2 && parts[0] == "snapshots" && (r.Method == http.MethodGet || r.Method == http.MethodDelete):
+		for _, s := range m.data["pe_snapshots"] {
+			if s["uuid"] != parts[1] {
+				continue
+			}
+			if r.Method == http.MethodGet {
+				writeJSON(w, http.StatusOK, s)
+				return
+			}
+			writeJSON(w, http.StatusCreated, map[string]interface{}{"task_uuid": m.newTask("kSnapshotDelete", "snapshot", parts[1])})
+			return
+		}
+		http.Error(w, `{"message": "snapshot not found"}`, http.StatusNotFound)
+	case path == "vms" && r.Method == http.MethodPost:
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"task_uuid": m.newTask("kVmCreate", "vm", "")})
+	case len(parts) == 3 && parts[0] == "vms" && parts[2] == "migrate" && r.Method == http.MethodPost:
+		for _, vm := range m.data["pe_vms"] {
+			if vm["uuid"] == parts[1] {
+				vm["host_uuid"] = body["host_uuid"]
+			}
+		}
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"task_uuid": m.newTask("kVmMigrate", "vm", parts[1])})
+	case path == "vms" && r.Method == http.MethodGet:
+		m.writePEList(w, r, m.data["pe_vms"])
+	case len(parts) == 2 && parts[0] == "hosts" && r.Method == http.MethodGet:
+		for _, h := range m.data["pe_hosts"] {
+			if h["uuid"] == parts[1] {
+				writeJSON(w, http.StatusOK, h)
+				return
+			}
+		}
+		http.Error(w, `{"message": "host not found"}`, http.StatusNotFound)
+	case len(parts) == 3 && parts[0] == "hosts" && (parts[2] == "enter_maintenance_mode" || parts[2] == "exit_maintenance_mode") && r.Method == http.MethodPost:
+		for _, h := range m.data["pe_hosts"] {
+			if h["uuid"] == parts[1] {
+				h["host_in_maintenance_mode"] = parts[2] == "enter_maintenance_mode"
+				writeJSON(w, http.StatusCreated, map[string]interface{}{"task_uuid": m.newTask("kHostMaintenance", "host", parts[1])})
+				return
+			}
+		}
+		http.Error(w, `{"message": "host not found"}`, http.StatusNotFound)
+	case len(parts) == 3 && parts[0] == "vms" && parts[2] == "restore" && r.Method == http.MethodPost:
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"task_uuid": m.newTask("kVmRestore", "vm", parts[1])})
+	case len(parts) == 3 && parts[0] == "vms" && parts[2] == "set_power_state" && r.Method == http.MethodPost:
+		for _, vm := range m.data["pe_vms"] {
+			if vm["uuid"] == parts[1] {
+				writeJSON(w, http.StatusCreated, map[string]interface{}{"task_uuid": m.newTask("kVmSetPowerState", "vm", parts[1])})
+				return
+			}
+		}
+		http.Error(w, `{"message": "vm not found"}`, http.StatusNotFound)
+	case len(parts) == 2 && parts[0] == "vms" && r.Method == http.MethodGet:
+		for _, vm := range m.data["pe_vms"] {
+			if vm["uuid"] == parts[1] {
+				writeJSON(w, http.StatusOK, vm)
+				return
+			}
+		}
+		http.Error(w, `{"message": "vm not found"}`, http.StatusNotFound)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// writePEList returns a v2 list response honouring page and count
+func (m *mockPrism) writePEList(w http.ResponseWriter, r *http.Request, list []map[string]interface{}) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	count, _ := strconv.Atoi(r.URL.Query().Get("count"))
+
+	start, end := 0, len(list)
+	if page > 0 && count > 0 {
+		start = (page - 1) * count
+		if start > len(list) {
+			start = len(list)
+		}
+		if start+count < end {
+			end = start + count
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"metadata": map[string]interface{}{"total_entities": len(list), "grand_total_entities": len(list), "count": end - start, "page": page},
+		"entities": list[start:end],
+	})
+}
+
+// handleKarbon serves the Karbon cluster endpoints
+func (m *mockPrism) handleKarbon(w http.ResponseWriter, r *http.Request, path string, body map[string]interface{}) {
+	if path == "v1-beta.1/k8s/clusters" && r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, m.data["karbon_clusters"])
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// writeJSON writes a JSON response with the provided status code
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
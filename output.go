@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v2"
+)
+
+// outputFormats are the accepted values for the global --output flag
+var outputFormats = []string{"table", "json", "yaml", "csv", "tsv"}
+
+// Result is the structured output of a command. Header, Rows and Footer are
+// used by the tabular formats while Entity holds the full SDK response that
+// is emitted by the json and yaml formats.
+type Result struct {
+	Header []string
+	Rows   [][]string
+	Footer []string
+	Entity interface{}
+
+	// table specific display options
+	MergeCells bool
+	NoWrap     bool
+}
+
+// Renderer writes a command result in a specific output format
+type Renderer interface {
+	Render(res *Result) error
+}
+
+// tableRenderer writes results using the shared tablewriter
+type tableRenderer struct {
+	tr *tablewriter.Table
+}
+
+// jsonRenderer writes the full result entity as indented JSON
+type jsonRenderer struct {
+	w io.Writer
+}
+
+// yamlRenderer writes the full result entity as YAML
+type yamlRenderer struct {
+	w io.Writer
+}
+
+// delimitedRenderer writes the result rows as csv or tsv
+type delimitedRenderer struct {
+	w     io.Writer
+	comma rune
+}
+
+// newRenderer returns the renderer for the requested output format
+func newRenderer(format string, w io.Writer, tr *tablewriter.Table) (Renderer, error) {
+	switch strings.ToLower(format) {
+	case "", "table":
+		return &tableRenderer{tr: tr}, nil
+	case "json":
+		return &jsonRenderer{w: w}, nil
+	case "yaml", "yml":
+		return &yamlRenderer{w: w}, nil
+	case "csv":
+		return &delimitedRenderer{w: w, comma: ','}, nil
+	case "tsv":
+		return &delimitedRenderer{w: w, comma: '\t'}, nil
+	}
+
+	return nil, fmt.Errorf("invalid output format %q. <%s>", format, strings.Join(outputFormats, ", "))
+}
+
+// renderResult writes a command result in the format selected by --output
+func renderResult(c *cli.Context, w io.Writer, tr *tablewriter.Table, res *Result) error {
+	r, err := newRenderer(c.String("output"), w, tr)
+	if err != nil {
+		return err
+	}
+
+	if len(c.String("query")) > 0 {
+		res, err = applyQuery(c.String("query"), res)
+		if err != nil {
+			return err
+		}
+	}
+
+	return r.Render(res)
+}
+
+// render writes a command result in the format selected by --output
+func (n *NCLI) render(c *cli.Context, res *Result) error {
+	return renderResult(c, n.out, n.tr, res)
+}
+
+// render writes a command result in the format selected by --output
+func (b *BCLI) render(c *cli.Context, res *Result) error {
+	return renderResult(c, b.out, b.tr, res)
+}
+
+// Render writes the header, rows and footer to the table writer
+func (t *tableRenderer) Render(res *Result) error {
+	if len(res.Header) > 0 {
+		t.tr.SetHeader(res.Header)
+	}
+	if len(res.Footer) > 0 {
+		t.tr.SetFooter(res.Footer)
+	}
+	if res.MergeCells {
+		t.tr.SetAutoMergeCells(true)
+		t.tr.SetRowLine(true)
+	}
+	if res.NoWrap {
+		t.tr.SetAutoWrapText(false)
+	}
+
+	t.tr.AppendBulk(res.Rows)
+	t.tr.Render()
+
+	return nil
+}
+
+// Render writes the result entity as JSON
+func (j *jsonRenderer) Render(res *Result) error {
+	out, err := json.MarshalIndent(resultEntity(res), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(j.w, string(out))
+	return err
+}
+
+// Render writes the result entity as YAML. The entity is passed through JSON
+// first so that keys match the API field names regardless of the yaml tags
+// present on the SDK types.
+func (y *yamlRenderer) Render(res *Result) error {
+	generic, err := toGeneric(resultEntity(res))
+	if err != nil {
+		return err
+	}
+
+	out, err := yaml.Marshal(generic)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprint(y.w, string(out))
+	return err
+}
+
+// Render writes the header and rows separated by the configured delimiter
+func (d *delimitedRenderer) Render(res *Result) error {
+	cw := csv.NewWriter(d.w)
+	cw.Comma = d.comma
+
+	if len(res.Header) > 0 {
+		if err := cw.Write(res.Header); err != nil {
+			return err
+		}
+	}
+	if err := cw.WriteAll(res.Rows); err != nil {
+		return err
+	}
+
+	return cw.Error()
+}
+
+// resultEntity returns the entity for machine readable output. Commands that
+// do not provide an entity fall back to their rows keyed by the header names.
+func resultEntity(res *Result) interface{} {
+	if res.Entity != nil {
+		return res.Entity
+	}
+
+	rows := []map[string]string{}
+	for _, row := range res.Rows {
+		item := map[string]string{}
+		for i, col := range res.Header {
+			if i < len(row) {
+				item[col] = row[i]
+			}
+		}
+		rows = append(rows, item)
+	}
+
+	return rows
+}
+
+// toGeneric converts a value to plain maps and slices using its JSON form
+func toGeneric(v interface{}) (interface{}, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var generic interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&generic); err != nil {
+		return nil, errors.New("unable to convert result for output: " + err.Error())
+	}
+
+	return normalizeNumbers(generic), nil
+}
+
+// normalizeNumbers replaces json.Number values with int64 or float64 so that
+// large integers such as byte sizes are not written in exponent form
+func normalizeNumbers(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			val[k] = normalizeNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range val {
+			val[i] = normalizeNumbers(item)
+		}
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return i
+		}
+		f, _ := val.Float64()
+		return f
+	}
+
+	return v
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+func Test_newRenderer(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		wantErr bool
+	}{
+		{name: "default", format: "", wantErr: false},
+		{name: "table", format: "table", wantErr: false},
+		{name: "json", format: "json", wantErr: false},
+		{name: "yaml upper", format: "YAML", wantErr: false},
+		{name: "csv", format: "csv", wantErr: false},
+		{name: "tsv", format: "tsv", wantErr: false},
+		{name: "invalid", format: "xml", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			_, err := newRenderer(tt.format, &buf, tablewriter.NewWriter(&buf))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newRenderer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRenderers(t *testing.T) {
+	type entity struct {
+		Name string `json:"name"`
+		Size int    `json:"size_bytes"`
+	}
+
+	res := &Result{
+		Header: []string{"Name", "Size"},
+		Rows:   [][]string{{"vm-one", "10"}, {"vm,two", "20"}},
+		Footer: []string{"Total", "2"},
+		Entity: []entity{{Name: "vm-one", Size: 10}, {Name: "vm,two", Size: 10737418240}},
+	}
+
+	tests := []struct {
+		name   string
+		format string
+		res    *Result
+		want   string
+	}{
+		{
+			name:   "csv",
+			format: "csv",
+			res:    res,
+			want:   "Name,Size\nvm-one,10\n\"vm,two\",20\n",
+		},
+		{
+			name:   "tsv",
+			format: "tsv",
+			res:    res,
+			want:   "Name\tSize\nvm-one\t10\nvm,two\t20\n",
+		},
+		{
+			name:   "json",
+			format: "json",
+			res:    res,
+			want:   "[\n  {\n    \"name\": \"vm-one\",\n    \"size_bytes\": 10\n  },\n  {\n    \"name\": \"vm,two\",\n    \"size_bytes\": 10737418240\n  }\n]\n",
+		},
+		{
+			name:   "yaml",
+			format: "yaml",
+			res:    res,
+			want:   "- name: vm-one\n  size_bytes: 10\n- name: vm,two\n  size_bytes: 10737418240\n",
+		},
+		{
+			name:   "json without entity",
+			format: "json",
+			res:    &Result{Header: []string{"Profile"}, Rows: [][]string{{"default"}}},
+			want:   "[\n  {\n    \"Profile\": \"default\"\n  }\n]\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			r, err := newRenderer(tt.format, &buf, tablewriter.NewWriter(&buf))
+			if err != nil {
+				t.Fatalf("newRenderer() error = %v", err)
+			}
+			if err := r.Render(tt.res); err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
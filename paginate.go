@@ -0,0 +1,144 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/routebyintuition/ntnx-go-sdk/pc"
+	"github.com/routebyintuition/ntnx-go-sdk/pe"
+)
+
+// defaultPageSize is the number of entities requested per page
+const defaultPageSize = 100
+
+// pageWorkers is the maximum number of pages requested concurrently
+const pageWorkers = 8
+
+// pageFunc retrieves the page starting at offset and returns the page items
+// along with the total number of entities matching the request
+type pageFunc func(offset, length int) (interface{}, int, error)
+
+// fetchPages retrieves the first page to learn the total number of matches and
+// then requests the remaining pages concurrently with a bounded worker pool.
+// Pages are returned in offset order. A limit greater than zero stops paging
+// once enough pages have been requested to cover that many entities.
+func fetchPages(pageSize, limit int, fetch pageFunc) ([]interface{}, error) {
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	if limit > 0 && limit < pageSize {
+		pageSize = limit
+	}
+
+	first, total, err := fetch(0, pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if limit > 0 && limit < total {
+		total = limit
+	}
+
+	pageCount := (total + pageSize - 1) / pageSize
+	if pageCount < 1 {
+		pageCount = 1
+	}
+
+	pages := make([]interface{}, pageCount)
+	pages[0] = first
+
+	if pageCount == 1 {
+		return pages, nil
+	}
+
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+
+	jobs := make(chan int)
+	done := make(chan struct{})
+
+	workers := pageWorkers
+	if pageCount-1 < workers {
+		workers = pageCount - 1
+	}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for page := range jobs {
+				items, _, err := fetch(page*pageSize, pageSize)
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						close(done)
+					})
+					continue
+				}
+				pages[page] = items
+			}
+		}()
+	}
+
+schedule:
+	for page := 1; page < pageCount; page++ {
+		select {
+		case jobs <- page:
+		case <-done:
+			break schedule
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	return pages, nil
+}
+
+// fetchPCEntities pages through a v3 list API returning []pc.Entities
+func fetchPCEntities(limit int, fetch func(offset, length int) ([]pc.Entities, int, error)) ([]pc.Entities, error) {
+	pages, err := fetchPages(defaultPageSize, limit, func(offset, length int) (interface{}, int, error) {
+		return fetch(offset, length)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entities := []pc.Entities{}
+	for _, page := range pages {
+		items, _ := page.([]pc.Entities)
+		entities = append(entities, items...)
+	}
+
+	if limit > 0 && len(entities) > limit {
+		entities = entities[:limit]
+	}
+
+	return entities, nil
+}
+
+// fetchPEEntities pages through a v2 list API returning []pe.Entities. The v2
+// APIs use one based page numbers with a count instead of offsets.
+func fetchPEEntities(limit int, fetch func(page, count int) ([]pe.Entities, int, error)) ([]pe.Entities, error) {
+	pages, err := fetchPages(defaultPageSize, limit, func(offset, length int) (interface{}, int, error) {
+		return fetch(offset/length+1, length)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entities := []pe.Entities{}
+	for _, page := range pages {
+		items, _ := page.([]pe.Entities)
+		entities = append(entities, items...)
+	}
+
+	if limit > 0 && len(entities) > limit {
+		entities = entities[:limit]
+	}
+
+	return entities, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func Test_fetchPages(t *testing.T) {
+	tests := []struct {
+		name      string
+		total     int
+		pageSize  int
+		limit     int
+		failAt    int
+		want      int
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "single page", total: 5, pageSize: 10, want: 5, wantCalls: 1},
+		{name: "empty", total: 0, pageSize: 10, want: 0, wantCalls: 1},
+		{name: "exact pages", total: 30, pageSize: 10, want: 30, wantCalls: 3},
+		{name: "partial last page", total: 95, pageSize: 10, want: 95, wantCalls: 10},
+		{name: "limit within first page", total: 95, pageSize: 10, limit: 4, want: 4, wantCalls: 1},
+		{name: "limit across pages", total: 95, pageSize: 10, limit: 25, want: 25, wantCalls: 3},
+		{name: "limit above total", total: 15, pageSize: 10, limit: 50, want: 15, wantCalls: 2},
+		{name: "error on later page", total: 95, pageSize: 10, failAt: 40, wantErr: true},
+		{name: "error on first page", total: 95, pageSize: 10, failAt: -1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			calls := 0
+
+			pages, err := fetchPages(tt.pageSize, tt.limit, func(offset, length int) (interface{}, int, error) {
+				mu.Lock()
+				calls++
+				mu.Unlock()
+
+				if (tt.failAt == -1 && offset == 0) || (tt.failAt > 0 && offset == tt.failAt) {
+					return nil, 0, errors.New("page failed")
+				}
+
+				items := []int{}
+				for i := offset; i < offset+length && i < tt.total; i++ {
+					items = append(items, i)
+				}
+				return items, tt.total, nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fetchPages() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			got := []int{}
+			for _, page := range pages {
+				got = append(got, page.([]int)...)
+			}
+			if tt.limit > 0 && len(got) > tt.limit {
+				got = got[:tt.limit]
+			}
+
+			want := []int{}
+			for i := 0; i < tt.want; i++ {
+				want = append(want, i)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("fetchPages() = %v, want %v", got, want)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("fetchPages() made %d calls, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -87,7 +87,7 @@ func (b *BCLI) configureDefaultProfile(c *cli.Context) error {
 		return err
 	}
 
-	fmt.Println("saved profile to: ", fileLocale)
+	fmt.Fprintln(b.out, "saved profile to: ", fileLocale)
 
 	return nil
 }
@@ -158,7 +158,7 @@ func (b *BCLI) createProfile(c *cli.Context) error {
 		return err
 	}
 
-	fmt.Println("saved profile to: ", fileLocale)
+	fmt.Fprintln(b.out, "saved profile to: ", fileLocale)
 
 	return nil
 }
@@ -175,8 +175,6 @@ func (b *BCLI) listProfiles(c *cli.Context) error {
 		return err
 	}
 
-	b.tr.SetHeader([]string{"Profile", "Last Modified", "Location"})
-
 	data := [][]string{}
 	profileCount := 0
 
@@ -187,12 +185,12 @@ func (b *BCLI) listProfiles(c *cli.Context) error {
 		}
 	}
 
-	b.tr.SetFooter([]string{"Total", "", strconv.Itoa(profileCount)})
-	b.tr.SetAutoWrapText(false)
-	b.tr.AppendBulk(data)
-	b.tr.Render()
-
-	return nil
+	return b.render(c, &Result{
+		Header: []string{"Profile", "Last Modified", "Location"},
+		Footer: []string{"Total", "", strconv.Itoa(profileCount)},
+		Rows:   data,
+		NoWrap: true,
+	})
 }
 
 func (b *BCLI) deleteProfile(c *cli.Context) error {
@@ -206,7 +204,7 @@ func (b *BCLI) deleteProfile(c *cli.Context) error {
 	if err != nil {
 		return err
 	}
-	fmt.Println("deleted profile: ", c.Args().First())
+	fmt.Fprintln(b.out, "deleted profile: ", c.Args().First())
 
 	return nil
 }
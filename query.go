@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/jmespath/go-jmespath"
+)
+
+// applyQuery runs a JMESPath expression against the result entity and returns
+// a new result holding the projection. The expression is evaluated against the
+// JSON form of the entity so field names match the API documentation.
+func applyQuery(expression string, res *Result) (*Result, error) {
+	query, err := jmespath.Compile(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query %q: %v", expression, err)
+	}
+
+	raw, err := json.Marshal(resultEntity(res))
+	if err != nil {
+		return nil, err
+	}
+
+	// numbers are left as float64 as required by the JMESPath comparators
+	var generic interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return nil, err
+	}
+
+	projected, err := query.Search(generic)
+	if err != nil {
+		return nil, fmt.Errorf("unable to apply query %q: %v", expression, err)
+	}
+
+	header, rows := queryTable(projected)
+
+	return &Result{
+		Header: header,
+		Rows:   rows,
+		Entity: projectedEntity{projected},
+		NoWrap: res.NoWrap,
+	}, nil
+}
+
+// projectedEntity wraps a query result so that an empty projection is still
+// emitted as null rather than falling back to the table rows
+type projectedEntity struct {
+	value interface{}
+}
+
+// MarshalJSON writes the wrapped query result
+func (p projectedEntity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.value)
+}
+
+// queryTable flattens a query result into table columns and rows. Lists of
+// objects use the object keys as columns, lists of lists (multi-select) are
+// written as is and scalars are written to a single column.
+func queryTable(v interface{}) ([]string, [][]string) {
+	switch val := v.(type) {
+	case nil:
+		return nil, [][]string{}
+	case map[string]interface{}:
+		header := sortedKeys([]interface{}{val})
+		return header, [][]string{objectRow(header, val)}
+	case []interface{}:
+		if len(val) == 0 {
+			return nil, [][]string{}
+		}
+
+		if _, ok := val[0].(map[string]interface{}); ok {
+			header := sortedKeys(val)
+			rows := [][]string{}
+			for _, item := range val {
+				obj, _ := item.(map[string]interface{})
+				rows = append(rows, objectRow(header, obj))
+			}
+			return header, rows
+		}
+
+		rows := [][]string{}
+		for _, item := range val {
+			if list, ok := item.([]interface{}); ok {
+				row := []string{}
+				for _, cell := range list {
+					row = append(row, queryCell(cell))
+				}
+				rows = append(rows, row)
+				continue
+			}
+			rows = append(rows, []string{queryCell(item)})
+		}
+		return nil, rows
+	}
+
+	return nil, [][]string{{queryCell(v)}}
+}
+
+// sortedKeys returns the sorted union of keys for a list of objects
+func sortedKeys(list []interface{}) []string {
+	seen := map[string]bool{}
+	keys := []string{}
+	for _, item := range list {
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for k := range obj {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// objectRow returns the values of an object in header order
+func objectRow(header []string, obj map[string]interface{}) []string {
+	row := []string{}
+	for _, k := range header {
+		row = append(row, queryCell(obj[k]))
+	}
+	return row
+}
+
+// queryCell converts a query value to its table cell representation
+func queryCell(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case map[string]interface{}, []interface{}:
+		out, _ := json.Marshal(val)
+		return string(out)
+	}
+
+	return fmt.Sprintf("%v", v)
+}
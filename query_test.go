@@ -0,0 +1,80 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_applyQuery(t *testing.T) {
+	type spec struct {
+		Name       string `json:"name"`
+		PowerState string `json:"power_state"`
+		Memory     int    `json:"memory_size_mib"`
+	}
+
+	res := &Result{
+		Header: []string{"Name", "Powered"},
+		Rows:   [][]string{{"web-1", "ON"}, {"web-2", "OFF"}},
+		Entity: []spec{
+			{Name: "web-1", PowerState: "ON", Memory: 4096},
+			{Name: "web-2", PowerState: "OFF", Memory: 1048576},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		wantHeader []string
+		wantRows   [][]string
+		wantErr    bool
+	}{
+		{
+			name:       "filter and project names",
+			expression: "[?power_state=='ON'].name",
+			wantRows:   [][]string{{"web-1"}},
+		},
+		{
+			name:       "multi-select list",
+			expression: "[*].[name, memory_size_mib]",
+			wantRows:   [][]string{{"web-1", "4096"}, {"web-2", "1048576"}},
+		},
+		{
+			name:       "multi-select hash",
+			expression: "[?memory_size_mib > `4096`].{vm: name, state: power_state}",
+			wantHeader: []string{"state", "vm"},
+			wantRows:   [][]string{{"OFF", "web-2"}},
+		},
+		{
+			name:       "scalar",
+			expression: "length(@)",
+			wantRows:   [][]string{{"2"}},
+		},
+		{
+			name:       "no match",
+			expression: "[?name=='db-1'].name",
+			wantRows:   [][]string{},
+		},
+		{
+			name:       "invalid expression",
+			expression: "[?name==",
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyQuery(tt.expression, res)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("applyQuery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got.Header, tt.wantHeader) {
+				t.Errorf("applyQuery() header = %v, want %v", got.Header, tt.wantHeader)
+			}
+			if !reflect.DeepEqual(got.Rows, tt.wantRows) {
+				t.Errorf("applyQuery() rows = %v, want %v", got.Rows, tt.wantRows)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/routebyintuition/ntnx-go-sdk/pc"
+)
+
+// entity kinds accepted by resolveUUID
+const (
+	kindVM        = "vm"
+	kindImage     = "image"
+	kindSubnet    = "subnet"
+	kindCluster   = "cluster"
+	kindContainer = "storage container"
+	kindHost      = "host"
+)
+
+// namedEntity is the minimal view of an entity used for name resolution
+type namedEntity struct {
+	Name   string
+	UUID   string
+	Detail string
+}
+
+// isGlobPattern reports whether a name contains glob meta characters
+func isGlobPattern(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
+
+// matchEntities returns the entities whose name matches the pattern. Glob
+// patterns are matched against the full name. Otherwise exact matches are
+// returned and when there are none, names starting with the pattern.
+func matchEntities(entities []namedEntity, pattern string) ([]namedEntity, error) {
+	matches := []namedEntity{}
+
+	if isGlobPattern(pattern) {
+		for _, e := range entities {
+			ok, err := path.Match(pattern, e.Name)
+			if err != nil {
+				return nil, fmt.Errorf("invalid name pattern %q: %v", pattern, err)
+			}
+			if ok {
+				matches = append(matches, e)
+			}
+		}
+		return matches, nil
+	}
+
+	for _, e := range entities {
+		if e.Name == pattern {
+			matches = append(matches, e)
+		}
+	}
+	if len(matches) > 0 {
+		return matches, nil
+	}
+
+	for _, e := range entities {
+		if strings.HasPrefix(e.Name, pattern) {
+			matches = append(matches, e)
+		}
+	}
+
+	return matches, nil
+}
+
+// selectEntity returns the single entity matching the pattern or an error
+// listing every candidate when the pattern is ambiguous
+func selectEntity(kind string, entities []namedEntity, pattern string) (namedEntity, error) {
+	matches, err := matchEntities(entities, pattern)
+	if err != nil {
+		return namedEntity{}, err
+	}
+
+	switch len(matches) {
+	case 0:
+		return namedEntity{}, fmt.Errorf("no %s found matching %q", kind, pattern)
+	case 1:
+		return matches[0], nil
+	}
+
+	return namedEntity{}, fmt.Errorf("%d %ss match %q. use a more specific name or the UUID:\n%s", len(matches), kind, pattern, candidateTable(matches))
+}
+
+// candidateTable formats the entities matching an ambiguous name
+func candidateTable(matches []namedEntity) string {
+	var sb strings.Builder
+	tr := tablewriter.NewWriter(&sb)
+	tr.SetHeader([]string{"Name", "UUID", "Details"})
+	for _, m := range matches {
+		tr.Append([]string{m.Name, m.UUID, m.Detail})
+	}
+	tr.Render()
+
+	return sb.String()
+}
+
+// selectExactEntity returns the single entity named exactly name. Prefix and
+// glob matches are only listed as candidates, for commands where a loose
+// match must not pick the entity.
+func selectExactEntity(kind string, entities []namedEntity, name string) (namedEntity, error) {
+	exact := []namedEntity{}
+	for _, e := range entities {
+		if e.Name == name {
+			exact = append(exact, e)
+		}
+	}
+
+	switch len(exact) {
+	case 1:
+		return exact[0], nil
+	case 0:
+		matches, err := matchEntities(entities, name)
+		if err != nil {
+			return namedEntity{}, err
+		}
+		if len(matches) == 0 {
+			return namedEntity{}, fmt.Errorf("no %s named %q", kind, name)
+		}
+		return namedEntity{}, fmt.Errorf("no %s named %q. use the exact name or the UUID of one of:\n%s", kind, name, candidateTable(matches))
+	}
+
+	return namedEntity{}, fmt.Errorf("%d %ss are named %q. use the UUID:\n%s", len(exact), kind, name, candidateTable(exact))
+}
+
+// resolveUUID returns the UUID of the entity identified by a UUID or a name.
+// Names may be exact, a unique prefix or a glob pattern matching one entity.
+func (n *NCLI) resolveUUID(kind, nameOrUUID string) (string, error) {
+	if len(nameOrUUID) == 0 {
+		return "", fmt.Errorf("no %s name or UUID provided", kind)
+	}
+	if IsValidUUID(nameOrUUID) {
+		return nameOrUUID, nil
+	}
+
+	entities, err := n.namedEntities(kind)
+	if err != nil {
+		return "", err
+	}
+
+	match, err := selectEntity(kind, entities, nameOrUUID)
+	if err != nil {
+		return "", err
+	}
+
+	return match.UUID, nil
+}
+
+// resolveExactUUID returns the UUID of the entity identified by a UUID or its
+// exact name
+func (n *NCLI) resolveExactUUID(kind, nameOrUUID string) (string, error) {
+	if len(nameOrUUID) == 0 {
+		return "", fmt.Errorf("no %s name or UUID provided", kind)
+	}
+	if IsValidUUID(nameOrUUID) {
+		return nameOrUUID, nil
+	}
+
+	entities, err := n.namedEntities(kind)
+	if err != nil {
+		return "", err
+	}
+
+	match, err := selectExactEntity(kind, entities, nameOrUUID)
+	if err != nil {
+		return "", err
+	}
+
+	return match.UUID, nil
+}
+
+// namedEntities lists every entity of a kind for name resolution
+func (n *NCLI) namedEntities(kind string) ([]namedEntity, error) {
+	switch kind {
+	case kindVM:
+		list, err := n.listAllVMs(new(pc.VMListRequest), 0)
+		if err != nil {
+			return nil, err
+		}
+		return pcNamedEntities(list, func(e pc.Entities) string {
+			detail := ""
+			if e.Spec.ClusterReference != nil {
+				detail = e.Spec.ClusterReference.Name
+			}
+			if e.Spec.Resources != nil && e.Spec.Resources.PowerState != nil {
+				detail = strings.TrimSpace(detail + " " + *e.Spec.Resources.PowerState)
+			}
+			return detail
+		}), nil
+	case kindImage:
+		list, err := n.listAllImages()
+		if err != nil {
+			return nil, err
+		}
+		return pcNamedEntities(list, func(e pc.Entities) string {
+			if e.Spec.Resources != nil && e.Spec.Resources.ImageType != nil {
+				return *e.Spec.Resources.ImageType
+			}
+			return ""
+		}), nil
+	case kindSubnet:
+		list, err := n.listAllSubnets()
+		if err != nil {
+			return nil, err
+		}
+		return pcNamedEntities(list, func(e pc.Entities) string {
+			if e.Spec.ClusterReference != nil {
+				return e.Spec.ClusterReference.Name
+			}
+			return ""
+		}), nil
+	case kindCluster:
+		list, err := n.listAllClusters()
+		if err != nil {
+			return nil, err
+		}
+		entities := []namedEntity{}
+		for _, e := range list {
+			if e.Metadata.UUID != nil {
+				entities = append(entities, namedEntity{Name: e.Status.Name, UUID: *e.Metadata.UUID})
+			}
+		}
+		return entities, nil
+	case kindContainer:
+		list, err := n.listAllContainers()
+		if err != nil {
+			return nil, err
+		}
+		entities := []namedEntity{}
+		for _, e := range list {
+			entities = append(entities, namedEntity{Name: e.Name, UUID: e.UUID})
+		}
+		return entities, nil
+	case kindHost:
+		list, err := n.listAllHosts()
+		if err != nil {
+			return nil, err
+		}
+		entities := []namedEntity{}
+		for _, e := range list {
+			entities = append(entities, namedEntity{Name: e.Name, UUID: e.UUID, Detail: e.HypervisorAddress})
+		}
+		return entities, nil
+	}
+
+	return nil, errors.New("unsupported entity kind for name resolution: " + kind)
+}
+
+// pcNamedEntities converts v3 entities using the spec name and metadata UUID
+func pcNamedEntities(list []pc.Entities, detail func(pc.Entities) string) []namedEntity {
+	entities := []namedEntity{}
+	for _, e := range list {
+		if e.Metadata.UUID == nil || e.Spec.Name == nil {
+			continue
+		}
+		entities = append(entities, namedEntity{Name: *e.Spec.Name, UUID: *e.Metadata.UUID, Detail: detail(e)})
+	}
+	return entities
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_matchEntities(t *testing.T) {
+	entities := []namedEntity{
+		{Name: "web-01", UUID: "1"},
+		{Name: "web-010", UUID: "2"},
+		{Name: "web-02", UUID: "3"},
+		{Name: "db-01", UUID: "4"},
+	}
+
+	tests := []struct {
+		name    string
+		pattern string
+		want    []string
+		wantErr bool
+	}{
+		{name: "exact preferred over prefix", pattern: "web-01", want: []string{"1"}},
+		{name: "prefix", pattern: "db", want: []string{"4"}},
+		{name: "prefix multiple", pattern: "web-0", want: []string{"1", "2", "3"}},
+		{name: "glob", pattern: "web-0?", want: []string{"1", "3"}},
+		{name: "glob class", pattern: "*-0[2]", want: []string{"3"}},
+		{name: "no match", pattern: "app", want: []string{}},
+		{name: "invalid glob", pattern: "web-[", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := matchEntities(entities, tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("matchEntities() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			uuids := []string{}
+			for _, e := range got {
+				uuids = append(uuids, e.UUID)
+			}
+			if !reflect.DeepEqual(uuids, tt.want) {
+				t.Errorf("matchEntities() = %v, want %v", uuids, tt.want)
+			}
+		})
+	}
+}
+
+func Test_selectEntity(t *testing.T) {
+	entities := []namedEntity{
+		{Name: "centos8", UUID: "1", Detail: "DISK_IMAGE"},
+		{Name: "centos8-iso", UUID: "2", Detail: "ISO_IMAGE"},
+	}
+
+	tests := []struct {
+		name    string
+		pattern string
+		want    string
+		wantErr string
+	}{
+		{name: "exact", pattern: "centos8", want: "1"},
+		{name: "unique prefix", pattern: "centos8-", want: "2"},
+		{name: "ambiguous", pattern: "cent*", wantErr: "ISO_IMAGE"},
+		{name: "missing", pattern: "rocky", wantErr: `no image found matching "rocky"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectEntity(kindImage, entities, tt.pattern)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("selectEntity() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("selectEntity() error = %v", err)
+			}
+			if got.UUID != tt.want {
+				t.Errorf("selectEntity() = %v, want %v", got.UUID, tt.want)
+			}
+		})
+	}
+}
+
+func Test_selectExactEntity(t *testing.T) {
+	entities := []namedEntity{
+		{Name: "web-01", UUID: "1"},
+		{Name: "web-02", UUID: "2"},
+		{Name: "db-01", UUID: "3"},
+		{Name: "db-01", UUID: "4"},
+	}
+
+	tests := []struct {
+		name    string
+		pattern string
+		want    string
+		wantErr string
+	}{
+		{name: "exact", pattern: "web-01", want: "1"},
+		{name: "unique prefix", pattern: "web-0", wantErr: `no vm named "web-0". use the exact name or the UUID of one of:`},
+		{name: "glob", pattern: "web-*", wantErr: "web-02"},
+		{name: "duplicate name", pattern: "db-01", wantErr: `2 vms are named "db-01"`},
+		{name: "missing", pattern: "app", wantErr: `no vm named "app"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectExactEntity(kindVM, entities, tt.pattern)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("selectExactEntity() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("selectExactEntity() error = %v", err)
+			}
+			if got.UUID != tt.want {
+				t.Errorf("selectExactEntity() = %v, want %v", got.UUID, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// vmSnapshot is a Prism Element v2 VM snapshot. The SDK has no snapshot type.
+type vmSnapshot struct {
+	UUID         string `json:"uuid"`
+	SnapshotName string `json:"snapshot_name"`
+	VMUUID       string `json:"vm_uuid"`
+	CreatedTime  int64  `json:"created_time"`
+	Deleted      bool   `json:"deleted"`
+	VMCreateSpec struct {
+		Name string `json:"name"`
+	} `json:"vm_create_spec"`
+}
+
+// vmSnapshotList is the v2 snapshot list response
+type vmSnapshotList struct {
+	Metadata struct {
+		TotalEntities int `json:"total_entities"`
+	} `json:"metadata"`
+	Entities []vmSnapshot `json:"entities"`
+}
+
+// created formats the snapshot creation time, reported in microseconds
+func (s vmSnapshot) created() string {
+	if s.CreatedTime == 0 {
+		return ""
+	}
+	return time.Unix(0, s.CreatedTime*int64(time.Microsecond)).UTC().Format(time.RFC3339)
+}
+
+// listAllSnapshots pages through the Prism Element snapshots. An empty
+// vmUUID returns the snapshots of every VM.
+func (n *NCLI) listAllSnapshots(vmUUID string) ([]vmSnapshot, error) {
+	pages, err := fetchPages(defaultPageSize, 0, func(offset, length int) (interface{}, int, error) {
+		query := url.Values{}
+		query.Set("page", strconv.Itoa(offset/length+1))
+		query.Set("count", strconv.Itoa(length))
+		if len(vmUUID) > 0 {
+			query.Set("vm_uuid", vmUUID)
+		}
+
+		req, err := n.con.PE.NewRequest("GET", "snapshots?"+query.Encode(), nil)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		listRes := new(vmSnapshotList)
+		if _, err := n.con.PE.Do(req, listRes); err != nil {
+			return nil, 0, err
+		}
+
+		return listRes.Entities, listRes.Metadata.TotalEntities, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := []vmSnapshot{}
+	for _, page := range pages {
+		items, _ := page.([]vmSnapshot)
+		for _, s := range items {
+			if !s.Deleted {
+				snapshots = append(snapshots, s)
+			}
+		}
+	}
+
+	return snapshots, nil
+}
+
+// findSnapshot returns the snapshot of a VM identified by its UUID or name.
+// A name shared by several snapshots is ambiguous.
+func findSnapshot(snapshots []vmSnapshot, ref string) (vmSnapshot, error) {
+	matches := []vmSnapshot{}
+	for _, s := range snapshots {
+		if s.UUID == ref {
+			return s, nil
+		}
+		if s.SnapshotName == ref {
+			matches = append(matches, s)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return vmSnapshot{}, fmt.Errorf("snapshot %s not found on the VM", ref)
+	case 1:
+		return matches[0], nil
+	}
+
+	return vmSnapshot{}, fmt.Errorf("%d snapshots are named %s. use the snapshot UUID", len(matches), ref)
+}
+
+// resolveSnapshotVM resolves the VM argument of a snapshot command and
+// returns its UUID and name
+func (n *NCLI) resolveSnapshotVM(c *cli.Context) (string, string, error) {
+	vmUUID, err := n.resolveUUID(kindVM, c.Args().First())
+	if err != nil {
+		return "", "", err
+	}
+
+	vm, err := n.getVMSpec(vmUUID)
+	if err != nil {
+		return "", "", err
+	}
+
+	return vmUUID, stringValue(vm.Spec.Name), nil
+}
+
+// resolveSnapshot resolves the VM and snapshot arguments of a snapshot
+// command and returns the VM UUID and name with the snapshot
+func (n *NCLI) resolveSnapshot(c *cli.Context) (string, string, vmSnapshot, error) {
+	ref := c.Args().Get(1)
+	if len(ref) == 0 {
+		return "", "", vmSnapshot{}, errors.New("no snapshot provided. <VM name|UUID> <snapshot name|UUID>")
+	}
+
+	vmUUID, vmName, err := n.resolveSnapshotVM(c)
+	if err != nil {
+		return "", "", vmSnapshot{}, err
+	}
+
+	snapshots, err := n.listAllSnapshots(vmUUID)
+	if err != nil {
+		return "", "", vmSnapshot{}, err
+	}
+
+	snapshot, err := findSnapshot(snapshots, ref)
+	return vmUUID, vmName, snapshot, err
+}
+
+// vmSnapshotList lists the snapshots of a VM or of every VM
+func (n *NCLI) vmSnapshotList(c *cli.Context) error {
+	vmUUID := ""
+	if c.Args().Len() > 0 {
+		var err error
+		if vmUUID, err = n.resolveUUID(kindVM, c.Args().First()); err != nil {
+			return err
+		}
+	}
+
+	snapshots, err := n.listAllSnapshots(vmUUID)
+	if err != nil {
+		return err
+	}
+
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		if snapshots[i].VMCreateSpec.Name != snapshots[j].VMCreateSpec.Name {
+			return snapshots[i].VMCreateSpec.Name < snapshots[j].VMCreateSpec.Name
+		}
+		return snapshots[i].CreatedTime < snapshots[j].CreatedTime
+	})
+
+	data := [][]string{}
+	for _, s := range snapshots {
+		data = append(data, []string{s.SnapshotName, s.UUID, s.VMCreateSpec.Name, s.created()})
+	}
+
+	return n.render(c, &Result{
+		Header: []string{"Name", "UUID", "VM", "Created"},
+		Footer: []string{"", "", "TOTAL", strconv.Itoa(len(snapshots))},
+		Rows:   data,
+		Entity: snapshots,
+	})
+}
+
+// vmSnapshotCreate takes a snapshot of a VM
+func (n *NCLI) vmSnapshotCreate(c *cli.Context) error {
+	vmUUID, vmName, err := n.resolveSnapshotVM(c)
+	if err != nil {
+		return err
+	}
+
+	name := c.String("name")
+	if len(name) == 0 {
+		name = vmName + "-" + time.Now().UTC().Format("20060102-150405")
+	}
+
+	body := map[string]interface{}{
+		"snapshot_specs": []map[string]string{{"vm_uuid": vmUUID, "snapshot_name": name}},
+	}
+
+	taskUUID, err := n.peTaskRequest("POST", "snapshots", body)
+	if err == errDryRun {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(n.out, "snapshot %s of %s submitted\n", name, vmName)
+
+	return n.reportTask(c, n.getPETask, taskUUID)
+}
+
+// vmSnapshotRestore reverts a VM to a snapshot after confirmation
+func (n *NCLI) vmSnapshotRestore(c *cli.Context) error {
+	vmUUID, vmName, snapshot, err := n.resolveSnapshot(c)
+	if err != nil {
+		return err
+	}
+
+	if !c.Bool("force") && !n.dryRun {
+		ok, err := n.confirm(fmt.Sprintf("restore %s to snapshot %s (%s)? changes made since the snapshot are lost", vmName, snapshot.SnapshotName, snapshot.created()))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("restore cancelled")
+		}
+	}
+
+	body := map[string]interface{}{
+		"snapshot_uuid":                 snapshot.UUID,
+		"restore_network_configuration": true,
+	}
+
+	taskUUID, err := n.peTaskRequest("POST", "vms/"+vmUUID+"/restore", body)
+	if err == errDryRun {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(n.out, "restore of %s to snapshot %s submitted\n", vmName, snapshot.SnapshotName)
+
+	return n.reportTask(c, n.getPETask, taskUUID)
+}
+
+// vmSnapshotClone creates a new VM from the VM spec and disks captured in a
+// snapshot
+func (n *NCLI) vmSnapshotClone(c *cli.Context) error {
+	name := c.String("name")
+	if len(name) < vmMinNameLength || len(name) > vmMaxNameLength {
+		return fmt.Errorf("no valid clone name provided. use --name <name of %d to %d characters>", vmMinNameLength, vmMaxNameLength)
+	}
+
+	_, _, snapshot, err := n.resolveSnapshot(c)
+	if err != nil {
+		return err
+	}
+
+	req, err := n.con.PE.NewRequest("GET", "snapshots/"+snapshot.UUID, nil)
+	if err != nil {
+		return err
+	}
+	var full struct {
+		VMCreateSpec map[string]interface{} `json:"vm_create_spec"`
+	}
+	if _, err := n.con.PE.Do(req, &full); err != nil {
+		return err
+	}
+	if full.VMCreateSpec == nil {
+		return fmt.Errorf("snapshot %s has no VM spec to clone", snapshot.SnapshotName)
+	}
+
+	spec := full.VMCreateSpec
+	spec["name"] = name
+	delete(spec, "uuid")
+
+	// the source VM keeps its MAC and IP addresses so the clone gets new ones
+	nics, _ := spec["vm_nics"].([]interface{})
+	for _, nic := range nics {
+		if nic, ok := nic.(map[string]interface{}); ok {
+			delete(nic, "mac_address")
+			delete(nic, "requested_ip_address")
+		}
+	}
+
+	taskUUID, err := n.peTaskRequest("POST", "vms", spec)
+	if err == errDryRun {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(n.out, "vm %s clone from snapshot %s submitted\n", name, snapshot.SnapshotName)
+
+	return n.reportTask(c, n.getPETask, taskUUID)
+}
+
+// vmSnapshotDelete deletes a VM snapshot after confirmation
+func (n *NCLI) vmSnapshotDelete(c *cli.Context) error {
+	_, vmName, snapshot, err := n.resolveSnapshot(c)
+	if err != nil {
+		return err
+	}
+
+	if !c.Bool("force") && !n.dryRun {
+		ok, err := n.confirm(fmt.Sprintf("delete snapshot %s of %s?", snapshot.SnapshotName, vmName))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("delete cancelled")
+		}
+	}
+
+	taskUUID, err := n.peTaskRequest("DELETE", "snapshots/"+snapshot.UUID, nil)
+	if err == errDryRun {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(n.out, "snapshot %s delete submitted\n", snapshot.SnapshotName)
+
+	return n.reportTask(c, n.getPETask, taskUUID)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_findSnapshot(t *testing.T) {
+	snapshots := []vmSnapshot{
+		{UUID: "snap-1", SnapshotName: "pre-patch"},
+		{UUID: "snap-2", SnapshotName: "nightly"},
+		{UUID: "snap-3", SnapshotName: "nightly"},
+	}
+
+	tests := []struct {
+		name    string
+		ref     string
+		want    string
+		wantErr string
+	}{
+		{name: "name", ref: "pre-patch", want: "snap-1"},
+		{name: "uuid", ref: "snap-3", want: "snap-3"},
+		{name: "ambiguous name", ref: "nightly", wantErr: "2 snapshots are named nightly"},
+		{name: "missing", ref: "weekly", wantErr: "not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findSnapshot(snapshots, tt.ref)
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("findSnapshot() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got.UUID != tt.want {
+				t.Errorf("findSnapshot() = %v, %v, want %v", got.UUID, err, tt.want)
+			}
+		})
+	}
+}
+
+func Test_vmSnapshot_created(t *testing.T) {
+	if got := (vmSnapshot{CreatedTime: 1700000000000000}).created(); got != "2023-11-14T22:13:20Z" {
+		t.Errorf("created() = %v", got)
+	}
+	if got := (vmSnapshot{}).created(); got != "" {
+		t.Errorf("created() of an unset time = %v", got)
+	}
+}
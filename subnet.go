@@ -9,36 +9,11 @@ import (
 
 func (n *NCLI) listSubnets(c *cli.Context) error {
 
-	ListRequest := new(pc.SubnetListRequest)
-
-	var vmListLoop []pc.Entities
-	totalMatches := 0
-	offset := 0
-	currentMatches := -1
-	var err error
-	getRes := &pc.SubnetListResponse{}
-
-	for totalMatches > currentMatches {
-		if currentMatches == -1 {
-			currentMatches = 0
-		}
-
-		ListRequest.Offset = offset
-
-		getRes, _, err = n.con.PC.Subnet.List(ListRequest)
-		if err != nil {
-			return err
-		}
-
-		currentMatches += *getRes.Metadata.Length
-		totalMatches = *getRes.Metadata.TotalMatches
-		offset += ListRequest.Length
-		vmListLoop = append(vmListLoop, getRes.Entities...)
+	vmListLoop, err := n.listAllSubnets()
+	if err != nil {
+		return err
 	}
 
-	n.tr.SetHeader([]string{"Name", "UUID", "Network", "CIDR", "VLAN", "TYPE"})
-	n.tr.SetFooter([]string{"", "", "", "", "TOTAL", strconv.Itoa(*getRes.Metadata.TotalMatches)})
-
 	data := [][]string{}
 
 	for _, entityValue := range vmListLoop {
@@ -52,41 +27,22 @@ func (n *NCLI) listSubnets(c *cli.Context) error {
 
 		data = append(data, []string{*entityValue.Spec.Name, *entityValue.Metadata.UUID, gwAddress, gwPrefix, strconv.Itoa(*entityValue.Spec.Resources.VlanID), *entityValue.Spec.Resources.SubnetType})
 	}
-	n.tr.AppendBulk(data)
-	n.tr.Render()
 
-	return nil
+	return n.render(c, &Result{
+		Header: []string{"Name", "UUID", "Network", "CIDR", "VLAN", "TYPE"},
+		Footer: []string{"", "", "", "", "TOTAL", strconv.Itoa(len(vmListLoop))},
+		Rows:   data,
+		Entity: vmListLoop,
+	})
 }
 
 func (n *NCLI) getSubnetUUIDList() ([]string, error) {
 
-	ListRequest := new(pc.SubnetListRequest)
-
 	data := []string{}
 
-	var vmListLoop []pc.Entities
-	totalMatches := 0
-	offset := 0
-	currentMatches := -1
-	var err error
-	getRes := &pc.SubnetListResponse{}
-
-	for totalMatches > currentMatches {
-		if currentMatches == -1 {
-			currentMatches = 0
-		}
-
-		ListRequest.Offset = offset
-
-		getRes, _, err = n.con.PC.Subnet.List(ListRequest)
-		if err != nil {
-			return nil, err
-		}
-
-		currentMatches += *getRes.Metadata.Length
-		totalMatches = *getRes.Metadata.TotalMatches
-		offset += ListRequest.Length
-		vmListLoop = append(vmListLoop, getRes.Entities...)
+	vmListLoop, err := n.listAllSubnets()
+	if err != nil {
+		return nil, err
 	}
 
 	for _, entityValue := range vmListLoop {
@@ -95,3 +51,17 @@ func (n *NCLI) getSubnetUUIDList() ([]string, error) {
 
 	return data, nil
 }
+
+// listAllSubnets pages through the subnet list API and returns every subnet
+func (n *NCLI) listAllSubnets() ([]pc.Entities, error) {
+	return fetchPCEntities(0, func(offset, length int) ([]pc.Entities, int, error) {
+		ListRequest := &pc.SubnetListRequest{Offset: offset, Length: length}
+
+		getRes, _, err := n.con.PC.Subnet.List(ListRequest)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return getRes.Entities, *getRes.Metadata.TotalMatches, nil
+	})
+}
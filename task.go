@@ -0,0 +1,531 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/routebyintuition/ntnx-go-sdk/pc"
+	"github.com/urfave/cli/v2"
+)
+
+// taskPollInterval is the delay between task status requests while waiting
+var taskPollInterval = 2 * time.Second
+
+// defaultTaskTimeout is how long --wait blocks before giving up on a task
+const defaultTaskTimeout = 10 * time.Minute
+
+// taskStatus is the normalized view of a Prism Central or Prism Element task
+type taskStatus struct {
+	UUID      string
+	Operation string
+	Status    string
+	Percent   int
+	Message   string
+}
+
+// peTask is the Prism Element v2 task response
+type peTask struct {
+	UUID               string `json:"uuid"`
+	OperationType      string `json:"operation_type"`
+	ProgressStatus     string `json:"progress_status"`
+	PercentageComplete int    `json:"percentage_complete"`
+	MetaResponse       struct {
+		Error       string `json:"error"`
+		ErrorDetail string `json:"error_detail"`
+	} `json:"meta_response"`
+}
+
+// taskGetFunc returns the current status of a task
+type taskGetFunc func(uuid string) (*taskStatus, error)
+
+// done reports whether the task reached a terminal state
+func (t *taskStatus) done() bool {
+	switch strings.ToUpper(t.Status) {
+	case "SUCCEEDED", "FAILED", "ABORTED":
+		return true
+	}
+	return false
+}
+
+// failed reports whether the task finished unsuccessfully
+func (t *taskStatus) failed() bool {
+	switch strings.ToUpper(t.Status) {
+	case "FAILED", "ABORTED":
+		return true
+	}
+	return false
+}
+
+// getPCTask retrieves a task from the Prism Central v3 API
+func (n *NCLI) getPCTask(uuid string) (*taskStatus, error) {
+	getRes, _, err := n.con.PC.Task.Get(&pc.TaskGetRequest{UUID: uuid})
+	if err != nil {
+		return nil, err
+	}
+
+	return &taskStatus{
+		UUID:      getRes.UUID,
+		Operation: getRes.OperationType,
+		Status:    strings.ToUpper(getRes.Status),
+		Percent:   getRes.PercentageComplete,
+		Message:   strings.TrimSpace(getRes.ErrorCode + " " + getRes.ErrorDetail),
+	}, nil
+}
+
+// peTaskRequest sends a Prism Element v2 request that answers with a task
+// UUID. With --dry-run the request is printed instead.
+func (n *NCLI) peTaskRequest(method, path string, body interface{}) (string, error) {
+	if n.dryRun {
+		return "", n.dryRunRequest(method, path, nil, body)
+	}
+
+	req, err := n.con.PE.NewRequest(method, path, body)
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		TaskUUID string `json:"task_uuid"`
+	}
+	if _, err := n.con.PE.Do(req, &result); err != nil {
+		return "", err
+	}
+
+	return result.TaskUUID, nil
+}
+
+// getPETask retrieves a task from the Prism Element v2 API
+func (n *NCLI) getPETask(uuid string) (*taskStatus, error) {
+	req, err := n.con.PE.NewRequest("GET", "tasks/"+uuid, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	task := new(peTask)
+	if _, err := n.con.PE.Do(req, task); err != nil {
+		return nil, err
+	}
+
+	message := ""
+	if task.MetaResponse.Error != "" && task.MetaResponse.Error != "kNoError" {
+		message = strings.TrimSpace(task.MetaResponse.Error + " " + task.MetaResponse.ErrorDetail)
+	}
+
+	return &taskStatus{
+		UUID:      task.UUID,
+		Operation: task.OperationType,
+		Status:    strings.ToUpper(task.ProgressStatus),
+		Percent:   task.PercentageComplete,
+		Message:   message,
+	}, nil
+}
+
+// waitForTask polls a task until it finishes or the timeout expires. A task
+// that fails or is aborted is returned along with an error.
+func waitForTask(get taskGetFunc, uuid string, timeout time.Duration) (*taskStatus, error) {
+	if len(uuid) == 0 {
+		return nil, errors.New("no task UUID returned to wait on")
+	}
+
+	deadline := time.Now().Add(timeout)
+
+	for {
+		task, err := get(uuid)
+		if err != nil {
+			return nil, err
+		}
+
+		if task.done() {
+			if task.failed() {
+				return task, fmt.Errorf("task %s %s: %s", uuid, strings.ToLower(task.Status), task.Message)
+			}
+			return task, nil
+		}
+
+		if time.Now().Add(taskPollInterval).After(deadline) {
+			return task, fmt.Errorf("timed out after %v waiting for task %s (%s %d%%)", timeout, uuid, strings.ToLower(task.Status), task.Percent)
+		}
+
+		time.Sleep(taskPollInterval)
+	}
+}
+
+// waitForTasks polls every task concurrently until they all finish or the
+// timeout expires. Statuses and errors are returned in the order of uuids.
+func waitForTasks(get taskGetFunc, uuids []string, timeout time.Duration) ([]*taskStatus, []error) {
+	tasks := make([]*taskStatus, len(uuids))
+	errs := make([]error, len(uuids))
+
+	var wg sync.WaitGroup
+	for i, uuid := range uuids {
+		wg.Add(1)
+		go func(i int, uuid string) {
+			defer wg.Done()
+			tasks[i], errs[i] = waitForTask(get, uuid, timeout)
+		}(i, uuid)
+	}
+	wg.Wait()
+
+	return tasks, errs
+}
+
+// executionTask returns the first task UUID from an intentful response status
+func executionTask(status pc.Status) string {
+	if status.ExecutionContext == nil || len(status.ExecutionContext.TaskUuids) == 0 {
+		return ""
+	}
+	return status.ExecutionContext.TaskUuids[0]
+}
+
+// pcTaskListResponse is the v3 task list response. The SDK decodes the task
+// list into the generic entity type which drops the task fields.
+type pcTaskListResponse struct {
+	Metadata pc.Metadata          `json:"metadata"`
+	Entities []pc.TaskGetResponse `json:"entities"`
+}
+
+// taskFilter selects tasks on the client side as the v3 task list does not
+// support FIQL filters
+type taskFilter struct {
+	status    string
+	entity    string
+	operation string
+}
+
+// match reports whether a task satisfies every filter that is set. Entities
+// match on UUID or on a name glob.
+func (f taskFilter) match(t pc.TaskGetResponse) bool {
+	if len(f.status) > 0 && !strings.EqualFold(f.status, t.Status) {
+		return false
+	}
+	if len(f.operation) > 0 && !strings.Contains(strings.ToLower(t.OperationType), strings.ToLower(f.operation)) {
+		return false
+	}
+	if len(f.entity) == 0 {
+		return true
+	}
+	for _, e := range t.EntityReferenceList {
+		if e.UUID == f.entity {
+			return true
+		}
+		if ok, _ := path.Match(f.entity, e.Name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// taskEntities formats the entities a task operates on
+func taskEntities(t pc.TaskGetResponse) string {
+	entities := []string{}
+	for _, e := range t.EntityReferenceList {
+		name := e.Name
+		if len(name) == 0 {
+			name = e.UUID
+		}
+		entities = append(entities, e.Kind+":"+name)
+	}
+	return strings.Join(entities, " ")
+}
+
+// listAllTasks pages through the v3 task list
+func (n *NCLI) listAllTasks() ([]pc.TaskGetResponse, error) {
+	pages, err := fetchPages(defaultPageSize, 0, func(offset, length int) (interface{}, int, error) {
+		req, err := n.con.PC.NewRequest("POST", "tasks/list", &pc.TaskListRequest{Kind: "task", Offset: offset, Length: length})
+		if err != nil {
+			return nil, 0, err
+		}
+
+		listRes := new(pcTaskListResponse)
+		if _, err := n.con.PC.Do(req, listRes); err != nil {
+			return nil, 0, err
+		}
+
+		total := len(listRes.Entities)
+		if listRes.Metadata.TotalMatches != nil {
+			total = *listRes.Metadata.TotalMatches
+		}
+
+		return listRes.Entities, total, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := []pc.TaskGetResponse{}
+	for _, page := range pages {
+		items, _ := page.([]pc.TaskGetResponse)
+		tasks = append(tasks, items...)
+	}
+
+	return tasks, nil
+}
+
+// taskList returns the Prism Central tasks matching the filter flags
+func (n *NCLI) taskList(c *cli.Context) error {
+	if c.Int("limit") < 0 {
+		return errors.New("invalid limit value...should be a positive integer")
+	}
+
+	tasks, err := n.listAllTasks()
+	if err != nil {
+		return err
+	}
+
+	filter := taskFilter{status: c.String("status"), entity: c.String("entity"), operation: c.String("operation")}
+
+	matched := []pc.TaskGetResponse{}
+	data := [][]string{}
+
+	for _, t := range tasks {
+		if !filter.match(t) {
+			continue
+		}
+		if c.Int("limit") > 0 && len(matched) == c.Int("limit") {
+			break
+		}
+		matched = append(matched, t)
+		data = append(data, []string{t.UUID, t.OperationType, t.Status, strconv.Itoa(t.PercentageComplete) + "%", taskEntities(t), t.CreationTime})
+	}
+
+	return n.render(c, &Result{
+		Header: []string{"UUID", "Operation", "Status", "Progress", "Entities", "Created"},
+		Footer: []string{"", "", "", "", "TOTAL", strconv.Itoa(len(matched))},
+		Rows:   data,
+		Entity: matched,
+	})
+}
+
+// taskGet returns the details of a single task
+func (n *NCLI) taskGet(c *cli.Context) error {
+	taskUUID := c.Args().First()
+	if len(taskUUID) == 0 {
+		return errors.New("no task UUID provided")
+	}
+
+	getRes, _, err := n.con.PC.Task.Get(&pc.TaskGetRequest{UUID: taskUUID})
+	if err != nil {
+		return err
+	}
+
+	subtasks := []string{}
+	for _, s := range getRes.SubtaskReferenceList {
+		subtasks = append(subtasks, s.UUID)
+	}
+
+	data := [][]string{
+		{"UUID", getRes.UUID},
+		{"Operation", getRes.OperationType},
+		{"Status", getRes.Status},
+		{"Progress", strconv.Itoa(getRes.PercentageComplete) + "%"},
+		{"Message", getRes.ProgressMessage},
+		{"Entities", taskEntities(*getRes)},
+		{"Parent Task", getRes.ParentTaskReference.UUID},
+		{"Subtasks", strings.Join(subtasks, " ")},
+		{"Cluster", getRes.ClusterReference.UUID},
+		{"Created", getRes.CreationTime},
+		{"Completed", getRes.CompletionTime},
+		{"Error", strings.TrimSpace(getRes.ErrorCode + " " + getRes.ErrorDetail)},
+	}
+
+	return n.render(c, &Result{
+		Header: []string{"Attribute", "Value"},
+		Rows:   data,
+		Entity: getRes,
+	})
+}
+
+// taskWait blocks until every task provided finishes and fails if any of them
+// did not succeed
+func (n *NCLI) taskWait(c *cli.Context) error {
+	if c.Args().Len() == 0 {
+		return errors.New("no task UUID provided")
+	}
+
+	taskUUIDs := c.Args().Slice()
+	tasks, errs := waitForTasks(n.getPCTask, taskUUIDs, c.Duration("timeout"))
+
+	data := [][]string{}
+	failed := 0
+
+	for i, taskUUID := range taskUUIDs {
+		task := tasks[i]
+		if errs[i] != nil {
+			failed++
+			if task == nil {
+				task = &taskStatus{UUID: taskUUID, Message: errs[i].Error()}
+			}
+		}
+		data = append(data, []string{task.UUID, task.Operation, task.Status, strconv.Itoa(task.Percent) + "%", task.Message})
+	}
+
+	err := n.render(c, &Result{
+		Header: []string{"UUID", "Operation", "Status", "Progress", "Message"},
+		Rows:   data,
+	})
+	if err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d tasks did not succeed", failed, c.Args().Len())
+	}
+
+	return nil
+}
+
+// taskWatch redraws a progress table for the provided tasks, or for every
+// running task when none are provided, until they all finish
+func (n *NCLI) taskWatch(c *cli.Context) error {
+	taskUUIDs := c.Args().Slice()
+
+	if len(taskUUIDs) == 0 {
+		tasks, err := n.listAllTasks()
+		if err != nil {
+			return err
+		}
+		for _, t := range tasks {
+			status := &taskStatus{Status: strings.ToUpper(t.Status)}
+			if !status.done() && len(t.ParentTaskReference.UUID) == 0 {
+				taskUUIDs = append(taskUUIDs, t.UUID)
+			}
+		}
+	}
+
+	if len(taskUUIDs) == 0 {
+		fmt.Fprintln(n.out, "no running tasks")
+		return nil
+	}
+
+	interval := c.Duration("interval")
+	if interval <= 0 {
+		interval = taskPollInterval
+	}
+	deadline := time.Now().Add(c.Duration("timeout"))
+	table := strings.ToLower(c.String("output")) == "table" || len(c.String("output")) == 0
+	clear := table && isTerminalWriter(n.out)
+
+	for {
+		data, tasks, err := n.taskProgress(taskUUIDs)
+		if err != nil {
+			return err
+		}
+
+		done := true
+		failed := 0
+		for _, t := range tasks {
+			status := &taskStatus{Status: strings.ToUpper(t.Status)}
+			if !status.done() {
+				done = false
+			}
+			if status.failed() {
+				failed++
+			}
+		}
+
+		if table {
+			if clear {
+				fmt.Fprint(n.out, "\033[H\033[2J")
+			}
+			tr := tablewriter.NewWriter(n.out)
+			tr.SetHeader([]string{"UUID", "Operation", "Status", "Progress", "Entities"})
+			tr.SetAutoWrapText(false)
+			tr.AppendBulk(data)
+			tr.Render()
+		}
+
+		if done {
+			if !table {
+				if err := n.render(c, &Result{Entity: tasks}); err != nil {
+					return err
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d of %d tasks did not succeed", failed, len(tasks))
+			}
+			return nil
+		}
+
+		if time.Now().Add(interval).After(deadline) {
+			return fmt.Errorf("timed out after %v watching %d tasks", c.Duration("timeout"), len(tasks))
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+// taskProgress returns a progress row for each task followed by rows for its
+// subtasks along with the top level tasks
+func (n *NCLI) taskProgress(taskUUIDs []string) ([][]string, []pc.TaskGetResponse, error) {
+	data := [][]string{}
+	tasks := []pc.TaskGetResponse{}
+
+	for _, taskUUID := range taskUUIDs {
+		getRes, _, err := n.con.PC.Task.Get(&pc.TaskGetRequest{UUID: taskUUID})
+		if err != nil {
+			return nil, nil, err
+		}
+		tasks = append(tasks, *getRes)
+		data = append(data, taskProgressRow(*getRes, ""))
+
+		for _, s := range getRes.SubtaskReferenceList {
+			subRes, _, err := n.con.PC.Task.Get(&pc.TaskGetRequest{UUID: s.UUID})
+			if err != nil {
+				return nil, nil, err
+			}
+			data = append(data, taskProgressRow(*subRes, "  └ "))
+		}
+	}
+
+	return data, tasks, nil
+}
+
+// taskProgressRow formats a task with a progress bar
+func taskProgressRow(t pc.TaskGetResponse, indent string) []string {
+	return []string{indent + t.UUID, t.OperationType, t.Status, progressBar(t.PercentageComplete), taskEntities(t)}
+}
+
+// progressBar draws a fixed width bar for a percentage
+func progressBar(percent int) string {
+	if percent < 0 {
+		percent = 0
+	}
+	if percent > 100 {
+		percent = 100
+	}
+	filled := percent / 5
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(".", 20-filled) + "] " + strconv.Itoa(percent) + "%"
+}
+
+// waitIfRequested blocks on a task when --wait is set. It returns nil when the
+// command was not asked to wait.
+func (n *NCLI) waitIfRequested(c *cli.Context, get taskGetFunc, taskUUID string) (*taskStatus, error) {
+	if !c.Bool("wait") {
+		return nil, nil
+	}
+
+	return waitForTask(get, taskUUID, c.Duration("timeout"))
+}
+
+// reportTask prints the task started by a mutating command and waits on it
+// when --wait is set
+func (n *NCLI) reportTask(c *cli.Context, get taskGetFunc, taskUUID string) error {
+	if len(taskUUID) > 0 {
+		fmt.Fprintf(n.out, "task: %s\n", taskUUID)
+	}
+
+	task, err := n.waitIfRequested(c, get, taskUUID)
+	if err != nil || task == nil {
+		return err
+	}
+
+	fmt.Fprintln(n.out, "task", task.UUID, strings.ToLower(task.Status))
+
+	return nil
+}
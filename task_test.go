@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/routebyintuition/ntnx-go-sdk/pc"
+)
+
+func Test_waitForTask(t *testing.T) {
+	oldInterval := taskPollInterval
+	taskPollInterval = time.Millisecond
+	t.Cleanup(func() { taskPollInterval = oldInterval })
+
+	tests := []struct {
+		name     string
+		statuses []string
+		getErr   error
+		timeout  time.Duration
+		want     string
+		wantErr  string
+	}{
+		{name: "succeeds after polling", statuses: []string{"QUEUED", "RUNNING", "SUCCEEDED"}, timeout: time.Second, want: "SUCCEEDED"},
+		{name: "prism element casing", statuses: []string{"Running", "Succeeded"}, timeout: time.Second, want: "SUCCEEDED"},
+		{name: "failed", statuses: []string{"RUNNING", "FAILED"}, timeout: time.Second, wantErr: "failed: boom"},
+		{name: "aborted", statuses: []string{"ABORTED"}, timeout: time.Second, wantErr: "aborted"},
+		{name: "timeout", statuses: []string{"RUNNING"}, timeout: 0, wantErr: "timed out"},
+		{name: "get error", getErr: errors.New("connection refused"), timeout: time.Second, wantErr: "connection refused"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			get := func(uuid string) (*taskStatus, error) {
+				if tt.getErr != nil {
+					return nil, tt.getErr
+				}
+				status := tt.statuses[len(tt.statuses)-1]
+				if calls < len(tt.statuses) {
+					status = tt.statuses[calls]
+				}
+				calls++
+				return &taskStatus{UUID: uuid, Status: strings.ToUpper(status), Message: "boom"}, nil
+			}
+
+			got, err := waitForTask(get, "task-1", tt.timeout)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("waitForTask() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("waitForTask() error = %v", err)
+			}
+			if got.Status != tt.want {
+				t.Errorf("waitForTask() status = %v, want %v", got.Status, tt.want)
+			}
+		})
+	}
+
+	if _, err := waitForTask(nil, "", time.Second); err == nil {
+		t.Errorf("waitForTask() accepted an empty task UUID")
+	}
+}
+
+func Test_taskFilter_match(t *testing.T) {
+	task := pc.TaskGetResponse{
+		OperationType:       "kVmSetPowerState",
+		Status:              "FAILED",
+		EntityReferenceList: []pc.EntityReferenceList{{Kind: "vm", UUID: "3d204c69-7fae-4021-9c53-8b4fae6d9a03", Name: "db-01"}},
+	}
+
+	tests := []struct {
+		name   string
+		filter taskFilter
+		want   bool
+	}{
+		{name: "no filter", filter: taskFilter{}, want: true},
+		{name: "status any case", filter: taskFilter{status: "failed"}, want: true},
+		{name: "status mismatch", filter: taskFilter{status: "RUNNING"}, want: false},
+		{name: "operation substring", filter: taskFilter{operation: "powerstate"}, want: true},
+		{name: "operation mismatch", filter: taskFilter{operation: "create"}, want: false},
+		{name: "entity uuid", filter: taskFilter{entity: "3d204c69-7fae-4021-9c53-8b4fae6d9a03"}, want: true},
+		{name: "entity glob", filter: taskFilter{entity: "db-*"}, want: true},
+		{name: "entity mismatch", filter: taskFilter{entity: "web-01"}, want: false},
+		{name: "all filters", filter: taskFilter{status: "FAILED", operation: "Power", entity: "db-01"}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.match(task); got != tt.want {
+				t.Errorf("taskFilter.match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_progressBar(t *testing.T) {
+	tests := []struct {
+		percent int
+		want    string
+	}{
+		{percent: 0, want: "[....................] 0%"},
+		{percent: 42, want: "[########............] 42%"},
+		{percent: 100, want: "[####################] 100%"},
+		{percent: 140, want: "[####################] 100%"},
+		{percent: -5, want: "[....................] 0%"},
+	}
+	for _, tt := range tests {
+		if got := progressBar(tt.percent); got != tt.want {
+			t.Errorf("progressBar(%d) = %v, want %v", tt.percent, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v2"
+)
+
+// templateVarName matches variable names that can also be called as template
+// functions so that placeholders such as {{SUBNET_UUID}} work
+var templateVarName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// templateReserved are function names that variables may not replace
+var templateReserved = []string{
+	"and", "call", "html", "index", "slice", "js", "len", "not", "or", "print", "printf", "println", "urlquery",
+	"eq", "ge", "gt", "le", "lt", "ne",
+	"var", "subnet", "image", "cluster", "vm",
+}
+
+// loadTemplateVars merges template variables from the environment, a YAML
+// variable file and key=value pairs. Later sources take precedence.
+func loadTemplateVars(environ []string, varFile string, pairs []string) (map[string]string, error) {
+	vars := map[string]string{}
+
+	for _, kv := range environ {
+		if i := strings.Index(kv, "="); i > 0 {
+			vars[kv[:i]] = kv[i+1:]
+		}
+	}
+
+	if len(varFile) > 0 {
+		raw, err := ioutil.ReadFile(varFile)
+		if err != nil {
+			return nil, err
+		}
+		fileVars := map[string]interface{}{}
+		if err := yaml.Unmarshal(raw, &fileVars); err != nil {
+			return nil, fmt.Errorf("unable to read variable file %s: %v", varFile, err)
+		}
+		for k, v := range fileVars {
+			vars[k] = fmt.Sprint(v)
+		}
+	}
+
+	for _, kv := range pairs {
+		i := strings.Index(kv, "=")
+		if i < 1 {
+			return nil, fmt.Errorf("invalid variable %q. use key=value", kv)
+		}
+		vars[kv[:i]] = kv[i+1:]
+	}
+
+	return vars, nil
+}
+
+// renderTemplate executes a spec as a text/template. Variables are available
+// as {{ .NAME }}, {{ var "NAME" }} or {{ NAME }} and the provided functions
+// are added to the template. Missing variables are an error.
+func renderTemplate(name string, raw []byte, vars map[string]string, funcs template.FuncMap) ([]byte, error) {
+	fm := template.FuncMap{
+		"var": func(key string) (string, error) {
+			v, ok := vars[key]
+			if !ok {
+				return "", fmt.Errorf("variable %s is not defined", key)
+			}
+			return v, nil
+		},
+	}
+	for k, v := range vars {
+		if !templateVarName.MatchString(k) || stringSliceContains(templateReserved, k) {
+			continue
+		}
+		value := v
+		fm[k] = func() string { return value }
+	}
+	for k, f := range funcs {
+		fm[k] = f
+	}
+
+	tmpl, err := template.New(name).Funcs(fm).Option("missingkey=error").Parse(string(raw))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse template %s: %v", name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return nil, fmt.Errorf("unable to render template %s: %v", name, err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// templateFuncs returns the lookup functions that resolve entity names to
+// UUIDs against Prism Central. Names must match exactly.
+func (n *NCLI) templateFuncs() template.FuncMap {
+	lookup := func(kind string) func(string) (string, error) {
+		return func(name string) (string, error) {
+			return n.resolveExactUUID(kind, name)
+		}
+	}
+
+	return template.FuncMap{
+		"subnet":  lookup(kindSubnet),
+		"image":   lookup(kindImage),
+		"cluster": lookup(kindCluster),
+		"vm":      lookup(kindVM),
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func Test_loadTemplateVars(t *testing.T) {
+	varFile := writeTestFile(t, "vars.yaml", "SUBNET_UUID: from-file\nIMAGE_UUID: image-file\nCPUS: 4\n")
+
+	tests := []struct {
+		name    string
+		environ []string
+		varFile string
+		pairs   []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:    "environment",
+			environ: []string{"SUBNET_UUID=from-env", "EMPTY="},
+			want:    map[string]string{"SUBNET_UUID": "from-env", "EMPTY": ""},
+		},
+		{
+			name:    "file overrides environment",
+			environ: []string{"SUBNET_UUID=from-env"},
+			varFile: varFile,
+			want:    map[string]string{"SUBNET_UUID": "from-file", "IMAGE_UUID": "image-file", "CPUS": "4"},
+		},
+		{
+			name:    "flags override file",
+			varFile: varFile,
+			pairs:   []string{"SUBNET_UUID=from-flag", "NAME=a=b"},
+			want:    map[string]string{"SUBNET_UUID": "from-flag", "IMAGE_UUID": "image-file", "CPUS": "4", "NAME": "a=b"},
+		},
+		{name: "invalid pair", pairs: []string{"novalue"}, wantErr: true},
+		{name: "missing file", varFile: "does-not-exist.yaml", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := loadTemplateVars(tt.environ, tt.varFile, tt.pairs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadTemplateVars() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadTemplateVars() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_renderTemplate(t *testing.T) {
+	vars := map[string]string{"SUBNET_UUID": "subnet-1", "len": "shadowed", "my-var": "dashed"}
+	funcs := template.FuncMap{"image": func(name string) string { return "uuid-of-" + name }}
+
+	tests := []struct {
+		name    string
+		raw     string
+		want    string
+		wantErr string
+	}{
+		{name: "bare placeholder", raw: `uuid: "{{SUBNET_UUID}}"`, want: `uuid: "subnet-1"`},
+		{name: "field access", raw: `uuid: {{ .SUBNET_UUID }}`, want: `uuid: subnet-1`},
+		{name: "var function", raw: `name: {{ var "my-var" }}`, want: `name: dashed`},
+		{name: "lookup function", raw: `uuid: {{ image "centos8" }}`, want: `uuid: uuid-of-centos8`},
+		{name: "builtins are not replaced", raw: `{{ len "abc" }}`, want: `3`},
+		{name: "no placeholders", raw: "spec:\n  name: vm", want: "spec:\n  name: vm"},
+		{name: "undefined placeholder", raw: `{{IMAGE_UUID}}`, wantErr: `"IMAGE_UUID" not defined`},
+		{name: "undefined field", raw: `{{ .IMAGE_UUID }}`, wantErr: "IMAGE_UUID"},
+		{name: "undefined var", raw: `{{ var "IMAGE_UUID" }}`, wantErr: "variable IMAGE_UUID is not defined"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderTemplate("spec.yaml", []byte(tt.raw), vars, funcs)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("renderTemplate() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("renderTemplate() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("renderTemplate() = %v, want %v", string(got), tt.want)
+			}
+		})
+	}
+}
@@ -1,11 +1,11 @@
 package main
 
 import (
-	"bufio"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -16,152 +16,425 @@ import (
 )
 
 func (n *NCLI) vmCreate(c *cli.Context) error {
-	createInstruct := &pc.VMCreateRequest{}
+	var raw []byte
+	var err error
+	source := "stdin"
 
 	if isInputFromPipe() {
-		createInstructStruct, err := processYAMLReader(os.Stdin, createInstruct)
+		raw, err = ioutil.ReadAll(os.Stdin)
 		if err != nil {
 			return err
 		}
-		outJSON, _ := json.MarshalIndent(createInstructStruct, "", "  ")
-		fmt.Println("YAML process results: ", string(outJSON))
-
 	} else if len(c.String("vm-yaml")) > 0 {
-		dir, err := isDirectory(c.String("vm-yaml"))
+		source = c.String("vm-yaml")
+		dir, err := isDirectory(source)
 		if err != nil {
 			return err
 		}
 		if dir {
 			return errors.New("path provided is a directory...single yaml file needed")
 		}
-		fh, err := os.Open(c.String("vm-yaml"))
+		raw, err = ioutil.ReadFile(source)
 		if err != nil {
 			return err
 		}
-		fhr := bufio.NewReader(fh)
-		createInstructStruct, err := processYAMLReader(fhr, createInstruct)
-		outJSON, _ := json.MarshalIndent(createInstructStruct, "", "  ")
-		fmt.Println("YAML process results: ", string(outJSON))
-
 	} else {
 		return errors.New("no yaml config provided via stdin or file")
 	}
 
-	err := n.ValidYAMLCreate(createInstruct)
+	vars, err := loadTemplateVars(os.Environ(), c.String("var-file"), c.StringSlice("var"))
 	if err != nil {
 		return err
 	}
 
-	getRes, _, err := n.con.PC.VM.Create(createInstruct)
+	docs, err := n.loadVMDocuments(source, raw, vars, guestFiles{UserData: c.String("cloud-init"), MetaData: c.String("meta-data"), UnattendXML: c.String("sysprep")})
 	if err != nil {
 		return err
 	}
+	if len(docs) > 1 {
+		return errors.New("vm create takes a single VM spec. use apply for multiple VMs")
+	}
 
-	outJSON, _ := json.MarshalIndent(getRes, "", "  ")
-	fmt.Println("YAML process results: ", string(outJSON))
+	err = n.ValidYAMLCreate(docs[0].Request)
+	if err != nil {
+		return err
+	}
 
-	return nil
+	getRes, err := n.createVM(docs[0])
+	if err == errDryRun {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	name := ""
+	if getRes.Spec.Name != nil {
+		name = *getRes.Spec.Name
+	}
+	uuid := ""
+	if getRes.Metadata.UUID != nil {
+		uuid = *getRes.Metadata.UUID
+	}
+
+	state := getRes.Status.State
+	taskUUID := executionTask(getRes.Status)
+
+	task, err := n.waitIfRequested(c, n.getPCTask, taskUUID)
+	if err != nil {
+		return err
+	}
+	if task != nil {
+		state = task.Status
+	}
+
+	return n.render(c, &Result{
+		Header: []string{"Name", "UUID", "State", "Task"},
+		Rows:   [][]string{{name, uuid, state, taskUUID}},
+		Entity: getRes,
+	})
 }
 
 func (n *NCLI) vmList(c *cli.Context) error {
 
+	filter, err := buildVMFilter(c.String("filter"), c.String("cluster"), c.String("power-state"), c.String("name-regex"))
+	if err != nil {
+		return err
+	}
+
+	sortOrder := strings.ToUpper(c.String("sort-order"))
+	if len(sortOrder) > 0 && sortOrder != "ASCENDING" && sortOrder != "DESCENDING" {
+		return errors.New("invalid sort order. <ASCENDING, DESCENDING>")
+	}
+
+	if c.Int("limit") < 0 {
+		return errors.New("invalid limit value...should be a positive integer")
+	}
+
 	ListRequest := new(pc.VMListRequest)
-	ListRequest.Length = 40
+	ListRequest.Filter = filter
+	ListRequest.SortAttribute = c.String("sort-attribute")
+	ListRequest.SortOrder = sortOrder
 
-	var vmListLoop []pc.Entities
-	totalMatches := 0
-	offset := 0
-	currentMatches := -1
-	var err error
-	var getRes *pc.VMListResponse
+	vmListLoop, err := n.listAllVMs(ListRequest, c.Int("limit"))
+	if err != nil {
+		return err
+	}
 
-	for totalMatches > currentMatches {
-		if currentMatches == -1 {
-			currentMatches = 0
-		}
+	data := [][]string{}
 
-		ListRequest.Offset = offset
+	for _, entityValue := range vmListLoop {
+		data = append(data, []string{*entityValue.Spec.Name, *entityValue.Metadata.UUID, *entityValue.Spec.Resources.PowerState, entityValue.Spec.ClusterReference.Name})
+	}
+
+	return n.render(c, &Result{
+		Header: []string{"Name", "UUID", "Powered", "Cluster"},
+		Footer: []string{"Total", "", "", strconv.Itoa(len(vmListLoop))},
+		Rows:   data,
+		Entity: vmListLoop,
+	})
+}
+
+func (n *NCLI) vmMemoryUpdate(c *cli.Context) error {
+	vmUUID, err := n.resolveUUID(kindVM, c.Args().First())
+	if err != nil {
+		return err
+	}
 
-		getRes, _, err = n.con.PC.VM.List(ListRequest)
+	if len(c.Args().Get(1)) == 0 {
+		return errors.New("no memory value provided...should be a size such as 8000, 8GB or 8GiB")
+	}
+
+	// a bare number is read in MB as update-memory always has
+	memMib, err := parseVMMemory(c.Args().Get(1), "MB")
+	if err != nil {
+		return err
+	}
+
+	return n.updateResources(c, vmUUID, resourceChange{memoryMib: memMib}, "vm updated to "+c.Args().Get(1)+" memory")
+}
+
+// vmCPUUpdate changes the socket and cores per socket topology of a VM
+func (n *NCLI) vmCPUUpdate(c *cli.Context) error {
+	vmUUID, err := n.resolveUUID(kindVM, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	if !c.IsSet("sockets") && !c.IsSet("cores-per-socket") {
+		return errors.New("no CPU value provided. use --sockets and/or --cores-per-socket")
+	}
+
+	ch := resourceChange{sockets: c.Int("sockets"), coresPerSocket: c.Int("cores-per-socket")}
+	if (c.IsSet("sockets") && ch.sockets < 1) || (c.IsSet("cores-per-socket") && ch.coresPerSocket < 1) {
+		return errors.New("invalid CPU value...sockets and cores per socket should be at least 1")
+	}
+
+	return n.updateResources(c, vmUUID, ch, "vm CPU updated")
+}
+
+// vmUpdate changes the memory and vCPUs of a VM. Memory accepts sizes such as
+// 8GiB and vCPUs keep the current cores per socket where possible.
+func (n *NCLI) vmUpdate(c *cli.Context) error {
+	if !c.IsSet("memory") && !c.IsSet("vcpus") {
+		return errors.New("nothing to update. use --memory and/or --vcpus")
+	}
+
+	ch := resourceChange{vcpus: c.Int("vcpus")}
+	if c.IsSet("vcpus") && ch.vcpus < 1 {
+		return errors.New("invalid vcpus value...should be at least 1")
+	}
+	if c.IsSet("memory") {
+		var err error
+		ch.memoryMib, err = parseVMMemory(c.String("memory"), "MiB")
 		if err != nil {
 			return err
 		}
+	}
 
-		currentMatches += *getRes.Metadata.Length
-		totalMatches = *getRes.Metadata.TotalMatches
-		offset += ListRequest.Length
-		vmListLoop = append(vmListLoop, getRes.Entities...)
+	if c.IsSet("selector") {
+		if c.Args().Len() > 0 {
+			return errors.New("use either a VM argument or --selector")
+		}
+		return n.bulkUpdateResources(c, ch)
 	}
 
-	n.tr.SetHeader([]string{"Name", "UUID", "Powered", "Cluster"})
+	vmUUID, err := n.resolveUUID(kindVM, c.Args().First())
+	if err != nil {
+		return err
+	}
 
-	n.tr.SetFooter([]string{"Total", "", "", strconv.Itoa(*getRes.Metadata.TotalMatches)})
+	return n.updateResources(c, vmUUID, ch, "vm updated")
+}
 
-	data := [][]string{}
+// parseVMMemory reads a memory size and checks it against the VM limits. The
+// check happens here as a zero memoryMib means unchanged to resourceChange.
+func parseVMMemory(size, defaultUnit string) (int, error) {
+	memMib, err := ParseMibSize(size, defaultUnit)
+	if err != nil {
+		return 0, err
+	}
+	if memMib < vmMinMemoryMib || memMib > vmMaxMemoryMib {
+		return 0, fmt.Errorf("invalid memory value...should be %d to %d MiB", vmMinMemoryMib, vmMaxMemoryMib)
+	}
 
-	for _, entityValue := range vmListLoop {
-		data = append(data, []string{*entityValue.Spec.Name, *entityValue.Metadata.UUID, *entityValue.Spec.Resources.PowerState, entityValue.Spec.ClusterReference.Name})
+	return memMib, nil
+}
+
+// resourceChange is a CPU or memory change. Zero values are left unchanged.
+// vcpus sets the total and is spread over sockets using the current cores
+// per socket when it divides evenly.
+type resourceChange struct {
+	sockets        int
+	coresPerSocket int
+	vcpus          int
+	memoryMib      int
+}
+
+// target returns the resulting sockets, cores per socket and memory when the
+// change is made to the resources
+func (r resourceChange) target(res *pc.Resources) (int, int, int) {
+	sockets, cores, memory := 1, 1, 0
+	if res.NumSockets != nil {
+		sockets = *res.NumSockets
+	}
+	if res.NumVcpusPerSocket != nil {
+		cores = *res.NumVcpusPerSocket
+	}
+	if res.MemorySizeMib != nil {
+		memory = *res.MemorySizeMib
+	}
+
+	if r.vcpus > 0 {
+		if r.vcpus%cores == 0 {
+			sockets = r.vcpus / cores
+		} else {
+			sockets, cores = r.vcpus, 1
+		}
+	}
+	if r.sockets > 0 {
+		sockets = r.sockets
+	}
+	if r.coresPerSocket > 0 {
+		cores = r.coresPerSocket
+	}
+	if r.memoryMib > 0 {
+		memory = r.memoryMib
+	}
+
+	return sockets, cores, memory
+}
+
+// validate checks the resulting topology and memory against the VM limits
+func (r resourceChange) validate(res *pc.Resources) error {
+	sockets, cores, memory := r.target(res)
+
+	if sockets*cores > vmMaxVCPUs {
+		return fmt.Errorf("invalid CPU value...%d sockets of %d cores is more than %d vCPUs", sockets, cores, vmMaxVCPUs)
+	}
+	if r.memoryMib > 0 && (memory < vmMinMemoryMib || memory > vmMaxMemoryMib) {
+		return fmt.Errorf("invalid memory value...should be %d to %d MiB", vmMinMemoryMib, vmMaxMemoryMib)
 	}
-	n.tr.AppendBulk(data)
-	n.tr.Render()
 
 	return nil
 }
 
-func (n *NCLI) vmMemoryUpdate(c *cli.Context) error {
-	if !IsValidUUID(c.Args().First()) {
-		return errors.New("invalid UUID format")
+// hotAddBlockers returns the reasons the change cannot be made while the VM
+// is running. AHV can add sockets and memory to a running VM but cannot
+// remove them or change the cores per socket.
+func (r resourceChange) hotAddBlockers(res *pc.Resources) []string {
+	if stringValue(res.PowerState) != "ON" {
+		return nil
 	}
 
-	if len(c.Args().Get(1)) == 0 {
-		return errors.New("no memory value provided...should be an integer")
+	blockers := []string{}
+	sockets, cores, memory := r.target(res)
+
+	if res.NumSockets != nil && sockets < *res.NumSockets {
+		blockers = append(blockers, fmt.Sprintf("sockets cannot be removed from a running VM (%d to %d)", *res.NumSockets, sockets))
+	}
+	if res.NumVcpusPerSocket != nil && cores != *res.NumVcpusPerSocket {
+		blockers = append(blockers, fmt.Sprintf("cores per socket cannot change on a running VM (%d to %d)", *res.NumVcpusPerSocket, cores))
+	}
+	if res.MemorySizeMib != nil && memory < *res.MemorySizeMib {
+		blockers = append(blockers, fmt.Sprintf("memory cannot be removed from a running VM (%d to %d MiB)", *res.MemorySizeMib, memory))
+	}
+
+	return blockers
+}
+
+// set applies the change to the resources
+func (r resourceChange) set(res *pc.Resources) {
+	sockets, cores, memory := r.target(res)
+
+	res.NumSockets = &sockets
+	res.NumVcpusPerSocket = &cores
+	if memory > 0 {
+		res.MemorySizeMib = &memory
 	}
+}
 
-	memVal, err := strconv.Atoi(c.Args().Get(1))
+// updateResources makes a CPU or memory change. Changes that cannot be hot
+// added to a running VM are made by powering the VM off and on again after
+// confirmation or with --power-cycle.
+func (n *NCLI) updateResources(c *cli.Context, vmUUID string, ch resourceChange, message string) error {
+	vm, err := n.getVMSpec(vmUUID)
 	if err != nil {
 		return err
 	}
-	if memVal < 500 || memVal > 500000 {
-		return errors.New("invalid memory value")
+	if vm.Spec.Resources == nil {
+		vm.Spec.Resources = &pc.Resources{}
 	}
 
-	getRequest := &pc.VMGetRequest{UUID: c.Args().First()}
-	getRes, _, err := n.con.PC.VM.Get(getRequest)
-	if err != nil {
+	if err := ch.validate(vm.Spec.Resources); err != nil {
 		return err
 	}
 
-	updateRequest := &pc.VMUpdateRequest{}
-	updateRequestData := &pc.VMUpdateRequestData{}
-	updateRequestData.Spec = getRes.Spec
-	updateRequestData.APIVersion = &getRes.APIVersion
-	updateRequestData.Metadata = &getRes.Metadata
+	blockers := ch.hotAddBlockers(vm.Spec.Resources)
+	powerCycle := len(blockers) > 0 && !n.dryRun
 
-	updateRequest.UUID = c.Args().First()
-	updateRequest.Data = *updateRequestData
+	if powerCycle {
+		fmt.Fprintln(n.out, "the change cannot be made while the VM is running:")
+		for _, b := range blockers {
+			fmt.Fprintln(n.out, "  -", b)
+		}
 
-	mibValue := GetMibFromMB(memVal)
-	updateRequest.Data.Spec.Resources.MemorySizeMib = &mibValue
+		if !c.Bool("power-cycle") {
+			ok, err := n.confirm(fmt.Sprintf("power off %s, apply the change and power it on?", stringValue(vm.Spec.Name)))
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return errors.New("update cancelled")
+			}
+		}
+	}
 
-	_, _, err = n.con.PC.VM.Update(updateRequest)
+	if powerCycle {
+		taskUUID, err := n.powerCycleUpdate(c, vmUUID, ch)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(n.out, message)
+		fmt.Fprintln(n.out, "virtual machine updated to power state: ", "ON")
+
+		return n.reportTask(c, n.getPCTask, taskUUID)
+	}
+
+	updateRes, err := n.updateVM(vmUUID, func(vm *vmSpec) error {
+		ch.set(vm.Spec.Resources)
+		return nil
+	})
+	if err == errDryRun {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("vm updated to %d memory\n", memVal)
+	fmt.Fprintln(n.out, message)
 
-	return nil
+	return n.reportTask(c, n.getPCTask, executionTask(updateRes.Status))
+}
+
+// powerCycleUpdate powers a VM off, makes the change and powers the VM on
+// again. The task UUID of the power on is returned.
+func (n *NCLI) powerCycleUpdate(c *cli.Context, vmUUID string, ch resourceChange) (string, error) {
+	if err := n.setPowerAndWait(c, vmUUID, "OFF"); err != nil {
+		return "", err
+	}
+
+	updateRes, err := n.updateVM(vmUUID, func(vm *vmSpec) error {
+		ch.set(vm.Spec.Resources)
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("%v. the VM was left powered off", err)
+	}
+
+	if _, err := waitForTask(n.getPCTask, executionTask(updateRes.Status), c.Duration("timeout")); err != nil {
+		return "", fmt.Errorf("%v. the VM was left powered off", err)
+	}
+
+	powerRes, err := n.updateVM(vmUUID, func(vm *vmSpec) error {
+		vm.Spec.Resources.PowerState = nutanix.String("ON")
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to power the VM back on: %v", err)
+	}
+
+	return executionTask(powerRes.Status), nil
+}
+
+// setPowerAndWait sets the power state of a VM through Prism Central and
+// waits for the task to finish
+func (n *NCLI) setPowerAndWait(c *cli.Context, vmUUID, powerState string) error {
+	updateRes, err := n.updateVM(vmUUID, func(vm *vmSpec) error {
+		vm.Spec.Resources.PowerState = nutanix.String(powerState)
+		return nil
+	})
+	if err == errDryRun {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = waitForTask(n.getPCTask, executionTask(updateRes.Status), c.Duration("timeout"))
+	return err
 }
 
 // vmVDiskGet returns the VDISK list for an identified VM
 func (n *NCLI) vmVDiskGet(c *cli.Context) error {
-	if !IsValidUUID(c.Args().First()) {
-		return errors.New("invalid UUID format")
+	vmUUID, err := n.resolveUUID(kindVM, c.Args().First())
+	if err != nil {
+		return err
 	}
 
 	getRequest := &pe.VMGetRequest{
 		Params: &pe.VMGetRequestParams{
-			UUID: c.Args().First(),
+			UUID: vmUUID,
 		},
 		Query: &pe.VMGetRequestQuery{
 			IncludeVMDiskConfig: true,
@@ -203,23 +476,23 @@ func (n *NCLI) vmVDiskGet(c *cli.Context) error {
 		}
 	}
 
-	n.tr.SetHeader([]string{"", fmt.Sprintf("Disk UUID - %s", name), "NFS LOCATION", "Size", "Disk Type"})
-
 	data = append(data, []string{"", "", "", "TOTAL", strconv.Itoa(vdiskCount)})
 
-	n.tr.SetAutoMergeCells(true)
-	n.tr.SetRowLine(true)
-	n.tr.AppendBulk(data)
-	n.tr.Render()
-	return nil
+	return n.render(c, &Result{
+		Header:     []string{"", fmt.Sprintf("Disk UUID - %s", name), "NFS LOCATION", "Size", "Disk Type"},
+		Rows:       data,
+		Entity:     getRes,
+		MergeCells: true,
+	})
 }
 
 func (n *NCLI) vmGet(c *cli.Context) error {
-	if !IsValidUUID(c.Args().First()) {
-		return errors.New("invalid UUID format")
+	vmUUID, err := n.resolveUUID(kindVM, c.Args().First())
+	if err != nil {
+		return err
 	}
 
-	getRequest := &pc.VMGetRequest{UUID: c.Args().First()}
+	getRequest := &pc.VMGetRequest{UUID: vmUUID}
 	getRes, _, err := n.con.PC.VM.Get(getRequest)
 	if err != nil {
 		return err
@@ -251,9 +524,6 @@ func (n *NCLI) vmGet(c *cli.Context) error {
 		data = append(data, []string{name, diskItem.UUID, diskSizeStr, diskItem.DeviceProperties.DeviceType})
 	}
 
-	n.tr.SetHeader([]string{"VM", "Disk UUID", "Size", "Disk Type"})
-
-	// n.tr.SetFooter([]string{"", "", "Total", strconv.Itoa(len(*getRes.Status.Resources.DiskList))})
 	data = append(data, []string{name, "", "TOTAL", strconv.Itoa(len(*getRes.Status.Resources.DiskList))})
 
 	data = append(data, []string{name, "", "POWER STATE", *getRes.Spec.Resources.PowerState})
@@ -267,57 +537,86 @@ func (n *NCLI) vmGet(c *cli.Context) error {
 		data = append(data, []string{name, networkItem.UUID, networkIP, networkItem.SubnetReference.Name})
 	}
 
-	n.tr.SetAutoMergeCells(true)
-	n.tr.SetRowLine(true)
-	n.tr.AppendBulk(data)
-	n.tr.Render()
-	return nil
+	return n.render(c, &Result{
+		Header:     []string{"VM", "Disk UUID", "Size", "Disk Type"},
+		Rows:       data,
+		Entity:     getRes,
+		MergeCells: true,
+	})
 }
 
 func (n *NCLI) vmSetPowerState(c *cli.Context) error {
-	if !IsValidUUID(c.Args().First()) {
-		return errors.New("invalid UUID format")
+	if c.IsSet("selector") {
+		if c.Args().Len() > 1 {
+			return errors.New("use either a VM argument or --selector. <power state> only with --selector")
+		}
+		powerState := strings.ToUpper(c.Args().First())
+		if !stringSliceContains(powerTransitions, powerState) {
+			return errors.New("invalid power state. <" + strings.Join(powerTransitions, ", ") + ">")
+		}
+		return n.bulkSetPower(c, powerState)
+	}
+
+	vmUUID, err := n.resolveUUID(kindVM, c.Args().First())
+	if err != nil {
+		return err
 	}
 	powerState := strings.ToUpper(c.Args().Get(1))
-	// powerOptions := []string{"ON", "OFF", "POWERCYCLE", "RESET", "PAUSE", "SUSPEND", "RESUME", "ACPI_SHUTDOWN", "ACPI_REBOOT"}
-	powerOptions := []string{"ON", "OFF"}
-	if !stringSliceContains(powerOptions, powerState) {
-		return errors.New("invalid memory state. <ON, OFF>")
+	if !stringSliceContains(powerTransitions, powerState) {
+		return errors.New("invalid power state. <" + strings.Join(powerTransitions, ", ") + ">")
 	}
 
-	getRequest := &pc.VMGetRequest{UUID: c.Args().First()}
-	getRes, _, err := n.con.PC.VM.Get(getRequest)
+	taskUUID, getTask, err := n.setPower(vmUUID, powerState)
+	if err == errDryRun {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
 
-	updateRequest := &pc.VMUpdateRequest{}
-	updateRequestData := &pc.VMUpdateRequestData{}
-	updateRequestData.Spec = getRes.Spec
-	updateRequestData.APIVersion = &getRes.APIVersion
-	updateRequestData.Metadata = &getRes.Metadata
+	fmt.Fprintln(n.out, "virtual machine updated to power state: ", powerState)
 
-	updateRequest.UUID = c.Args().First()
-	updateRequest.Data = *updateRequestData
+	return n.reportTask(c, getTask, taskUUID)
+}
 
-	updateRequest.Data.Spec.Resources.PowerState = nutanix.String(powerState)
+// setPower requests a power state change and returns the task UUID with the
+// API the task is followed through
+func (n *NCLI) setPower(vmUUID, powerState string) (string, taskGetFunc, error) {
+	if powerState == "ON" || powerState == "OFF" {
+		updateRes, err := n.updateVM(vmUUID, func(vm *vmSpec) error {
+			vm.Spec.Resources.PowerState = nutanix.String(powerState)
+			return nil
+		})
+		if err != nil {
+			return "", nil, err
+		}
+		return executionTask(updateRes.Status), n.getPCTask, nil
+	}
 
-	_, _, err = n.con.PC.VM.Update(updateRequest)
+	// v3 only expresses ON and OFF so the remaining transitions use the v2 API
+	taskUUID, err := n.vmSetPowerTransition(vmUUID, powerState)
 	if err != nil {
-		return err
+		return "", nil, err
 	}
+	return taskUUID, n.getPETask, nil
+}
 
-	fmt.Println("virtual machine updated to power state: ", powerState)
+// powerTransitions are the power state changes accepted by update-power
+var powerTransitions = []string{"ON", "OFF", "POWERCYCLE", "RESET", "PAUSE", "SUSPEND", "RESUME", "ACPI_SHUTDOWN", "ACPI_REBOOT"}
 
-	return nil
+// vmSetPowerTransition requests a power state transition through the Prism
+// Element v2 API and returns the resulting task UUID
+func (n *NCLI) vmSetPowerTransition(vmUUID, transition string) (string, error) {
+	return n.peTaskRequest("POST", "vms/"+vmUUID+"/set_power_state", map[string]string{"transition": transition})
 }
 
 func (n *NCLI) vmDiskList(c *cli.Context) error {
-	if !IsValidUUID(c.Args().First()) {
-		return errors.New("invalid UUID format")
+	vmUUID, err := n.resolveUUID(kindVM, c.Args().First())
+	if err != nil {
+		return err
 	}
 
-	getRequest := &pc.VMGetRequest{UUID: c.Args().First()}
+	getRequest := &pc.VMGetRequest{UUID: vmUUID}
 	getRes, _, err := n.con.PC.VM.Get(getRequest)
 	if err != nil {
 		return err
@@ -331,11 +630,68 @@ func (n *NCLI) vmDiskList(c *cli.Context) error {
 		data = append(data, []string{diskItem.UUID, strconv.Itoa(diskItem.DiskSizeBytes), diskItem.DeviceProperties.DiskAddress.AdapterType})
 	}
 
-	n.tr.SetHeader([]string{"Disk UUID", "Size (Bytes)", "Disk Type"})
+	return n.render(c, &Result{
+		Header: []string{"Disk UUID", "Size (Bytes)", "Disk Type"},
+		Footer: []string{name, "Total", strconv.Itoa(len(*getRes.Status.Resources.DiskList))},
+		Rows:   data,
+		Entity: *getRes.Status.Resources.DiskList,
+	})
+}
 
-	n.tr.SetFooter([]string{name, "Total", strconv.Itoa(len(*getRes.Status.Resources.DiskList))})
+// listAllVMs pages through the VM list API and returns every matching VM.
+// A limit greater than zero stops paging once that many VMs are retrieved.
+func (n *NCLI) listAllVMs(ListRequest *pc.VMListRequest, limit int) ([]pc.Entities, error) {
+	return fetchPCEntities(limit, func(offset, length int) ([]pc.Entities, int, error) {
+		pageRequest := *ListRequest
+		pageRequest.Offset = offset
+		pageRequest.Length = length
 
-	n.tr.AppendBulk(data)
-	n.tr.Render()
-	return nil
+		getRes, _, err := n.con.PC.VM.List(&pageRequest)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return getRes.Entities, *getRes.Metadata.TotalMatches, nil
+	})
+}
+
+// buildVMFilter combines a raw FIQL filter with the convenience flags into the
+// filter string used by the v3 VM list API. Criteria are joined with ";" (AND).
+// FIQL has no grouping and ";" binds tighter than ",", so a raw filter with an
+// OR cannot be combined with the flags.
+func buildVMFilter(filter, cluster, powerState, nameRegex string) (string, error) {
+	criteria := []string{}
+
+	if len(filter) > 0 {
+		if strings.Contains(filter, ",") && len(cluster)+len(powerState)+len(nameRegex) > 0 {
+			return "", errors.New("a --filter with , (OR) cannot be combined with --cluster, --power-state or --name-regex. add the conditions to each OR clause of the filter instead")
+		}
+		criteria = append(criteria, filter)
+	}
+
+	if len(cluster) > 0 {
+		criteria = append(criteria, "cluster_name=="+fiqlEscape(cluster))
+	}
+
+	if len(powerState) > 0 {
+		state := strings.ToLower(powerState)
+		if state != "on" && state != "off" {
+			return "", errors.New("invalid power state filter. <ON, OFF>")
+		}
+		criteria = append(criteria, "power_state=="+state)
+	}
+
+	if len(nameRegex) > 0 {
+		if _, err := regexp.Compile(nameRegex); err != nil {
+			return "", fmt.Errorf("invalid name regex %q: %v", nameRegex, err)
+		}
+		criteria = append(criteria, "vm_name=="+fiqlEscape(nameRegex))
+	}
+
+	return strings.Join(criteria, ";"), nil
+}
+
+// fiqlEscape percent encodes the FIQL operators that may appear in a value
+func fiqlEscape(value string) string {
+	return strings.NewReplacer(";", "%3B", ",", "%2C").Replace(value)
 }
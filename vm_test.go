@@ -0,0 +1,151 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	nutanix "github.com/routebyintuition/ntnx-go-sdk"
+	"github.com/routebyintuition/ntnx-go-sdk/pc"
+)
+
+func Test_buildVMFilter(t *testing.T) {
+	type args struct {
+		filter     string
+		cluster    string
+		powerState string
+		nameRegex  string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "empty",
+			args: args{},
+			want: "",
+		},
+		{
+			name: "raw filter only",
+			args: args{filter: "power_state==on;cluster_name==prod"},
+			want: "power_state==on;cluster_name==prod",
+		},
+		{
+			name: "convenience flags",
+			args: args{cluster: "prod", powerState: "ON", nameRegex: "web-.*"},
+			want: "cluster_name==prod;power_state==on;vm_name==web-.*",
+		},
+		{
+			name: "raw filter combined with flags",
+			args: args{filter: "num_vcpus==4", cluster: "dev"},
+			want: "num_vcpus==4;cluster_name==dev",
+		},
+		{
+			name:    "raw OR filter combined with flags",
+			args:    args{filter: "power_state==on,power_state==off", cluster: "prod"},
+			wantErr: true,
+		},
+		{
+			name: "raw OR filter only",
+			args: args{filter: "power_state==on,power_state==off"},
+			want: "power_state==on,power_state==off",
+		},
+		{
+			name: "escaped regex",
+			args: args{nameRegex: "web-[0-9]{1,2}"},
+			want: "vm_name==web-[0-9]{1%2C2}",
+		},
+		{
+			name:    "invalid power state",
+			args:    args{powerState: "paused"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid regex",
+			args:    args{nameRegex: "web-("},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildVMFilter(tt.args.filter, tt.args.cluster, tt.args.powerState, tt.args.nameRegex)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("buildVMFilter() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("buildVMFilter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseVMMemory(t *testing.T) {
+	tests := []struct {
+		size    string
+		unit    string
+		want    int
+		wantErr bool
+	}{
+		{size: "8GiB", unit: "MiB", want: 8192},
+		{size: "8000", unit: "MB", want: 7629},
+		{size: "0", unit: "MiB", wantErr: true},
+		{size: "0.5MB", unit: "MiB", wantErr: true},
+		{size: "100MiB", unit: "MiB", wantErr: true},
+		{size: "8PB", unit: "MiB", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.size, func(t *testing.T) {
+			got, err := parseVMMemory(tt.size, tt.unit)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseVMMemory() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseVMMemory() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_resourceChange(t *testing.T) {
+	running := func() *pc.Resources {
+		return &pc.Resources{NumSockets: intPtr(2), NumVcpusPerSocket: intPtr(2), MemorySizeMib: intPtr(4096), PowerState: nutanix.String("ON")}
+	}
+
+	tests := []struct {
+		name     string
+		change   resourceChange
+		res      *pc.Resources
+		want     []int
+		blockers int
+	}{
+		{name: "add memory", change: resourceChange{memoryMib: 8192}, res: running(), want: []int{2, 2, 8192}},
+		{name: "remove memory", change: resourceChange{memoryMib: 2048}, res: running(), want: []int{2, 2, 2048}, blockers: 1},
+		{name: "vcpus keep cores per socket", change: resourceChange{vcpus: 8}, res: running(), want: []int{4, 2, 4096}},
+		{name: "vcpus that do not divide", change: resourceChange{vcpus: 3}, res: running(), want: []int{3, 1, 4096}, blockers: 1},
+		{name: "add sockets", change: resourceChange{sockets: 4}, res: running(), want: []int{4, 2, 4096}},
+		{name: "remove sockets and change cores", change: resourceChange{sockets: 1, coresPerSocket: 4}, res: running(), want: []int{1, 4, 4096}, blockers: 2},
+		{
+			name:   "powered off",
+			change: resourceChange{sockets: 1, coresPerSocket: 4, memoryMib: 1024},
+			res: func() *pc.Resources {
+				res := running()
+				res.PowerState = nutanix.String("OFF")
+				return res
+			}(),
+			want: []int{1, 4, 1024},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sockets, cores, memory := tt.change.target(tt.res)
+			if got := []int{sockets, cores, memory}; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("target() = %v, want %v", got, tt.want)
+			}
+			if got := tt.change.hotAddBlockers(tt.res); len(got) != tt.blockers {
+				t.Errorf("hotAddBlockers() = %q, want %d blockers", got, tt.blockers)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/routebyintuition/ntnx-go-sdk/pc"
+	"github.com/urfave/cli/v2"
+)
+
+// isCDROM reports whether a VM disk is a CD-ROM device
+func isCDROM(disk pc.DiskList) bool {
+	return disk.DeviceProperties != nil && disk.DeviceProperties.DeviceType == "CDROM"
+}
+
+// findCDROM returns the position of the CD-ROM device identified by device,
+// a bus and index such as IDE.0 or a bare index on the IDE bus
+func findCDROM(disks []pc.DiskList, device string) (int, error) {
+	i, err := findDisk(disks, device, "IDE")
+	if err != nil {
+		return 0, err
+	}
+	if !isCDROM(disks[i]) {
+		return 0, fmt.Errorf("%s is not a CD-ROM", device)
+	}
+	return i, nil
+}
+
+// mountCDROM points a CD-ROM at an ISO image and returns the address of the
+// device. Without a device the first empty CD-ROM is used, then the first
+// CD-ROM, and a new IDE CD-ROM is added when the VM has none. A CD-ROM can
+// only be added while the VM is off.
+func mountCDROM(disks *[]pc.DiskList, device, imageUUID, powerState string) (string, error) {
+	i := -1
+
+	if len(device) > 0 {
+		found, err := findCDROM(*disks, device)
+		if err != nil {
+			return "", err
+		}
+		i = found
+	} else {
+		for j, disk := range *disks {
+			if !isCDROM(disk) {
+				continue
+			}
+			if disk.DataSourceReference == nil {
+				i = j
+				break
+			}
+			if i < 0 {
+				i = j
+			}
+		}
+	}
+
+	if i < 0 {
+		if powerState == "ON" {
+			return "", errors.New("the VM has no CD-ROM and one can only be added while it is powered off")
+		}
+		address, err := diskAddress(*disks, "IDE", -1)
+		if err != nil {
+			return "", err
+		}
+		*disks = append(*disks, pc.DiskList{DeviceProperties: &pc.DeviceProperties{DeviceType: "CDROM", DiskAddress: address}})
+		i = len(*disks) - 1
+	}
+
+	disk := &(*disks)[i]
+	disk.DataSourceReference = &pc.DataSourceReference{Kind: "image", UUID: imageUUID}
+	disk.DiskSizeMib = 0
+	disk.DiskSizeBytes = 0
+
+	return diskAddressKey(disk.DeviceProperties.DiskAddress), nil
+}
+
+// ejectCDROM removes the media from a CD-ROM and returns the address of the
+// device. Without a device the single CD-ROM with media is used.
+func ejectCDROM(disks []pc.DiskList, device string) (string, error) {
+	i := -1
+
+	if len(device) > 0 {
+		found, err := findCDROM(disks, device)
+		if err != nil {
+			return "", err
+		}
+		if disks[found].DataSourceReference == nil {
+			return "", fmt.Errorf("CD-ROM %s is already empty", device)
+		}
+		i = found
+	} else {
+		for j, disk := range disks {
+			if !isCDROM(disk) || disk.DataSourceReference == nil {
+				continue
+			}
+			if i >= 0 {
+				return "", errors.New("several CD-ROMs have media mounted. pick one with --device <bus.index>")
+			}
+			i = j
+		}
+		if i < 0 {
+			return "", errors.New("no CD-ROM has media mounted")
+		}
+	}
+
+	disks[i].DataSourceReference = nil
+	disks[i].DiskSizeMib = 0
+	disks[i].DiskSizeBytes = 0
+
+	return diskAddressKey(disks[i].DeviceProperties.DiskAddress), nil
+}
+
+// isoImage resolves an image and checks that it is an ISO
+func (n *NCLI) isoImage(name string) (string, error) {
+	imageUUID, err := n.resolveUUID(kindImage, name)
+	if err != nil {
+		return "", err
+	}
+
+	images, err := n.listAllImages()
+	if err != nil {
+		return "", err
+	}
+
+	for _, image := range images {
+		if stringValue(image.Metadata.UUID) != imageUUID {
+			continue
+		}
+		imageType := ""
+		if image.Spec.Resources != nil {
+			imageType = stringValue(image.Spec.Resources.ImageType)
+		}
+		if imageType != "ISO_IMAGE" {
+			return "", fmt.Errorf("image %s is a %s, not an ISO_IMAGE", name, imageType)
+		}
+		return imageUUID, nil
+	}
+
+	return "", fmt.Errorf("image %s not found", name)
+}
+
+// vmCDROMMount mounts an ISO image on a VM CD-ROM
+func (n *NCLI) vmCDROMMount(c *cli.Context) error {
+	if len(c.String("image")) == 0 {
+		return errors.New("no image provided. use --image <ISO image name|UUID>")
+	}
+
+	vmUUID, err := n.resolveUUID(kindVM, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	imageUUID, err := n.isoImage(c.String("image"))
+	if err != nil {
+		return err
+	}
+
+	return n.updateVMAndReport(c, vmUUID, func(vm *vmSpec) (string, error) {
+		device, err := mountCDROM(vmDisks(vm.Spec.Resources), c.String("device"), imageUUID, stringValue(vm.Spec.Resources.PowerState))
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("image %s mounted on CD-ROM %s of %s", c.String("image"), device, stringValue(vm.Spec.Name)), nil
+	})
+}
+
+// vmCDROMEject removes the media from a VM CD-ROM
+func (n *NCLI) vmCDROMEject(c *cli.Context) error {
+	vmUUID, err := n.resolveUUID(kindVM, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	return n.updateVMAndReport(c, vmUUID, func(vm *vmSpec) (string, error) {
+		device, err := ejectCDROM(*vmDisks(vm.Spec.Resources), c.String("device"))
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("CD-ROM %s of %s ejected", device, stringValue(vm.Spec.Name)), nil
+	})
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/routebyintuition/ntnx-go-sdk/pc"
+)
+
+func testCDROMDisks(mounted ...bool) []pc.DiskList {
+	disks := []pc.DiskList{
+		{UUID: "disk-0", DiskSizeMib: 10240, DeviceProperties: &pc.DeviceProperties{DeviceType: "DISK", DiskAddress: &pc.DiskAddress{AdapterType: "SCSI", DeviceIndex: intPtr(0)}}},
+	}
+	for i, m := range mounted {
+		cdrom := pc.DiskList{DeviceProperties: &pc.DeviceProperties{DeviceType: "CDROM", DiskAddress: &pc.DiskAddress{AdapterType: "IDE", DeviceIndex: intPtr(i)}}}
+		if m {
+			cdrom.DataSourceReference = &pc.DataSourceReference{Kind: "image", UUID: "old-iso"}
+			cdrom.DiskSizeMib = 700
+		}
+		disks = append(disks, cdrom)
+	}
+	return disks
+}
+
+func Test_mountCDROM(t *testing.T) {
+	tests := []struct {
+		name       string
+		disks      []pc.DiskList
+		device     string
+		powerState string
+		want       string
+		wantCount  int
+		wantErr    string
+	}{
+		{name: "first empty", disks: testCDROMDisks(true, false), want: "IDE.1", wantCount: 3},
+		{name: "replace media", disks: testCDROMDisks(true), want: "IDE.0", wantCount: 2},
+		{name: "device", disks: testCDROMDisks(false, false), device: "IDE.1", want: "IDE.1", wantCount: 3},
+		{name: "not a cdrom", disks: testCDROMDisks(false), device: "SCSI.0", wantErr: "SCSI.0 is not a CD-ROM"},
+		{name: "add when off", disks: testCDROMDisks(), powerState: "OFF", want: "IDE.0", wantCount: 2},
+		{name: "add when on", disks: testCDROMDisks(), powerState: "ON", wantErr: "only be added while it is powered off"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			disks := tt.disks
+			got, err := mountCDROM(&disks, tt.device, "new-iso", tt.powerState)
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("mountCDROM() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("mountCDROM() error = %v", err)
+			}
+			if got != tt.want || len(disks) != tt.wantCount {
+				t.Fatalf("mountCDROM() = %v with %d disks, want %v with %d", got, len(disks), tt.want, tt.wantCount)
+			}
+
+			i, _ := findCDROM(disks, got)
+			if disks[i].DataSourceReference == nil || disks[i].DataSourceReference.UUID != "new-iso" || disks[i].DiskSizeMib != 0 {
+				t.Errorf("mountCDROM() left %+v", disks[i])
+			}
+		})
+	}
+}
+
+func Test_ejectCDROM(t *testing.T) {
+	tests := []struct {
+		name    string
+		disks   []pc.DiskList
+		device  string
+		want    string
+		wantErr string
+	}{
+		{name: "single mounted", disks: testCDROMDisks(false, true), want: "IDE.1"},
+		{name: "device", disks: testCDROMDisks(true, true), device: "1", want: "IDE.1"},
+		{name: "several mounted", disks: testCDROMDisks(true, true), wantErr: "pick one with --device"},
+		{name: "nothing mounted", disks: testCDROMDisks(false), wantErr: "no CD-ROM has media mounted"},
+		{name: "device empty", disks: testCDROMDisks(false), device: "IDE.0", wantErr: "already empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ejectCDROM(tt.disks, tt.device)
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ejectCDROM() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ejectCDROM() error = %v", err)
+			}
+			i, _ := findCDROM(tt.disks, got)
+			if got != tt.want || tt.disks[i].DataSourceReference != nil {
+				t.Errorf("ejectCDROM() = %v, left %+v", got, tt.disks[i])
+			}
+		})
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/routebyintuition/ntnx-go-sdk/pc"
+	"github.com/urfave/cli/v2"
+)
+
+// diskBuses are the adapter types accepted for VM disks
+var diskBuses = []string{"SCSI", "IDE", "PCI", "SATA", "SPAPR_VSCSI"}
+
+// diskBus validates and normalizes an adapter type
+func diskBus(bus string) (string, error) {
+	bus = strings.ToUpper(bus)
+	if !stringSliceContains(diskBuses, bus) {
+		return "", errors.New("invalid bus. <" + strings.Join(diskBuses, ", ") + ">")
+	}
+	return bus, nil
+}
+
+// vmDisks returns the disk list of a VM, creating it when it is missing
+func vmDisks(res *pc.Resources) *[]pc.DiskList {
+	if res.DiskList == nil {
+		res.DiskList = &[]pc.DiskList{}
+	}
+	return res.DiskList
+}
+
+// findDisk returns the position of a disk identified by its UUID, a bus and
+// device index such as SCSI.1, or a device index on the default bus
+func findDisk(disks []pc.DiskList, ref, defaultBus string) (int, error) {
+	for i, d := range disks {
+		if len(d.UUID) > 0 && d.UUID == ref {
+			return i, nil
+		}
+	}
+
+	key := strings.ToUpper(ref)
+	if _, err := strconv.Atoi(ref); err == nil {
+		key = strings.ToUpper(defaultBus) + "." + ref
+	}
+
+	for i, d := range disks {
+		if d.DeviceProperties != nil && d.DeviceProperties.DiskAddress != nil && diskAddressKey(d.DeviceProperties.DiskAddress) == key {
+			return i, nil
+		}
+	}
+
+	return 0, fmt.Errorf("disk %s not found on the VM", ref)
+}
+
+// diskAddress returns the address for a new disk on the bus. A negative index
+// picks the next free device index and a used index is an error.
+func diskAddress(disks []pc.DiskList, bus string, index int) (*pc.DiskAddress, error) {
+	used := map[int]string{}
+	next := 0
+
+	for _, d := range disks {
+		if d.DeviceProperties == nil || d.DeviceProperties.DiskAddress == nil || d.DeviceProperties.DiskAddress.DeviceIndex == nil {
+			continue
+		}
+		da := d.DeviceProperties.DiskAddress
+		if !strings.EqualFold(da.AdapterType, bus) {
+			continue
+		}
+		used[*da.DeviceIndex] = d.UUID
+		if *da.DeviceIndex >= next {
+			next = *da.DeviceIndex + 1
+		}
+	}
+
+	if index < 0 {
+		index = next
+	}
+	if owner, ok := used[index]; ok {
+		if len(owner) == 0 {
+			owner = "a new disk"
+		}
+		return nil, fmt.Errorf("device index %s.%d is already used by %s", bus, index, owner)
+	}
+
+	return &pc.DiskAddress{AdapterType: bus, DeviceIndex: &index}, nil
+}
+
+// resizeDisk grows a disk to sizeMib and refuses to shrink it
+func resizeDisk(disk *pc.DiskList, sizeMib int) error {
+	if isCDROM(*disk) {
+		return errors.New("a CD-ROM cannot be resized")
+	}
+
+	current := disk.DiskSizeMib
+	if current == 0 && disk.DiskSizeBytes > 0 {
+		current = disk.DiskSizeBytes / (1 << 20)
+	}
+
+	if sizeMib < current {
+		return fmt.Errorf("refusing to shrink disk from %d to %d MiB", current, sizeMib)
+	}
+	if sizeMib == current {
+		return fmt.Errorf("disk is already %d MiB", current)
+	}
+
+	disk.DiskSizeMib = sizeMib
+	disk.DiskSizeBytes = 0
+	return nil
+}
+
+// isBootDisk reports whether the disk is the boot device of the VM
+func isBootDisk(res *pc.Resources, disk pc.DiskList) bool {
+	if res.BootConfig == nil || res.BootConfig.BootDevice == nil || res.BootConfig.BootDevice.DiskAddress == nil {
+		return false
+	}
+	if disk.DeviceProperties == nil || disk.DeviceProperties.DiskAddress == nil {
+		return false
+	}
+	return diskAddressKey(res.BootConfig.BootDevice.DiskAddress) == diskAddressKey(disk.DeviceProperties.DiskAddress)
+}
+
+// diskIndex returns the --index flag or -1 when it is not set
+func diskIndex(c *cli.Context) (int, error) {
+	if !c.IsSet("index") {
+		return -1, nil
+	}
+	if c.Int("index") < 0 {
+		return 0, errors.New("invalid index value...should be 0 or more")
+	}
+	return c.Int("index"), nil
+}
+
+// vmDiskAdd adds an empty disk to a VM
+func (n *NCLI) vmDiskAdd(c *cli.Context) error {
+	if len(c.String("size")) == 0 {
+		return errors.New("no disk size provided. use --size <size such as 100GiB>")
+	}
+	sizeMib, err := ParseMibSize(c.String("size"), "MiB")
+	if err != nil {
+		return err
+	}
+	if sizeMib < 1 {
+		return errors.New("invalid disk size...should be at least 1 MiB")
+	}
+
+	bus, err := diskBus(c.String("bus"))
+	if err != nil {
+		return err
+	}
+	index, err := diskIndex(c)
+	if err != nil {
+		return err
+	}
+
+	var storage *pc.StorageConfig
+	if len(c.String("container")) > 0 {
+		containerUUID, err := n.resolveUUID(kindContainer, c.String("container"))
+		if err != nil {
+			return err
+		}
+		storage = &pc.StorageConfig{StorageContainerReference: &pc.StorageContainerReference{Kind: "storage_container", UUID: containerUUID}}
+	}
+
+	vmUUID, err := n.resolveUUID(kindVM, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	return n.updateVMAndReport(c, vmUUID, func(vm *vmSpec) (string, error) {
+		disks := vmDisks(vm.Spec.Resources)
+
+		address, err := diskAddress(*disks, bus, index)
+		if err != nil {
+			return "", err
+		}
+
+		*disks = append(*disks, pc.DiskList{
+			DiskSizeMib:      sizeMib,
+			DeviceProperties: &pc.DeviceProperties{DeviceType: "DISK", DiskAddress: address},
+			StorageConfig:    storage,
+		})
+
+		return fmt.Sprintf("disk %s of %d MiB added to %s", diskAddressKey(address), sizeMib, stringValue(vm.Spec.Name)), nil
+	})
+}
+
+// vmDiskAttachImage adds a disk cloned from an image to a VM
+func (n *NCLI) vmDiskAttachImage(c *cli.Context) error {
+	if len(c.String("image")) == 0 {
+		return errors.New("no image provided. use --image <name|UUID>")
+	}
+
+	imageUUID, err := n.resolveUUID(kindImage, c.String("image"))
+	if err != nil {
+		return err
+	}
+
+	bus, err := diskBus(c.String("bus"))
+	if err != nil {
+		return err
+	}
+	index, err := diskIndex(c)
+	if err != nil {
+		return err
+	}
+
+	sizeMib := 0
+	if len(c.String("size")) > 0 {
+		if sizeMib, err = ParseMibSize(c.String("size"), "MiB"); err != nil {
+			return err
+		}
+	}
+
+	vmUUID, err := n.resolveUUID(kindVM, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	return n.updateVMAndReport(c, vmUUID, func(vm *vmSpec) (string, error) {
+		disks := vmDisks(vm.Spec.Resources)
+
+		address, err := diskAddress(*disks, bus, index)
+		if err != nil {
+			return "", err
+		}
+
+		*disks = append(*disks, pc.DiskList{
+			DiskSizeMib:         sizeMib,
+			DataSourceReference: &pc.DataSourceReference{Kind: "image", UUID: imageUUID},
+			DeviceProperties:    &pc.DeviceProperties{DeviceType: "DISK", DiskAddress: address},
+		})
+
+		return fmt.Sprintf("disk %s from image %s added to %s", diskAddressKey(address), c.String("image"), stringValue(vm.Spec.Name)), nil
+	})
+}
+
+// vmDiskResize grows a VM disk
+func (n *NCLI) vmDiskResize(c *cli.Context) error {
+	ref := c.Args().Get(1)
+	if len(ref) == 0 {
+		return errors.New("no disk provided. <VM name|UUID> <disk UUID|bus.index|index>")
+	}
+	if len(c.String("size")) == 0 {
+		return errors.New("no disk size provided. use --size <size such as 200GiB>")
+	}
+	sizeMib, err := ParseMibSize(c.String("size"), "MiB")
+	if err != nil {
+		return err
+	}
+
+	vmUUID, err := n.resolveUUID(kindVM, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	return n.updateVMAndReport(c, vmUUID, func(vm *vmSpec) (string, error) {
+		disks := *vmDisks(vm.Spec.Resources)
+
+		i, err := findDisk(disks, ref, c.String("bus"))
+		if err != nil {
+			return "", err
+		}
+		if err := resizeDisk(&disks[i], sizeMib); err != nil {
+			return "", fmt.Errorf("disk %s: %v", ref, err)
+		}
+
+		return fmt.Sprintf("disk %s resized to %d MiB", ref, sizeMib), nil
+	})
+}
+
+// vmDiskDetach removes a disk from a VM after confirmation. The boot disk
+// cannot be removed.
+func (n *NCLI) vmDiskDetach(c *cli.Context) error {
+	ref := c.Args().Get(1)
+	if len(ref) == 0 {
+		return errors.New("no disk provided. <VM name|UUID> <disk UUID|bus.index|index>")
+	}
+
+	vmUUID, err := n.resolveUUID(kindVM, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	return n.updateVMAndReport(c, vmUUID, func(vm *vmSpec) (string, error) {
+		disks := *vmDisks(vm.Spec.Resources)
+
+		i, err := findDisk(disks, ref, c.String("bus"))
+		if err != nil {
+			return "", err
+		}
+		if isBootDisk(vm.Spec.Resources, disks[i]) {
+			return "", fmt.Errorf("disk %s is the boot device and cannot be detached", ref)
+		}
+
+		// confirm once the VM and disk are known so the prompt names them
+		if !c.Bool("force") && !n.dryRun {
+			address := ref
+			if disks[i].DeviceProperties != nil && disks[i].DeviceProperties.DiskAddress != nil {
+				address = diskAddressKey(disks[i].DeviceProperties.DiskAddress)
+			}
+			ok, err := n.confirm(fmt.Sprintf("detach disk %s from %s? the disk data is deleted", address, stringValue(vm.Spec.Name)))
+			if err != nil {
+				return "", err
+			}
+			if !ok {
+				return "", errors.New("detach cancelled")
+			}
+		}
+
+		remaining := append(append([]pc.DiskList{}, disks[:i]...), disks[i+1:]...)
+		vm.Spec.Resources.DiskList = &remaining
+
+		return fmt.Sprintf("disk %s detached from %s", ref, stringValue(vm.Spec.Name)), nil
+	})
+}
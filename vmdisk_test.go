@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/routebyintuition/ntnx-go-sdk/pc"
+)
+
+func testDisks() []pc.DiskList {
+	return []pc.DiskList{
+		{UUID: "disk-0", DiskSizeMib: 10240, DeviceProperties: &pc.DeviceProperties{DeviceType: "DISK", DiskAddress: &pc.DiskAddress{AdapterType: "SCSI", DeviceIndex: intPtr(0)}}},
+		{UUID: "disk-2", DiskSizeMib: 20480, DeviceProperties: &pc.DeviceProperties{DeviceType: "DISK", DiskAddress: &pc.DiskAddress{AdapterType: "SCSI", DeviceIndex: intPtr(2)}}},
+		{UUID: "cdrom-0", DeviceProperties: &pc.DeviceProperties{DeviceType: "CDROM", DiskAddress: &pc.DiskAddress{AdapterType: "IDE", DeviceIndex: intPtr(0)}}},
+	}
+}
+
+func Test_findDisk(t *testing.T) {
+	tests := []struct {
+		name    string
+		ref     string
+		bus     string
+		want    int
+		wantErr bool
+	}{
+		{name: "uuid", ref: "disk-2", bus: "SCSI", want: 1},
+		{name: "bus and index", ref: "ide.0", bus: "SCSI", want: 2},
+		{name: "bare index", ref: "2", bus: "SCSI", want: 1},
+		{name: "bare index on bus", ref: "0", bus: "IDE", want: 2},
+		{name: "missing", ref: "SCSI.1", bus: "SCSI", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findDisk(testDisks(), tt.ref, tt.bus)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("findDisk() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("findDisk() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_diskAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		bus     string
+		index   int
+		want    string
+		wantErr string
+	}{
+		{name: "next free index", bus: "SCSI", index: -1, want: "SCSI.3"},
+		{name: "gap", bus: "SCSI", index: 1, want: "SCSI.1"},
+		{name: "empty bus", bus: "SATA", index: -1, want: "SATA.0"},
+		{name: "collision", bus: "SCSI", index: 2, wantErr: "device index SCSI.2 is already used by disk-2"},
+		{name: "collision on other bus", bus: "IDE", index: 0, wantErr: "device index IDE.0 is already used by cdrom-0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := diskAddress(testDisks(), tt.bus, tt.index)
+			if len(tt.wantErr) > 0 {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("diskAddress() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("diskAddress() error = %v", err)
+			}
+			if key := diskAddressKey(got); key != tt.want {
+				t.Errorf("diskAddress() = %v, want %v", key, tt.want)
+			}
+		})
+	}
+}
+
+func Test_resizeDisk(t *testing.T) {
+	tests := []struct {
+		name    string
+		disk    int
+		size    int
+		wantErr string
+	}{
+		{name: "grow", disk: 0, size: 20480},
+		{name: "shrink", disk: 1, size: 10240, wantErr: "refusing to shrink disk from 20480 to 10240 MiB"},
+		{name: "same size", disk: 0, size: 10240, wantErr: "disk is already 10240 MiB"},
+		{name: "cdrom", disk: 2, size: 10240, wantErr: "CD-ROM"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			disks := testDisks()
+			disks[tt.disk].DiskSizeBytes = disks[tt.disk].DiskSizeMib << 20
+
+			err := resizeDisk(&disks[tt.disk], tt.size)
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("resizeDisk() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resizeDisk() error = %v", err)
+			}
+			if disks[tt.disk].DiskSizeMib != tt.size || disks[tt.disk].DiskSizeBytes != 0 {
+				t.Errorf("resizeDisk() size = %d MiB %d bytes", disks[tt.disk].DiskSizeMib, disks[tt.disk].DiskSizeBytes)
+			}
+		})
+	}
+}
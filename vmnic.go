@@ -0,0 +1,288 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/routebyintuition/ntnx-go-sdk/pc"
+	"github.com/urfave/cli/v2"
+)
+
+// findNIC returns the position of a NIC identified by its UUID or MAC address
+func findNIC(nics []pc.NicList, ref string) (int, error) {
+	for i, nic := range nics {
+		if (len(nic.UUID) > 0 && nic.UUID == ref) || (len(nic.MacAddress) > 0 && strings.EqualFold(nic.MacAddress, ref)) {
+			return i, nil
+		}
+	}
+
+	return 0, fmt.Errorf("NIC %s not found on the VM", ref)
+}
+
+// ipInSubnet checks that a static IP can be requested on a subnet. The IP
+// must fall inside the subnet prefix and cannot be the network, broadcast or
+// gateway address.
+func ipInSubnet(ip string, subnetName string, config *pc.IPConfig) error {
+	addr := net.ParseIP(ip).To4()
+	if addr == nil {
+		return fmt.Errorf("invalid IP address %q", ip)
+	}
+
+	if config == nil || len(config.SubnetIP) == 0 {
+		return fmt.Errorf("subnet %s has no IP address management. static IPs cannot be requested on it", subnetName)
+	}
+
+	_, network, err := net.ParseCIDR(fmt.Sprintf("%s/%d", config.SubnetIP, config.PrefixLength))
+	if err != nil {
+		return fmt.Errorf("subnet %s: %v", subnetName, err)
+	}
+	if !network.Contains(addr) {
+		return fmt.Errorf("IP %s is outside subnet %s (%s)", ip, subnetName, network)
+	}
+
+	broadcast := make(net.IP, len(addr))
+	for i := range addr {
+		broadcast[i] = network.IP[i] | ^network.Mask[i]
+	}
+	if addr.Equal(network.IP) || addr.Equal(broadcast) {
+		return fmt.Errorf("IP %s is the network or broadcast address of subnet %s", ip, subnetName)
+	}
+	if addr.Equal(net.ParseIP(config.DefaultGatewayIP)) {
+		return fmt.Errorf("IP %s is the gateway of subnet %s", ip, subnetName)
+	}
+
+	return nil
+}
+
+// ipOwner returns the VM already using an IP. The NIC identified by vmUUID
+// and nicUUID is skipped so a NIC can keep its own address.
+func ipOwner(vms []pc.Entities, ip, vmUUID, nicUUID string) (string, bool) {
+	for _, vm := range vms {
+		lists := []*[]pc.NicList{}
+		if vm.Spec.Resources != nil {
+			lists = append(lists, vm.Spec.Resources.NicList)
+		}
+		if vm.Status.Resources != nil {
+			lists = append(lists, vm.Status.Resources.NicList)
+		}
+
+		for _, list := range lists {
+			if list == nil {
+				continue
+			}
+			for _, nic := range *list {
+				if stringValue(vm.Metadata.UUID) == vmUUID && len(nicUUID) > 0 && nic.UUID == nicUUID {
+					continue
+				}
+				for _, endpoint := range nic.IPEndpointList {
+					if endpoint.IP == ip {
+						return stringValue(vm.Spec.Name), true
+					}
+				}
+			}
+		}
+	}
+
+	return "", false
+}
+
+// nicSubnet resolves a subnet name or UUID and returns its reference and IP
+// config
+func (n *NCLI) nicSubnet(name string) (pc.SubnetReference, *pc.IPConfig, error) {
+	subnetUUID, err := n.resolveUUID(kindSubnet, name)
+	if err != nil {
+		return pc.SubnetReference{}, nil, err
+	}
+
+	subnets, err := n.listAllSubnets()
+	if err != nil {
+		return pc.SubnetReference{}, nil, err
+	}
+
+	for _, subnet := range subnets {
+		if stringValue(subnet.Metadata.UUID) != subnetUUID {
+			continue
+		}
+		ref := pc.SubnetReference{Kind: "subnet", UUID: subnetUUID, Name: stringValue(subnet.Spec.Name)}
+		if subnet.Spec.Resources == nil {
+			return ref, nil, nil
+		}
+		return ref, subnet.Spec.Resources.IPConfig, nil
+	}
+
+	return pc.SubnetReference{}, nil, fmt.Errorf("subnet %s not found", name)
+}
+
+// checkStaticIP validates a requested static IP against the subnet and the
+// addresses of every other VM
+func (n *NCLI) checkStaticIP(ip string, subnet pc.SubnetReference, config *pc.IPConfig, vmUUID, nicUUID string) error {
+	if err := ipInSubnet(ip, subnet.Name, config); err != nil {
+		return err
+	}
+
+	vms, err := n.listAllVMs(new(pc.VMListRequest), 0)
+	if err != nil {
+		return err
+	}
+	if owner, ok := ipOwner(vms, ip, vmUUID, nicUUID); ok {
+		return fmt.Errorf("IP %s is already assigned to %s", ip, owner)
+	}
+
+	return nil
+}
+
+// nicMessage describes a NIC for command output
+func nicMessage(nic pc.NicList) string {
+	message := "on " + nic.SubnetReference.Name
+	if len(nic.IPEndpointList) > 0 {
+		message += " with IP " + nic.IPEndpointList[0].IP
+	}
+	return message
+}
+
+// vmNICAdd adds a NIC to a VM
+func (n *NCLI) vmNICAdd(c *cli.Context) error {
+	if len(c.String("subnet")) == 0 {
+		return errors.New("no subnet provided. use --subnet <name|UUID>")
+	}
+
+	vmUUID, err := n.resolveUUID(kindVM, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	subnet, config, err := n.nicSubnet(c.String("subnet"))
+	if err != nil {
+		return err
+	}
+
+	nic := pc.NicList{NicType: "NORMAL_NIC", IsConnected: true, SubnetReference: subnet}
+
+	if len(c.String("mac")) > 0 {
+		mac, err := net.ParseMAC(c.String("mac"))
+		if err != nil {
+			return fmt.Errorf("invalid MAC address %q", c.String("mac"))
+		}
+		nic.MacAddress = mac.String()
+	}
+
+	if len(c.String("ip")) > 0 {
+		if err := n.checkStaticIP(c.String("ip"), subnet, config, vmUUID, ""); err != nil {
+			return err
+		}
+		nic.IPEndpointList = []pc.IPEndpointList{{IP: c.String("ip"), Type: "ASSIGNED"}}
+	}
+
+	return n.updateVMAndReport(c, vmUUID, func(vm *vmSpec) (string, error) {
+		nics := []pc.NicList{}
+		if vm.Spec.Resources.NicList != nil {
+			nics = *vm.Spec.Resources.NicList
+		}
+		if len(nic.MacAddress) > 0 {
+			if _, err := findNIC(nics, nic.MacAddress); err == nil {
+				return "", fmt.Errorf("MAC address %s is already used by the VM", nic.MacAddress)
+			}
+		}
+
+		nics = append(nics, nic)
+		vm.Spec.Resources.NicList = &nics
+
+		return fmt.Sprintf("NIC added to %s %s", stringValue(vm.Spec.Name), nicMessage(nic)), nil
+	})
+}
+
+// vmNICUpdate moves a NIC to another subnet or changes its static IP
+func (n *NCLI) vmNICUpdate(c *cli.Context) error {
+	ref := c.Args().Get(1)
+	if len(ref) == 0 {
+		return errors.New("no NIC provided. <VM name|UUID> <NIC UUID|MAC>")
+	}
+	if len(c.String("subnet")) == 0 && len(c.String("ip")) == 0 {
+		return errors.New("nothing to update. use --subnet <name|UUID> and/or --ip <address>")
+	}
+
+	vmUUID, err := n.resolveUUID(kindVM, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	current, err := n.getVMSpec(vmUUID)
+	if err != nil {
+		return err
+	}
+	nics := []pc.NicList{}
+	if current.Spec.Resources != nil && current.Spec.Resources.NicList != nil {
+		nics = *current.Spec.Resources.NicList
+	}
+	i, err := findNIC(nics, ref)
+	if err != nil {
+		return err
+	}
+	nic := nics[i]
+
+	subnetName := c.String("subnet")
+	if len(subnetName) == 0 {
+		subnetName = nic.SubnetReference.UUID
+	}
+	subnet, config, err := n.nicSubnet(subnetName)
+	if err != nil {
+		return err
+	}
+
+	if subnet.UUID != nic.SubnetReference.UUID {
+		nic.SubnetReference = subnet
+		nic.IPEndpointList = nil
+	}
+
+	if len(c.String("ip")) > 0 {
+		if err := n.checkStaticIP(c.String("ip"), subnet, config, vmUUID, nic.UUID); err != nil {
+			return err
+		}
+		nic.IPEndpointList = []pc.IPEndpointList{{IP: c.String("ip"), Type: "ASSIGNED"}}
+	}
+
+	return n.updateVMAndReport(c, vmUUID, func(vm *vmSpec) (string, error) {
+		nics := []pc.NicList{}
+		if vm.Spec.Resources.NicList != nil {
+			nics = *vm.Spec.Resources.NicList
+		}
+		i, err := findNIC(nics, ref)
+		if err != nil {
+			return "", err
+		}
+		nics[i] = nic
+
+		return fmt.Sprintf("NIC %s updated %s", ref, nicMessage(nic)), nil
+	})
+}
+
+// vmNICRemove removes a NIC from a VM
+func (n *NCLI) vmNICRemove(c *cli.Context) error {
+	ref := c.Args().Get(1)
+	if len(ref) == 0 {
+		return errors.New("no NIC provided. <VM name|UUID> <NIC UUID|MAC>")
+	}
+
+	vmUUID, err := n.resolveUUID(kindVM, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	return n.updateVMAndReport(c, vmUUID, func(vm *vmSpec) (string, error) {
+		nics := []pc.NicList{}
+		if vm.Spec.Resources.NicList != nil {
+			nics = *vm.Spec.Resources.NicList
+		}
+		i, err := findNIC(nics, ref)
+		if err != nil {
+			return "", err
+		}
+
+		remaining := append(append([]pc.NicList{}, nics[:i]...), nics[i+1:]...)
+		vm.Spec.Resources.NicList = &remaining
+
+		return fmt.Sprintf("NIC %s removed from %s", ref, stringValue(vm.Spec.Name)), nil
+	})
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	nutanix "github.com/routebyintuition/ntnx-go-sdk"
+	"github.com/routebyintuition/ntnx-go-sdk/pc"
+)
+
+func Test_ipInSubnet(t *testing.T) {
+	config := &pc.IPConfig{SubnetIP: "10.10.0.0", PrefixLength: 24, DefaultGatewayIP: "10.10.0.1"}
+
+	tests := []struct {
+		name    string
+		ip      string
+		config  *pc.IPConfig
+		wantErr string
+	}{
+		{name: "inside", ip: "10.10.0.50", config: config},
+		{name: "outside", ip: "10.20.0.50", config: config, wantErr: "outside subnet prod (10.10.0.0/24)"},
+		{name: "network", ip: "10.10.0.0", config: config, wantErr: "network or broadcast"},
+		{name: "broadcast", ip: "10.10.0.255", config: config, wantErr: "network or broadcast"},
+		{name: "gateway", ip: "10.10.0.1", config: config, wantErr: "gateway"},
+		{name: "invalid", ip: "10.10.0", config: config, wantErr: "invalid IP address"},
+		{name: "unmanaged", ip: "10.10.0.50", wantErr: "no IP address management"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ipInSubnet(tt.ip, "prod", tt.config)
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("ipInSubnet() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ipInSubnet() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_ipOwner(t *testing.T) {
+	vm := func(uuid, name, nicUUID, ip string) pc.Entities {
+		nics := []pc.NicList{{UUID: nicUUID, IPEndpointList: []pc.IPEndpointList{{IP: ip}}}}
+		return pc.Entities{
+			Metadata: pc.Metadata{UUID: nutanix.String(uuid)},
+			Spec:     pc.Spec{Name: nutanix.String(name), Resources: &pc.Resources{NicList: &nics}},
+		}
+	}
+	vms := []pc.Entities{vm("vm-1", "web-01", "nic-1", "10.10.0.11"), vm("vm-2", "web-02", "nic-2", "10.10.0.12")}
+
+	tests := []struct {
+		name    string
+		ip      string
+		vmUUID  string
+		nicUUID string
+		want    string
+		wantOK  bool
+	}{
+		{name: "free", ip: "10.10.0.50", vmUUID: "vm-1"},
+		{name: "other VM", ip: "10.10.0.12", vmUUID: "vm-1", want: "web-02", wantOK: true},
+		{name: "other NIC on the VM", ip: "10.10.0.11", vmUUID: "vm-1", want: "web-01", wantOK: true},
+		{name: "same NIC", ip: "10.10.0.11", vmUUID: "vm-1", nicUUID: "nic-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ipOwner(vms, tt.ip, tt.vmUUID, tt.nicUUID)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("ipOwner() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func Test_findNIC(t *testing.T) {
+	nics := []pc.NicList{{UUID: "nic-1", MacAddress: "50:6b:8d:00:00:01"}, {UUID: "nic-2", MacAddress: "50:6b:8d:00:00:02"}}
+
+	if i, err := findNIC(nics, "nic-2"); err != nil || i != 1 {
+		t.Errorf("findNIC(uuid) = %v, %v", i, err)
+	}
+	if i, err := findNIC(nics, "50:6B:8D:00:00:01"); err != nil || i != 0 {
+		t.Errorf("findNIC(mac) = %v, %v", i, err)
+	}
+	if _, err := findNIC(nics, "nic-3"); err == nil {
+		t.Error("findNIC(missing) should fail")
+	}
+}
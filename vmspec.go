@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/routebyintuition/ntnx-go-sdk/pc"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v2"
+)
+
+// vmSpec is a VM as returned by Prism Central along with its categories. The
+// SDK metadata type cannot hold categories so they are carried separately to
+// survive a get/modify/PUT round trip.
+type vmSpec struct {
+	pc.VMGetResponse
+	Categories map[string]string
+}
+
+// vmDocument is a VM create request read from YAML along with its categories
+type vmDocument struct {
+	Source     string
+	Request    *pc.VMCreateRequest
+	Categories map[string]string
+}
+
+// vmCategories holds the categories of a VM request or response
+type vmCategories struct {
+	Metadata struct {
+		Categories map[string]string `json:"categories" yaml:"categories"`
+	} `json:"metadata" yaml:"metadata"`
+}
+
+// name returns the VM name of the document
+func (d *vmDocument) name() string {
+	return stringValue(d.Request.Spec.Name)
+}
+
+// decodeVMSpec decodes a v3 VM get response into a fresh vmSpec
+func decodeVMSpec(raw []byte) (*vmSpec, error) {
+	vm := &vmSpec{}
+	if err := json.Unmarshal(raw, &vm.VMGetResponse); err != nil {
+		return nil, err
+	}
+
+	cats := vmCategories{}
+	if err := json.Unmarshal(raw, &cats); err != nil {
+		return nil, err
+	}
+	vm.Categories = cats.Metadata.Categories
+
+	return vm, nil
+}
+
+// getVMRaw retrieves the JSON of a VM from Prism Central
+func (n *NCLI) getVMRaw(vmUUID string) ([]byte, error) {
+	req, err := n.con.PC.NewRequest("GET", "vms/"+vmUUID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if _, err := n.con.PC.Do(req, &buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// getVMSpec retrieves a VM including its categories
+func (n *NCLI) getVMSpec(vmUUID string) (*vmSpec, error) {
+	raw, err := n.getVMRaw(vmUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeVMSpec(raw)
+}
+
+// updateBody builds the PUT body for a VM. Status is dropped and categories
+// are written back into the metadata.
+func (vm *vmSpec) updateBody() (map[string]interface{}, error) {
+	meta := vm.Metadata
+	meta.Categories = nil
+	meta.CategoriesMapping = nil
+
+	metaMap := map[string]interface{}{}
+	raw, err := json.Marshal(meta)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &metaMap); err != nil {
+		return nil, err
+	}
+	if vm.Categories != nil {
+		metaMap["categories"] = vm.Categories
+	}
+
+	return map[string]interface{}{
+		"api_version": vm.APIVersion,
+		"metadata":    metaMap,
+		"spec":        vm.Spec,
+	}, nil
+}
+
+// updateVM retrieves a VM, applies modify to its spec and sends the update
+// with the spec_version that was read. With --dry-run the update is printed
+// as a diff against the current VM instead.
+func (n *NCLI) updateVM(vmUUID string, modify func(vm *vmSpec) error) (*pc.VMUpdateResponse, error) {
+	raw, err := n.getVMRaw(vmUUID)
+	if err != nil {
+		return nil, err
+	}
+	vm, err := decodeVMSpec(raw)
+	if err != nil {
+		return nil, err
+	}
+	if vm.Spec.Resources == nil {
+		vm.Spec.Resources = &pc.Resources{}
+	}
+
+	if err := modify(vm); err != nil {
+		return nil, err
+	}
+
+	if n.dryRun {
+		current, err := decodeVMSpec(raw)
+		if err != nil {
+			return nil, err
+		}
+		currentBody, err := current.updateBody()
+		if err != nil {
+			return nil, err
+		}
+		body, err := vm.updateBody()
+		if err != nil {
+			return nil, err
+		}
+		return nil, n.dryRunRequest("PUT", "vms/"+vmUUID, currentBody, body)
+	}
+
+	return n.putVMSpec(vmUUID, vm)
+}
+
+// updateVMAndReport applies a change to a VM with updateVM, prints the
+// message returned by modify and reports the update task
+func (n *NCLI) updateVMAndReport(c *cli.Context, vmUUID string, modify func(vm *vmSpec) (string, error)) error {
+	var message string
+	updateRes, err := n.updateVM(vmUUID, func(vm *vmSpec) error {
+		var err error
+		message, err = modify(vm)
+		return err
+	})
+	if err == errDryRun {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(n.out, message)
+
+	return n.reportTask(c, n.getPCTask, executionTask(updateRes.Status))
+}
+
+// putVMSpec sends a VM update request
+func (n *NCLI) putVMSpec(vmUUID string, vm *vmSpec) (*pc.VMUpdateResponse, error) {
+	body, err := vm.updateBody()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := n.con.PC.NewRequest("PUT", "vms/"+vmUUID, body)
+	if err != nil {
+		return nil, err
+	}
+
+	updateRes := new(pc.VMUpdateResponse)
+	if _, err := n.con.PC.Do(req, updateRes); err != nil {
+		return nil, err
+	}
+
+	return updateRes, nil
+}
+
+// createVM sends a VM create request including the document categories
+func (n *NCLI) createVM(doc *vmDocument) (*pc.VMCreateResponse, error) {
+	body := map[string]interface{}{}
+	raw, err := json.Marshal(doc.Request)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return nil, err
+	}
+
+	meta, _ := body["metadata"].(map[string]interface{})
+	if meta == nil {
+		meta = map[string]interface{}{}
+	}
+	delete(meta, "categories")
+	if len(doc.Categories) > 0 {
+		meta["categories"] = doc.Categories
+	}
+	body["metadata"] = meta
+
+	if n.dryRun {
+		return nil, n.dryRunRequest("POST", "vms", nil, body)
+	}
+
+	req, err := n.con.PC.NewRequest("POST", "vms", body)
+	if err != nil {
+		return nil, err
+	}
+
+	createRes := new(pc.VMCreateResponse)
+	if _, err := n.con.PC.Do(req, createRes); err != nil {
+		return nil, err
+	}
+
+	return createRes, nil
+}
+
+// loadVMDocuments renders a VM YAML file as a template and decodes every YAML
+// document in it. Guest customization files are resolved against the
+// directory of the source and overridden by the files provided.
+func (n *NCLI) loadVMDocuments(source string, raw []byte, vars map[string]string, override guestFiles) ([]*vmDocument, error) {
+	rendered, err := renderTemplate(source, raw, vars, n.templateFuncs())
+	if err != nil {
+		return nil, err
+	}
+
+	baseDir := ""
+	if source != "stdin" {
+		baseDir = filepath.Dir(source)
+	}
+
+	docs := []*vmDocument{}
+	for _, part := range splitYAMLDocuments(rendered) {
+		doc := &vmDocument{Source: source, Request: &pc.VMCreateRequest{}}
+
+		if _, err := processYAMLReader(bytes.NewReader(part), doc.Request); err != nil {
+			return nil, err
+		}
+
+		cats := vmCategories{}
+		if err := yaml.Unmarshal(part, &cats); err != nil {
+			return nil, err
+		}
+		doc.Categories = cats.Metadata.Categories
+
+		files, err := specGuestFiles(part, baseDir)
+		if err != nil {
+			return nil, err
+		}
+		if err := applyGuestFiles(doc.Request, files.merge(override)); err != nil {
+			return nil, err
+		}
+
+		docs = append(docs, doc)
+	}
+
+	if len(docs) == 0 {
+		return nil, errors.New("no VM spec found in " + source)
+	}
+
+	return docs, nil
+}
+
+// splitYAMLDocuments splits a YAML stream on document separators and drops
+// documents that are empty
+func splitYAMLDocuments(raw []byte) [][]byte {
+	docs := [][]byte{}
+	current := []string{}
+
+	flush := func() {
+		doc := strings.Join(current, "\n")
+		if len(strings.TrimSpace(doc)) > 0 {
+			docs = append(docs, []byte(doc+"\n"))
+		}
+		current = []string{}
+	}
+
+	for _, line := range strings.Split(string(raw), "\n") {
+		if strings.TrimRight(line, " \t\r") == "---" {
+			flush()
+			continue
+		}
+		current = append(current, line)
+	}
+	flush()
+
+	return docs
+}